@@ -11,12 +11,20 @@
 // When building app plugins, it is possible for that plugin to support
 // multiple matrix elements, but each implementation of the interface
 // is expeced to only implement one.
+//
+// The optional interfaces in this package, such as DevDetacher, are
+// detected with type assertions on the App. The RPC client that plugins
+// are used through only implements App itself, so they are only
+// available to app implementations built into Otto for now.
 package app
 
 import (
+	"io"
+
 	"github.com/hashicorp/otto/appfile"
 	"github.com/hashicorp/otto/appfile/detect"
 	"github.com/hashicorp/otto/context"
+	"github.com/hashicorp/otto/directory"
 	"github.com/hashicorp/otto/foundation"
 	"github.com/hashicorp/otto/ui"
 )
@@ -75,6 +83,154 @@ type App interface {
 	DevDep(dst *Context, src *Context) (*DevDep, error)
 }
 
+// Importer is an optional interface that an App can implement to adopt
+// a deploy that already exists but wasn't created by Otto.
+type Importer interface {
+	// Import is given the identifiers of the existing resources (the
+	// keys and values are specific to each app implementation) and
+	// should return the deploy record that represents them. Otto will
+	// fill in the lookup information and store the record.
+	Import(ctx *Context, ids map[string]string) (*directory.Deploy, error)
+}
+
+// ArtifactDeployer is an optional interface that an App can implement
+// to deploy a specific build artifact that Otto looked up, rather than
+// looking up the artifact itself as Deploy does. It isn't available to
+// plugin apps yet, so they can only deploy the latest build.
+type ArtifactDeployer interface {
+	DeployArtifact(ctx *Context, build *directory.Build) error
+}
+
+// Outputter is an optional interface that an App can implement to
+// report the outputs of its deploy, such as addresses or resource IDs,
+// by querying them directly.
+type Outputter interface {
+	Outputs(*Context) (map[string]string, error)
+}
+
+// Sheller is an optional interface that an App can implement to support
+// opening an interactive shell into its running development environment.
+type Sheller interface {
+	// Shell returns the command that, when run with the user's terminal
+	// attached, opens an interactive session into the dev environment.
+	Shell(*Context) (*ShellCommand, error)
+}
+
+// HealthChecker is an optional interface that an App can implement to
+// report whether its development environment is ready to be used. After
+// Dev, Otto calls HealthCheck repeatedly until it returns nil or a
+// timeout is reached. Plugin apps can't implement it yet, so Dev
+// returns for them without waiting.
+type HealthChecker interface {
+	HealthCheck(*Context) error
+}
+
+// DevDetacher is an optional interface that an App can implement to run
+// its development environment in the background, so that Otto doesn't
+// block until it exits. It isn't forwarded to plugins, so Core.DevDetached
+// fails for the apps they provide.
+type DevDetacher interface {
+	// DevDetached starts the development environment in the background
+	// and returns as soon as it is started. The handle is stored by Otto
+	// and given to DevStop later, possibly by another process.
+	DevDetached(*Context) (*DevHandle, error)
+
+	// DevStop stops the development environment started by DevDetached.
+	DevStop(*Context, *DevHandle) error
+}
+
+// DevHandle identifies a development environment running in the
+// background so that it can be stopped later.
+type DevHandle struct {
+	// PID is the process ID of the environment, if it is a process.
+	PID int `json:"pid,omitempty"`
+
+	// Data is any other information the app needs to stop it. The keys
+	// and values are specific to each app implementation.
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// LogStreamer is an optional interface that an App can implement to
+// stream the logs of its running development environment. Apps provided
+// by plugins can't implement it yet, so Core.LogsAll skips them.
+type LogStreamer interface {
+	// Logs returns the logs as a stream of lines. Closing it must stop
+	// any following of the logs.
+	Logs(*Context, *LogsOpts) (io.ReadCloser, error)
+}
+
+// LogsOpts are the options for LogStreamer.Logs.
+type LogsOpts struct {
+	// Follow, if true, keeps the stream open and streams new lines as
+	// they are logged, until it is closed.
+	Follow bool
+
+	// Lines is the number of most recent lines to start with. Zero
+	// starts with all of them.
+	Lines int
+}
+
+// CacheKeyer is an optional interface that an App can implement to
+// control when its cached dev dependency is reused. The key should
+// change whenever something that affects the result of DevDep changes,
+// such as the contents of the files the app builds it from. Otto
+// rebuilds the dev dependency when the key changes. An empty key uses
+// the default cache, which is also what the apps of plugins get since
+// this isn't forwarded over RPC yet.
+type CacheKeyer interface {
+	CacheKey(*Context) (string, error)
+}
+
+// InfraOutputRequirer is an optional interface that an App can
+// implement to declare the infrastructure outputs it needs to compile,
+// such as a VPC or subnet ID. Otto verifies that the infrastructure
+// provides all of them before the app is compiled, according to the
+// outputs of its compilation and of the infrastructure already created.
+// Plugin apps can't declare their requirements this way yet, so they
+// are compiled without the check.
+type InfraOutputRequirer interface {
+	RequiredInfraOutputs() []string
+}
+
+// ActionLister is an optional interface that an App can implement to
+// declare the actions that its development environment accepts, such
+// as "ssh" or "reload". If it is implemented, Otto validates the
+// requested action and its arguments before executing it.
+type ActionLister interface {
+	Actions() []Action
+}
+
+// Action describes an action that the development environment accepts.
+type Action struct {
+	// Name is the name of the action.
+	Name string
+
+	// Synopsis is a short sentence describing what the action does.
+	Synopsis string
+
+	// MinArgs and MaxArgs are the bounds on the number of arguments
+	// the action accepts. If MaxArgs is negative, there is no upper
+	// bound.
+	MinArgs int
+	MaxArgs int
+}
+
+// ShellCommand is the command used to open an interactive shell.
+type ShellCommand struct {
+	// Path is the program to execute and Args are its arguments (not
+	// including the program name).
+	Path string
+	Args []string
+
+	// Dir is the working directory for the command. If empty, the
+	// current working directory is used.
+	Dir string
+
+	// Env is additional environment variables in "KEY=VALUE" form that
+	// are added to the current environment.
+	Env []string
+}
+
 // Meta is metadata about an app implementation.
 type Meta struct {
 	// Tuples returns the tuples that this app implementation supports.
@@ -152,6 +308,11 @@ type Context struct {
 	// This is only available if this app is the root application being
 	// developed (dependencies don't get an IP).
 	DevIPAddress string
+
+	// ResourceLimits, if non-nil, are the limits that the development
+	// environment of this application should be constrained to. See the
+	// ResourceLimits docs for details.
+	ResourceLimits *ResourceLimits
 }
 
 // RouteName implements the router.Context interface so we can use Router
@@ -184,6 +345,16 @@ type CompileResult struct {
 	// used as a dependency.
 	DevDepFragmentPath string `json:"dev_dep_fragment_path"`
 
+	// InfraFragmentPath is the path to a fragment that the
+	// infrastructure compile uses to generate the infrastructure for
+	// this application. This is only given to the infrastructure if the
+	// apps are compiled before it; see otto.CoreConfig.CompilePhaseDeps.
+	InfraFragmentPath string `json:"infra_fragment_path,omitempty"`
+
+	// Warnings are problems that didn't cause the compilation to fail
+	// but should be shown to the user, such as deprecated configuration.
+	Warnings []string `json:"warnings"`
+
 	// FoundationResults are the compilation results of the foundations.
 	//
 	// This is populated by Otto core and any set value here will be ignored.
@@ -24,6 +24,20 @@ type DevDep struct {
 	Files []string `json:"files"`
 }
 
+// ResourceLimits are limits on the resources that a development
+// environment may use. A zero value for a field means no limit.
+//
+// Enforcement of these limits depends entirely on the app
+// implementation. Implementations that manage VMs or containers should
+// translate them into the equivalent settings of their backend.
+type ResourceLimits struct {
+	// CPUs is the maximum number of CPUs.
+	CPUs int `json:"cpus"`
+
+	// MemoryMB is the maximum amount of memory in megabytes.
+	MemoryMB int `json:"memory_mb"`
+}
+
 // RelFiles makes all the Files values relative to the given directory.
 func (d *DevDep) RelFiles(dir string) error {
 	for i, f := range d.Files {
@@ -2,9 +2,12 @@ package appfile
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -45,6 +48,12 @@ type Compiled struct {
 	// Graph is the DAG that has all the dependencies. This is already
 	// verified to have no cycles. Each vertex is a *CompiledGraphVertex.
 	Graph *dag.AcyclicGraph
+
+	// SourceHash is the SourceHash of the root Appfile when it was
+	// compiled, so that a compiled Appfile that is out of date with its
+	// source can be detected. It is blank if the Appfile has no source
+	// file or was compiled by an older version.
+	SourceHash string
 }
 
 func (c *Compiled) Validate() error {
@@ -103,10 +112,24 @@ type CompiledGraphVertex struct {
 	// this value).
 	Dir string
 
+	// Scope is the operation this Appfile is needed for, one of the
+	// DependencyScope constants. It is determined by the scopes of the
+	// dependencies along every path from the root, so a dependency of
+	// a dev-only dependency is also dev-only. The root is always needed.
+	Scope string
+
 	// Don't use this outside of this package.
 	NameValue string
 }
 
+// InScope returns true if this Appfile is needed for the operation with
+// the given scope. Everything is in DependencyScopeAll.
+func (v *CompiledGraphVertex) InScope(scope string) bool {
+	return scope == DependencyScopeAll ||
+		v.Scope == DependencyScopeAll ||
+		v.Scope == scope
+}
+
 func (v *CompiledGraphVertex) Name() string {
 	return v.NameValue
 }
@@ -237,6 +260,12 @@ func (c *Compiler) Compile(f *File) (*Compiled, error) {
 		return nil, fmt.Errorf("Error writing compiled Appfile version: %s", err)
 	}
 
+	// Record the source as it is now, before anything could change it
+	sourceHash, err := SourceHash(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Error hashing Appfile %s: %s", f.Path, err)
+	}
+
 	// Check if we have an ID for this or not. If we don't, then we need
 	// to write the ID file. We only do this if the file has a path.
 	if f.Path != "" {
@@ -288,6 +317,7 @@ func (c *Compiler) Compile(f *File) (*Compiled, error) {
 	if err := compiled.Validate(); err != nil {
 		return nil, err
 	}
+	compiled.SourceHash = sourceHash
 
 	// Write the compiled Appfile data
 	if err := compileWrite(c.opts.Dir, compiled); err != nil {
@@ -312,8 +342,13 @@ func (c *Compiler) MinCompile(f *File) (*Compiled, error) {
 		return nil, err
 	}
 
-	// Add our root vertex for this Appfile
-	vertex := &CompiledGraphVertex{File: f, NameValue: f.Application.Name}
+	// Add our root vertex for this Appfile. The application may be
+	// missing, in which case validation will report it later.
+	var name string
+	if f.Application != nil {
+		name = f.Application.Name
+	}
+	vertex := &CompiledGraphVertex{File: f, NameValue: name}
 	compiled.Graph.Add(vertex)
 
 	return compiled, nil
@@ -342,6 +377,10 @@ func (c *Compiler) compileDependencies(root *CompiledGraphVertex, graph *dag.Acy
 	queue := make([]*CompiledGraphVertex, 1, 30)
 	queue[0] = root
 
+	// Keep track of the edges and their scopes so we can determine the
+	// scope of every vertex once the graph is complete.
+	var edges []*scopedEdge
+
 	// While we still have dependencies to get, continue loading them.
 	// TODO: parallelize
 	for len(queue) > 0 {
@@ -457,12 +496,81 @@ func (c *Compiler) compileDependencies(root *CompiledGraphVertex, graph *dag.Acy
 
 			// Connect the dependencies
 			graph.Connect(dag.BasicEdge(current, vertex))
+			edges = append(edges, &scopedEdge{
+				Source: current,
+				Target: vertex,
+				Mask:   scopeMask(dep.Scope),
+			})
 		}
 	}
 
+	computeScopes(root, edges)
 	return nil
 }
 
+// The scopes of a vertex are tracked as a bit mask while computing them
+// so that the scopes along different paths can be combined.
+const (
+	scopeMaskDev = 1 << iota
+	scopeMaskBuild
+
+	scopeMaskAll = scopeMaskDev | scopeMaskBuild
+)
+
+// scopedEdge is a dependency edge along with the scope it was declared
+// with.
+type scopedEdge struct {
+	Source, Target *CompiledGraphVertex
+	Mask           int
+}
+
+func scopeMask(scope string) int {
+	switch scope {
+	case DependencyScopeDev:
+		return scopeMaskDev
+	case DependencyScopeBuild:
+		return scopeMaskBuild
+	default:
+		return scopeMaskAll
+	}
+}
+
+// computeScopes sets the Scope of every vertex reachable from the root.
+// A vertex is needed for a scope if there is a path from the root on
+// which every dependency is needed for that scope.
+func computeScopes(root *CompiledGraphVertex, edges []*scopedEdge) {
+	masks := map[*CompiledGraphVertex]int{root: scopeMaskAll}
+	for changed := true; changed; {
+		changed = false
+		for _, e := range edges {
+			mask := masks[e.Target] | (masks[e.Source] & e.Mask)
+			if mask != masks[e.Target] {
+				masks[e.Target] = mask
+				changed = true
+			}
+		}
+	}
+
+	for v, mask := range masks {
+		switch mask {
+		case scopeMaskDev:
+			v.Scope = DependencyScopeDev
+		case scopeMaskBuild:
+			v.Scope = DependencyScopeBuild
+		case scopeMaskAll:
+			v.Scope = DependencyScopeAll
+		default:
+			// The scopes along every path to this vertex conflict, such
+			// as a build-only dependency of a dev-only dependency. Treat
+			// it as always needed rather than never building it.
+			log.Printf(
+				"[WARN] dependency %s has conflicting scopes, using all",
+				v.Name())
+			v.Scope = DependencyScopeAll
+		}
+	}
+}
+
 type compileImportOpts struct {
 	Storage   getter.Storage
 	Cache     map[string]*File
@@ -657,6 +765,27 @@ func (c *Compiler) compileImports(root *File) error {
 	return resultErr
 }
 
+// SourceHash returns the hex-encoded SHA-256 hash of the contents of the
+// Appfile at path. It is blank if path is blank or the file doesn't
+// exist, such as for an Appfile that was generated by default.
+func SourceHash(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func compileVersion(dir string) error {
 	f, err := os.Create(filepath.Join(dir, CompileVersionFilename))
 	if err != nil {
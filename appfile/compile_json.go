@@ -14,8 +14,9 @@ import (
 
 func (c *Compiled) MarshalJSON() ([]byte, error) {
 	raw := &compiledJSON{
-		File:  c.File,
-		Edges: make([]map[string]string, 0, len(c.Graph.Edges())),
+		File:       c.File,
+		Edges:      make([]map[string]string, 0, len(c.Graph.Edges())),
+		SourceHash: c.SourceHash,
 	}
 
 	// Compile the list of vertices, keeping track of their position
@@ -44,6 +45,7 @@ func (c *Compiled) UnmarshalJSON(data []byte) error {
 	}
 
 	c.File = raw.File
+	c.SourceHash = raw.SourceHash
 	c.Graph = new(dag.AcyclicGraph)
 	for _, v := range raw.Vertices {
 		c.Graph.Add(v)
@@ -68,7 +70,8 @@ func (c *Compiled) UnmarshalJSON(data []byte) error {
 }
 
 type compiledJSON struct {
-	File     *File
-	Vertices []*CompiledGraphVertex
-	Edges    []map[string]string
+	File       *File
+	Vertices   []*CompiledGraphVertex
+	Edges      []map[string]string
+	SourceHash string `json:",omitempty"`
 }
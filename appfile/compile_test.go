@@ -135,6 +135,39 @@ func TestCompile_dotOttoId(t *testing.T) {
 	testCompileMarshal(t, c, opts.Dir)
 }
 
+func TestCompile_scope(t *testing.T) {
+	opts := testCompileOpts(t)
+	defer os.RemoveAll(opts.Dir)
+	f := testFile(t, "compile-dep-scope")
+	defer f.resetID()
+
+	c, err := testCompiler(t, opts).Compile(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Reload it to make sure the scopes are stored
+	c, err = LoadCompiled(opts.Dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"foo":    DependencyScopeAll,
+		"dev":    DependencyScopeDev,
+		"build":  DependencyScopeBuild,
+		"shared": DependencyScopeAll,
+	}
+	actual := make(map[string]string)
+	for _, raw := range c.Graph.Vertices() {
+		v := raw.(*CompiledGraphVertex)
+		actual[v.Name()] = v.Scope
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestCompile_structure(t *testing.T) {
 	cases := []struct {
 		Dir  string
@@ -348,6 +381,34 @@ func TestCompileID_existing(t *testing.T) {
 	}
 }
 
+func TestCompile_sourceHash(t *testing.T) {
+	opts := testCompileOpts(t)
+	defer os.RemoveAll(opts.Dir)
+	f := testFile(t, "compile-dep-scope")
+	defer f.resetID()
+
+	c, err := testCompiler(t, opts).Compile(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected, err := SourceHash(f.Path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected == "" || c.SourceHash != expected {
+		t.Fatalf("bad: %q", c.SourceHash)
+	}
+
+	// Reload it to make sure the hash is stored
+	c, err = LoadCompiled(opts.Dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.SourceHash != expected {
+		t.Fatalf("bad: %q", c.SourceHash)
+	}
+}
+
 func TestLoadCompile_new(t *testing.T) {
 	path := filepath.Join("./test-fixtures", "load-new")
 	_, err := LoadCompiled(path)
@@ -59,13 +59,33 @@ type Customization struct {
 // Dependency is another Appfile that an App depends on
 type Dependency struct {
 	Source string
+
+	// Scope is the operation the dependency is needed for, one of the
+	// DependencyScope constants. If it is blank, it is needed for all.
+	Scope string
 }
 
+// The valid values for the scope of a dependency.
+const (
+	DependencyScopeAll   = ""
+	DependencyScopeDev   = "dev"
+	DependencyScopeBuild = "build"
+)
+
 // Project is the structure of a project that many applications
 // can belong to.
 type Project struct {
 	Name           string
 	Infrastructure string
+
+	// PreCompile and PostCompile are shell commands that are run in
+	// order before and after the Appfile is compiled, such as to
+	// generate configuration the applications need. They are run in
+	// the project directory and compilation fails if one of them fails.
+	// Previews of the compilation, such as drift detection, don't run
+	// them.
+	PreCompile  []string `mapstructure:"pre_compile"`
+	PostCompile []string `mapstructure:"post_compile"`
 }
 
 // Infrastructure is the structure of defining the infrastructure
@@ -96,7 +96,7 @@ func (f *Customization) HCL() *ast.ObjectItem {
 }
 
 func (f *Dependency) HCL() *ast.ObjectItem {
-	items := make([]*ast.ObjectItem, 0, 1)
+	items := make([]*ast.ObjectItem, 0, 2)
 	items = append(items, &ast.ObjectItem{
 		Keys: []*ast.ObjectKey{
 			&ast.ObjectKey{
@@ -111,6 +111,22 @@ func (f *Dependency) HCL() *ast.ObjectItem {
 		},
 		Assign: emptyAssign,
 	})
+	if f.Scope != "" {
+		items = append(items, &ast.ObjectItem{
+			Keys: []*ast.ObjectKey{
+				&ast.ObjectKey{
+					Token: token.Token{Type: token.IDENT, Text: "scope"},
+				},
+			},
+			Val: &ast.LiteralType{
+				Token: token.Token{
+					Type: token.STRING,
+					Text: fmt.Sprintf(`"%s"`, f.Scope),
+				},
+			},
+			Assign: emptyAssign,
+		})
+	}
 
 	return &ast.ObjectItem{
 		Keys: []*ast.ObjectKey{
@@ -240,6 +256,12 @@ func (f *Project) HCL() *ast.ObjectItem {
 		},
 		Assign: emptyAssign,
 	})
+	if len(f.PreCompile) > 0 {
+		items = append(items, hclStringList("pre_compile", 3, f.PreCompile))
+	}
+	if len(f.PostCompile) > 0 {
+		items = append(items, hclStringList("post_compile", 4, f.PostCompile))
+	}
 
 	return &ast.ObjectItem{
 		Keys: []*ast.ObjectKey{
@@ -325,3 +347,30 @@ func (f *Infrastructure) HCL() *ast.ObjectItem {
 		},
 	}
 }
+
+// hclStringList returns the assignment of a list of strings to key.
+func hclStringList(key string, line int, values []string) *ast.ObjectItem {
+	list := make([]ast.Node, len(values))
+	for i, v := range values {
+		list[i] = &ast.LiteralType{
+			Token: token.Token{
+				Type: token.STRING,
+				Text: fmt.Sprintf("%q", v),
+			},
+		}
+	}
+
+	return &ast.ObjectItem{
+		Keys: []*ast.ObjectKey{
+			&ast.ObjectKey{
+				Token: token.Token{
+					Type: token.IDENT,
+					Text: key,
+					Pos:  token.Pos{Line: line},
+				},
+			},
+		},
+		Val:    &ast.ListType{List: list},
+		Assign: emptyAssign,
+	}
+}
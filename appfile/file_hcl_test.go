@@ -19,6 +19,7 @@ func TestFileHCL(t *testing.T) {
 		Input, Output string
 	}{
 		{"basic.hcl", "basic.golden"},
+		{"project-compile-hooks.hcl", "project-compile-hooks.golden"},
 	}
 
 	for _, tc := range cases {
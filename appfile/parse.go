@@ -302,7 +302,7 @@ func parseProject(result *File, list *ast.ObjectList) error {
 	item := list.Items[0]
 
 	// Check for invalid keys
-	valid := []string{"name", "infrastructure"}
+	valid := []string{"name", "infrastructure", "pre_compile", "post_compile"}
 	if err := checkHCLKeys(item.Val, valid); err != nil {
 		return multierror.Prefix(err, "project:")
 	}
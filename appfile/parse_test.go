@@ -112,6 +112,31 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		// Projects
+		{
+			"project-compile-hooks.hcl",
+			&File{
+				Application: &Application{
+					Name:   "foo",
+					Detect: true,
+				},
+				Project: &Project{
+					Name:           "foo",
+					Infrastructure: "aws",
+					PreCompile:     []string{"./scripts/gen-config.sh", `echo "pre"`},
+					PostCompile:    []string{"echo post"},
+				},
+				Infrastructure: []*Infrastructure{
+					&Infrastructure{
+						Name:   "aws",
+						Type:   "aws",
+						Flavor: "foo",
+					},
+				},
+			},
+			false,
+		},
+
 		// Infrastructures
 		{
 			"infra-dup.hcl",
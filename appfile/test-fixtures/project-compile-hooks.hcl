@@ -0,0 +1,15 @@
+application {
+    name = "foo"
+}
+
+project {
+    name = "foo"
+    infrastructure = "aws"
+    pre_compile = ["./scripts/gen-config.sh", "echo \"pre\""]
+    post_compile = ["echo post"]
+}
+
+infrastructure "aws" {
+    type = "aws"
+    flavor = "foo"
+}
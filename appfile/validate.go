@@ -2,6 +2,7 @@ package appfile
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 )
@@ -34,6 +35,15 @@ func (f *File) Validate() error {
 			result = multierror.Append(result, fmt.Errorf(
 				"application: type is required"))
 		}
+		for _, dep := range f.Application.Dependencies {
+			switch dep.Scope {
+			case DependencyScopeAll, DependencyScopeDev, DependencyScopeBuild:
+			default:
+				result = multierror.Append(result, fmt.Errorf(
+					"application: dependency '%s': scope must be '%s' or '%s', got '%s'",
+					dep.Source, DependencyScopeDev, DependencyScopeBuild, dep.Scope))
+			}
+		}
 	}
 
 	// Validate the project
@@ -59,6 +69,18 @@ func (f *File) Validate() error {
 					f.Project.Infrastructure))
 			}
 		}
+		for _, cmd := range f.Project.PreCompile {
+			if strings.TrimSpace(cmd) == "" {
+				result = multierror.Append(result, fmt.Errorf(
+					"project: pre_compile commands can't be empty"))
+			}
+		}
+		for _, cmd := range f.Project.PostCompile {
+			if strings.TrimSpace(cmd) == "" {
+				result = multierror.Append(result, fmt.Errorf(
+					"project: post_compile commands can't be empty"))
+			}
+		}
 	}
 
 	return result
@@ -40,6 +40,11 @@ func TestFileValidate(t *testing.T) {
 			true,
 		},
 
+		{
+			"validate-dep-bad-scope",
+			true,
+		},
+
 		{
 			"validate-project-no-name",
 			true,
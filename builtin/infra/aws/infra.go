@@ -81,19 +81,19 @@ func creds(ctx *infrastructure.Context) (map[string]string, error) {
 }
 
 func verifyCreds(ctx *infrastructure.Context) error {
-	found, err := sshagent.HasKey(ctx.InfraCreds["ssh_public_key"])
+	found, err := sshagent.HasKey(ctx.Cred("ssh_public_key"))
 	if err != nil {
 		return sshAgentError(err)
 	}
 	if !found {
 		ok, _ := guessAndLoadPrivateKey(
-			ctx.Ui, ctx.InfraCreds["ssh_public_key_path"])
+			ctx.Ui, ctx.Cred("ssh_public_key_path"))
 		if ok {
 			ctx.Ui.Message(
 				"A private key was found and loaded. Otto will now check\n" +
 					"the SSH Agent again and continue if the correct key is loaded")
 
-			found, err = sshagent.HasKey(ctx.InfraCreds["ssh_public_key"])
+			found, err = sshagent.HasKey(ctx.Cred("ssh_public_key"))
 			if err != nil {
 				return sshAgentError(err)
 			}
@@ -105,7 +105,7 @@ func verifyCreds(ctx *infrastructure.Context) error {
 			"You specified an SSH public key of: %q, but the private key from this\n"+
 				"keypair is not loaded the SSH Agent. To load it, run:\n\n"+
 				"  ssh-add [PATH_TO_PRIVATE_KEY]",
-			ctx.InfraCreds["ssh_public_key_path"]))
+			ctx.Cred("ssh_public_key_path")))
 	}
 	return nil
 }
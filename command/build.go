@@ -3,6 +3,8 @@ package command
 import (
 	"fmt"
 	"strings"
+
+	"github.com/hashicorp/otto/otto"
 )
 
 // BuildCommand is the command that builds a deployable artifact
@@ -44,7 +46,7 @@ func (c *BuildCommand) Run(args []string) int {
 	if err := core.Build(); err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Error building app: %s", err))
-		return 1
+		return otto.ExitCode(err)
 	}
 
 	return 0
@@ -190,6 +190,19 @@ func (c *CompileCommand) Run(args []string) int {
 		return 1
 	}
 
+	// Show anything we were warned about so it isn't lost in the output
+	if warnings := core.Warnings(); len(warnings) > 0 {
+		ui.Header("[yellow]Warnings:")
+		for _, w := range warnings {
+			if w.Vertex != "" {
+				ui.Message(fmt.Sprintf("[yellow]- %s (%s): %s", w.Vertex, w.Source, w.Message))
+			} else {
+				ui.Message(fmt.Sprintf("[yellow]- %s: %s", w.Source, w.Message))
+			}
+		}
+		ui.Message("")
+	}
+
 	// Success!
 	ui.Header("[green]Compilation success!")
 	ui.Message(fmt.Sprintf(
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/otto/helper/flag"
+	"github.com/hashicorp/otto/otto"
 )
 
 // DeployCommand is the command that deploys the app once it is built.
@@ -13,8 +14,10 @@ type DeployCommand struct {
 }
 
 func (c *DeployCommand) Run(args []string) int {
+	var flagArtifact string
 	fs := c.FlagSet("deploy", FlagSetNone)
 	fs.Usage = func() { c.Ui.Error(c.Help()) }
+	fs.StringVar(&flagArtifact, "artifact", "", "")
 	args, execArgs, posArgs := flag.FilterArgs(fs, args)
 	if err := fs.Parse(args); err != nil {
 		return 1
@@ -51,11 +54,16 @@ func (c *DeployCommand) Run(args []string) int {
 	}
 
 	// Deploy the artifact
-	if err := core.Deploy(action, execArgs); err != nil {
+	err = core.Deploy(&otto.DeployOpts{
+		Action:     action,
+		Args:       execArgs,
+		ArtifactID: flagArtifact,
+	})
+	if err != nil {
 		// Display errors without prefix, we expect them to be formatted in a way
 		// that's suitable for UI.
 		c.Ui.Error(err.Error())
-		return 1
+		return otto.ExitCode(err)
 	}
 
 	return 0
@@ -78,6 +86,11 @@ Usage: otto deploy [options]
   build artifact. Deploy can be called multiple times with the same
   artifact to redeploy an application.
 
+Options:
+
+  -artifact=id    The ID of the build artifact to deploy. This must be
+                  the latest build. By default the latest build is used.
+
 `
 
 	return strings.TrimSpace(helpText)
@@ -51,7 +51,7 @@ func (c *DevCommand) Run(args []string) int {
 		if err := core.Dev(); err != nil {
 			c.Ui.Error(fmt.Sprintf(
 				"Error building dev environment: %s", err))
-			return 1
+			return otto.ExitCode(err)
 		}
 
 		return 0
@@ -65,7 +65,7 @@ func (c *DevCommand) Run(args []string) int {
 	})
 	if err != nil {
 		c.Ui.Error(err.Error())
-		return 1
+		return otto.ExitCode(err)
 	}
 
 	return 0
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/otto/helper/flag"
+	"github.com/hashicorp/otto/otto"
 )
 
 // InfraCommand is the command that sets up the infrastructure for an
@@ -56,7 +57,7 @@ func (c *InfraCommand) Run(args []string) int {
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"Error occurred: %s", err))
-		return 1
+		return otto.ExitCode(err)
 	}
 
 	return 0
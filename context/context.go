@@ -15,8 +15,13 @@ type Shared struct {
 	//   App.Build
 	//   TODO
 	//
+	// Read them with Cred so that Otto knows which ones are used.
 	InfraCreds map[string]string
 
+	// CredRead, if non-nil, is called with the key of every credential
+	// read with Cred. For plugins, it is forwarded to the core over RPC.
+	CredRead func(key string)
+
 	// Ui is the Ui object that can be used to communicate with the user.
 	Ui ui.Ui
 
@@ -33,6 +38,11 @@ type Shared struct {
 	// Appfile is the full appfile
 	Appfile *appfile.File
 
+	// ProjectDir is the root directory of the project, usually where the
+	// main Appfile lives. Relative paths in the Appfile should be
+	// resolved against this rather than the working directory.
+	ProjectDir string
+
 	// FoundationDirs are the directories of the various foundation scripts.
 	//
 	// These directories will contain a "dev" and "deploy" subdirectory
@@ -40,4 +50,20 @@ type Shared struct {
 	// Within those folders, a "main.sh" file will exist that should be
 	// called.
 	FoundationDirs []string
+
+	// Vars are the variables resolved from the sources configured for
+	// Otto, such as files or the environment. They hold values that are
+	// kept out of the Appfile because they differ between environments.
+	Vars map[string]string
+
+	// Cancel, if non-nil, is closed when the user cancels the operation,
+	// such as a build started with Core.BuildAsync. Long-running work
+	// should stop as soon as possible and return an error once it is
+	// closed. This isn't available to plugins.
+	Cancel <-chan struct{}
+
+	// Env are additional environment variables that should be set for
+	// any subprocesses executed on behalf of Otto, such as Vagrant or
+	// Terraform. These take precedence over the inherited environment.
+	Env map[string]string
 }
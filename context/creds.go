@@ -0,0 +1,12 @@
+package context
+
+// Cred returns the value of the infrastructure credential with the given
+// key, or "" if there is none. Implementations should read InfraCreds
+// with this rather than directly so that the use is recorded.
+func (s *Shared) Cred(key string) string {
+	if s.CredRead != nil {
+		s.CredRead(key)
+	}
+
+	return s.InfraCreds[key]
+}
@@ -0,0 +1,29 @@
+package context
+
+import (
+	"fmt"
+)
+
+// ExitError is an error that app, infrastructure, and foundation
+// implementations can return when a subprocess they ran failed, so that
+// Otto can exit with the same exit code.
+type ExitError struct {
+	// Code is the exit code of the subprocess.
+	Code int
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+
+	return e.Err.Error()
+}
+
+func (e *ExitError) ExitCode() int { return e.Code }
+
+// errwrap.Wrapper impl.
+func (e *ExitError) WrappedErrors() []error { return []error{e.Err} }
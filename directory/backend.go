@@ -3,6 +3,8 @@ package directory
 import (
 	"io"
 	"os"
+
+	"github.com/hashicorp/otto/helper/uuid"
 )
 
 // Backend is the interface for any directory service. It is effectively
@@ -32,10 +34,14 @@ type Backend interface {
 	GetDev(*Dev) (*Dev, error)
 	DeleteDev(*Dev) error
 
-	// PutBuild stores the result of a build.
+	// PutBuild stores the result of a build. It becomes the latest
+	// build, and earlier builds are kept so they can still be queried
+	// by ID.
 	//
 	// GetBuild queries a build. The result is returned. The parameter
-	// must fill in the App, Infra, and InfraFlavor fields.
+	// must fill in the App, Infra, and InfraFlavor fields. If it also
+	// has an ID, the build with that ID is returned, which may be an
+	// earlier one. Otherwise, the latest build is returned.
 	PutBuild(*Build) error
 	GetBuild(*Build) (*Build, error)
 
@@ -47,6 +53,31 @@ type Backend interface {
 	GetDeploy(*Deploy) (*Deploy, error)
 }
 
+// BuildLister is an optional interface that a Backend can implement to
+// list every build it keeps for the Lookup of the given build, including
+// the latest one, in no particular order. Snapshot uses it to include
+// the earlier builds that PutBuild keeps.
+type BuildLister interface {
+	ListBuilds(*Build) ([]*Build, error)
+}
+
+// Pinger is an optional interface that a Backend can implement to report
+// whether or not it is currently reachable. Backends that don't implement
+// this are assumed to always be available.
+type Pinger interface {
+	Ping() error
+}
+
+// Ping checks that the given backend is available. If the backend doesn't
+// implement Pinger, this always returns nil.
+func Ping(b Backend) error {
+	if p, ok := b.(Pinger); ok {
+		return p.Ping()
+	}
+
+	return nil
+}
+
 // Build represents a build of an App.
 type Build struct {
 	// Lookup information for the Build. AppID, Infra, and InfraFlavor
@@ -55,6 +86,19 @@ type Build struct {
 
 	// Resulting artifact from the build
 	Artifact map[string]string
+
+	// Signature is the signature of the build, if builds are signed.
+	// It is set by Otto after the build completes, not by the app.
+	Signature []byte
+
+	// Private fields. These are usually set on Get or Put.
+	//
+	// DO NOT MODIFY THESE.
+	ID string
+}
+
+func (b *Build) setId() {
+	b.ID = uuid.GenerateUUID()
 }
 
 // BlobData is the metadata and data associated with stored binary
@@ -42,6 +42,16 @@ type BoltBackend struct {
 	Dir string
 }
 
+// Ping implements Pinger. It verifies the database can be opened.
+func (b *BoltBackend) Ping() error {
+	db, err := b.db()
+	if err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
 func (b *BoltBackend) GetBlob(k string) (*BlobData, error) {
 	db, err := b.db()
 	if err != nil {
@@ -248,20 +258,36 @@ func (b *BoltBackend) GetBuild(build *Build) (*Build, error) {
 		}
 
 		// Get the infra bucket
-		bucket = bucket.Bucket([]byte(fmt.Sprintf(
-			"%s-%s", build.Lookup.Infra, build.Lookup.InfraFlavor)))
+		bucket = bucket.Bucket([]byte(b.buildKey(build)))
 		if bucket == nil {
 			return nil
 		}
 
-		// Get the key for this infra
-		data := bucket.Get([]byte("build"))
+		// Get the requested build from the history if there is one.
+		// Builds stored before the history was kept are only found if
+		// they are the latest.
+		var data []byte
+		if build.ID != "" {
+			if history := bucket.Bucket([]byte("builds")); history != nil {
+				data = history.Get([]byte(build.ID))
+			}
+		}
+		if data == nil {
+			data = bucket.Get([]byte("build"))
+		}
 		if data == nil {
 			return nil
 		}
 
 		result = &Build{}
-		return b.structRead(result, data)
+		if err := b.structRead(result, data); err != nil {
+			return err
+		}
+		if build.ID != "" && result.ID != build.ID {
+			result = nil
+		}
+
+		return nil
 	})
 	if err != nil {
 		return nil, err
@@ -271,6 +297,10 @@ func (b *BoltBackend) GetBuild(build *Build) (*Build, error) {
 }
 
 func (b *BoltBackend) PutBuild(build *Build) error {
+	if build.ID == "" {
+		build.setId()
+	}
+
 	db, err := b.db()
 	if err != nil {
 		return err
@@ -292,16 +322,80 @@ func (b *BoltBackend) PutBuild(build *Build) error {
 		}
 
 		// Get the infra bucket
-		bucket, err = bucket.CreateBucketIfNotExists([]byte(fmt.Sprintf(
-			"%s-%s", build.Lookup.Infra, build.Lookup.InfraFlavor)))
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(b.buildKey(build)))
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Put([]byte("build"), data); err != nil {
+			return err
+		}
+
+		// Keep every build by ID so that earlier ones can be queried
+		history, err := bucket.CreateBucketIfNotExists([]byte("builds"))
 		if err != nil {
 			return err
 		}
 
-		return bucket.Put([]byte("build"), data)
+		return history.Put([]byte(build.ID), data)
 	})
 }
 
+// ListBuilds implements BuildLister.
+func (b *BoltBackend) ListBuilds(build *Build) ([]*Build, error) {
+	db, err := b.db()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var result []*Build
+	err = db.View(func(tx *bolt.Tx) error {
+		// Get the app bucket
+		bucket := tx.Bucket(boltAppsBucket).Bucket([]byte(
+			build.Lookup.AppID))
+		if bucket == nil {
+			return nil
+		}
+
+		// Get the infra bucket
+		bucket = bucket.Bucket([]byte(b.buildKey(build)))
+		if bucket == nil {
+			return nil
+		}
+
+		// Builds stored before the history was kept only have the latest
+		history := bucket.Bucket([]byte("builds"))
+		if history == nil {
+			data := bucket.Get([]byte("build"))
+			if data == nil {
+				return nil
+			}
+
+			var latest Build
+			if err := b.structRead(&latest, data); err != nil {
+				return err
+			}
+			result = append(result, &latest)
+			return nil
+		}
+
+		return history.ForEach(func(k, v []byte) error {
+			var item Build
+			if err := b.structRead(&item, v); err != nil {
+				return err
+			}
+			result = append(result, &item)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 func (b *BoltBackend) GetDeploy(deploy *Deploy) (*Deploy, error) {
 	db, err := b.db()
 	if err != nil {
@@ -377,6 +471,15 @@ func (b *BoltBackend) PutDeploy(deploy *Deploy) error {
 	})
 }
 
+func (b *BoltBackend) buildKey(build *Build) string {
+	key := fmt.Sprintf("%s-%s", build.Lookup.Infra, build.Lookup.InfraFlavor)
+	if build.Lookup.InfraName != "" {
+		key = fmt.Sprintf("%s-name-%s", key, build.Lookup.InfraName)
+	}
+
+	return key
+}
+
 func (b *BoltBackend) infraKey(infra *Infra) string {
 	key := "root"
 	if infra.Lookup.Foundation != "" {
@@ -9,4 +9,9 @@ type Lookup struct {
 	Infra       string // Infra is the infra type, i.e. "aws"
 	InfraFlavor string // InfraFlavor is the flavor, i.e. "vpc-public-private"
 	Foundation  string // Foundation is the name of he foundation, i.e. "consul"
+
+	// InfraName, if set, scopes a build to the infrastructure with this
+	// name in the Appfile. Builds are otherwise shared by all the
+	// infrastructures of the same type and flavor.
+	InfraName string
 }
@@ -181,6 +181,98 @@ func TestBackend(t *testing.T, b Backend) {
 		return
 	}
 
+	//---------------------------------------------------------------
+	// Build
+	//---------------------------------------------------------------
+
+	// GetBuild (doesn't exist)
+	lookup := Lookup{AppID: "foo", Infra: "bar", InfraFlavor: "baz"}
+	buildResult, err := b.GetBuild(&Build{Lookup: lookup})
+	if err != nil {
+		t.Errorf("GetBuild (non-exist) error: %s", err)
+		return
+	}
+	if buildResult != nil {
+		t.Error("GetBuild (non-exist): result should be nil")
+		return
+	}
+
+	// PutBuild twice
+	first := &Build{Lookup: lookup, Artifact: map[string]string{"v": "1"}}
+	if err := b.PutBuild(first); err != nil {
+		t.Errorf("PutBuild err: %s", err)
+		return
+	}
+	second := &Build{Lookup: lookup, Artifact: map[string]string{"v": "2"}}
+	if err := b.PutBuild(second); err != nil {
+		t.Errorf("PutBuild err: %s", err)
+		return
+	}
+	if first.ID == "" || second.ID == "" || first.ID == second.ID {
+		t.Errorf("PutBuild: bad IDs: %q %q", first.ID, second.ID)
+		return
+	}
+
+	// GetBuild (latest)
+	buildResult, err = b.GetBuild(&Build{Lookup: lookup})
+	if err != nil {
+		t.Errorf("GetBuild (latest) error: %s", err)
+		return
+	}
+	if !reflect.DeepEqual(buildResult, second) {
+		t.Errorf("GetBuild (latest) bad: %#v", buildResult)
+		return
+	}
+
+	// GetBuild (earlier, by ID)
+	buildResult, err = b.GetBuild(&Build{Lookup: lookup, ID: first.ID})
+	if err != nil {
+		t.Errorf("GetBuild (by ID) error: %s", err)
+		return
+	}
+	if !reflect.DeepEqual(buildResult, first) {
+		t.Errorf("GetBuild (by ID) bad: %#v", buildResult)
+		return
+	}
+
+	// GetBuild (unknown ID)
+	buildResult, err = b.GetBuild(&Build{Lookup: lookup, ID: "unknown"})
+	if err != nil {
+		t.Errorf("GetBuild (unknown ID) error: %s", err)
+		return
+	}
+	if buildResult != nil {
+		t.Error("GetBuild (unknown ID): result should be nil")
+		return
+	}
+
+	// ListBuilds, if the backend implements it
+	if lister, ok := b.(BuildLister); ok {
+		builds, err := lister.ListBuilds(&Build{Lookup: lookup})
+		if err != nil {
+			t.Errorf("ListBuilds error: %s", err)
+			return
+		}
+		ids := make(map[string]bool)
+		for _, build := range builds {
+			ids[build.ID] = true
+		}
+		if len(builds) != 2 || !ids[first.ID] || !ids[second.ID] {
+			t.Errorf("ListBuilds bad: %#v", builds)
+			return
+		}
+
+		builds, err = lister.ListBuilds(&Build{Lookup: Lookup{AppID: "unknown"}})
+		if err != nil {
+			t.Errorf("ListBuilds (non-exist) error: %s", err)
+			return
+		}
+		if len(builds) != 0 {
+			t.Errorf("ListBuilds (non-exist) bad: %#v", builds)
+			return
+		}
+	}
+
 	//---------------------------------------------------------------
 	// Dev
 	//---------------------------------------------------------------
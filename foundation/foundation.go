@@ -61,6 +61,7 @@ type Context struct {
 }
 
 // CompileResult is the structure containing compilation result values.
-//
-// This is empty now but may be used in the future.
-type CompileResult struct{}
+type CompileResult struct {
+	// Warnings are reported to the user without failing the compilation.
+	Warnings []string `json:"warnings"`
+}
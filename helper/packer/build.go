@@ -76,9 +76,6 @@ func Build(ctx *app.Context, opts *BuildOptions) error {
 
 		vars[k] = v
 	}
-	for k, v := range ctx.InfraCreds {
-		vars[k] = v
-	}
 
 	// Setup the vars
 	if err := foundation.WriteVars(&ctx.Shared); err != nil {
@@ -113,6 +110,18 @@ func Build(ctx *app.Context, opts *BuildOptions) error {
 		templatePath = filepath.Join(packerDir, "template.json")
 	}
 
+	// Only pass the credentials that the template declares, so that the
+	// others aren't recorded as used.
+	declared, err := DeclaredVars(templatePath)
+	if err != nil {
+		return fmt.Errorf("Error reading Packer template: %s", err)
+	}
+	for k := range ctx.InfraCreds {
+		if _, ok := declared[k]; ok {
+			vars[k] = ctx.Cred(k)
+		}
+	}
+
 	ctx.Ui.Header("Building deployment artifact with Packer...")
 	ctx.Ui.Message(
 		"Raw Packer output will begin streaming in below. Otto\n" +
@@ -0,0 +1,33 @@
+package packer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DeclaredVars returns the names of the user variables declared by the
+// Packer template at path. This is used to pass only the infrastructure
+// credentials that the template uses, so that Otto knows which ones are
+// needed.
+func DeclaredVars(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var template struct {
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(f).Decode(&template); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", path, err)
+	}
+
+	result := make(map[string]struct{}, len(template.Variables))
+	for k := range template.Variables {
+		result[k] = struct{}{}
+	}
+
+	return result, nil
+}
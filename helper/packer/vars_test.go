@@ -0,0 +1,22 @@
+package packer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeclaredVars(t *testing.T) {
+	actual, err := DeclaredVars(filepath.Join("./test-fixtures", "template-vars.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]struct{}{
+		"aws_access_key": struct{}{},
+		"aws_region":     struct{}{},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
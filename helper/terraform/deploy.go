@@ -119,6 +119,7 @@ func (opts *DeployOptions) actionDeploy(rctx router.Context) error {
 		Path:      project.Path(),
 		Dir:       opts.tfDir(ctx),
 		Ui:        ctx.Ui,
+		Env:       ctx.Env,
 		Variables: vars,
 		Directory: ctx.Directory,
 		StateId:   deploy.ID,
@@ -191,6 +192,7 @@ func (opts *DeployOptions) actionDestroy(rctx router.Context) error {
 		Path:      project.Path(),
 		Dir:       opts.tfDir(ctx),
 		Ui:        ctx.Ui,
+		Env:       ctx.Env,
 		Variables: vars,
 		Directory: ctx.Directory,
 		StateId:   deploy.ID,
@@ -236,6 +238,7 @@ func (opts *DeployOptions) actionInfo(rctx router.Context) error {
 		Path:      project.Path(),
 		Dir:       opts.tfDir(ctx),
 		Ui:        ctx.Ui,
+		Env:       ctx.Env,
 		Directory: ctx.Directory,
 		StateId:   deploy.ID,
 	}
@@ -275,8 +278,18 @@ func (opts *DeployOptions) lookupInfraVars(
 		}
 		vars[k] = v
 	}
-	for k, v := range ctx.InfraCreds {
-		vars[k] = v
+
+	// Only pass the credentials that the configuration declares, so
+	// that the others aren't recorded as used.
+	declared, err := DeclaredVars(opts.tfDir(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			"Error reading Terraform configuration: %s", err)
+	}
+	for k := range ctx.InfraCreds {
+		if _, ok := declared[k]; ok {
+			vars[k] = ctx.Cred(k)
+		}
 	}
 	return infra, vars, nil
 }
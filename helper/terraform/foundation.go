@@ -98,9 +98,6 @@ func (f *Foundation) execute(ctx *foundation.Context, args ...string) error {
 	for k, v := range infra.Outputs {
 		vars[k] = v
 	}
-	for k, v := range ctx.InfraCreds {
-		vars[k] = v
-	}
 
 	// Get the directory
 	tfDir := f.Dir
@@ -108,11 +105,24 @@ func (f *Foundation) execute(ctx *foundation.Context, args ...string) error {
 		tfDir = filepath.Join(ctx.Dir, "deploy")
 	}
 
+	// Only pass the credentials that the configuration declares, so
+	// that the others aren't recorded as used.
+	declared, err := DeclaredVars(tfDir)
+	if err != nil {
+		return fmt.Errorf("Error reading Terraform configuration: %s", err)
+	}
+	for k := range ctx.InfraCreds {
+		if _, ok := declared[k]; ok {
+			vars[k] = ctx.Cred(k)
+		}
+	}
+
 	// Run Terraform!
 	tf := &Terraform{
 		Path:      project.Path(),
 		Dir:       tfDir,
 		Ui:        ctx.Ui,
+		Env:       ctx.Env,
 		Variables: vars,
 		Directory: ctx.Directory,
 		StateId:   foundationInfra.ID,
@@ -106,6 +106,7 @@ func (i *Infrastructure) actionInfo(rctx router.Context) error {
 		Path:      project.Path(),
 		Dir:       ctx.Dir,
 		Ui:        ctx.Ui,
+		Env:       ctx.Env,
 		Directory: ctx.Directory,
 		StateId:   infra.ID,
 	}
@@ -126,10 +127,17 @@ func (i *Infrastructure) execute(ctx *infrastructure.Context, command ...string)
 		return err
 	}
 
-	// Build the variables
+	// Build the variables. Only the credentials that the configuration
+	// declares are passed, so that the others aren't recorded as used.
+	declared, err := DeclaredVars(ctx.Dir)
+	if err != nil {
+		return fmt.Errorf("Error reading Terraform configuration: %s", err)
+	}
 	vars := make(map[string]string)
-	for k, v := range ctx.InfraCreds {
-		vars[k] = v
+	for k := range ctx.InfraCreds {
+		if _, ok := declared[k]; ok {
+			vars[k] = ctx.Cred(k)
+		}
 	}
 	for k, v := range i.Variables {
 		vars[k] = v
@@ -168,6 +176,7 @@ func (i *Infrastructure) execute(ctx *infrastructure.Context, command ...string)
 		Path:      project.Path(),
 		Dir:       ctx.Dir,
 		Ui:        ctx.Ui,
+		Env:       ctx.Env,
 		Variables: vars,
 		Directory: ctx.Directory,
 		StateId:   infra.ID,
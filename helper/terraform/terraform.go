@@ -53,6 +53,10 @@ type Terraform struct {
 	// Variables is a list of variables to pass to Terraform.
 	Variables map[string]string
 
+	// Env is a set of additional environment variables to set when
+	// executing Terraform. These override the inherited environment.
+	Env map[string]string
+
 	// Directory can be set to point to a directory where data can be
 	// stored. If this is set, then the state will be loaded/stored here
 	// automatically.
@@ -159,6 +163,14 @@ func (t *Terraform) Execute(commandRaw ...string) error {
 	}
 	cmd := exec.Command(path, command...)
 	cmd.Dir = t.Dir
+	if len(t.Env) > 0 {
+		env := os.Environ()
+		for k, v := range t.Env {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+
+		cmd.Env = env
+	}
 
 	// Start the Terraform command. If there is an error we just store
 	// the error but can't exit yet because we have to store partial
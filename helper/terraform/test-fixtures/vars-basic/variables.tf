@@ -0,0 +1,5 @@
+variable "aws_access_key" {
+    description = "Access key for AWS"
+}
+
+variable "aws_region" {}
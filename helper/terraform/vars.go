@@ -0,0 +1,57 @@
+package terraform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// DeclaredVars returns the names of the variables declared by the
+// Terraform configuration in dir. Only the files in dir itself are read,
+// since those are the variables that can be set when running Terraform
+// there. This is used to pass only the infrastructure credentials that
+// the configuration uses, so that Otto knows which ones are needed.
+func DeclaredVars(dir string) (map[string]struct{}, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]struct{})
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() ||
+			(!strings.HasSuffix(name, ".tf") && !strings.HasSuffix(name, ".tf.json")) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		root, err := hcl.Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s: %s", path, err)
+		}
+		list, ok := root.Node.(*ast.ObjectList)
+		if !ok {
+			return nil, fmt.Errorf("Error parsing %s: no root object", path)
+		}
+
+		for _, item := range list.Filter("variable").Items {
+			if len(item.Keys) == 0 {
+				continue
+			}
+			if v, ok := item.Keys[0].Token.Value().(string); ok {
+				result[v] = struct{}{}
+			}
+		}
+	}
+
+	return result, nil
+}
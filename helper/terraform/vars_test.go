@@ -0,0 +1,23 @@
+package terraform
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeclaredVars(t *testing.T) {
+	actual, err := DeclaredVars(filepath.Join("./test-fixtures", "vars-basic"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]struct{}{
+		"aws_access_key": struct{}{},
+		"aws_region":     struct{}{},
+		"ami":            struct{}{},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
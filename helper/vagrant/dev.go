@@ -101,6 +101,14 @@ func (opts *DevOptions) Vagrant(ctx *app.Context) *Vagrant {
 		Ui:      ctx.Ui,
 	}
 
+	// Copy the environment since Vagrant modifies it on execution
+	if len(ctx.Env) > 0 {
+		result.Env = make(map[string]string)
+		for k, v := range ctx.Env {
+			result.Env[k] = v
+		}
+	}
+
 	// If we have a layered environment we want to configure every environment
 	// with the layer information so that we can call arbitrary commands.
 	if opts.Layer != nil {
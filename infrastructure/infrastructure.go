@@ -3,6 +3,7 @@ package infrastructure
 import (
 	"github.com/hashicorp/otto/appfile"
 	"github.com/hashicorp/otto/context"
+	"github.com/hashicorp/otto/directory"
 	"github.com/hashicorp/otto/ui"
 )
 
@@ -26,6 +27,145 @@ type Infrastructure interface {
 	Flavors() []string
 }
 
+// CredsRequirer is an optional interface that an Infrastructure can
+// implement to declare the keys that must be present in its credentials.
+// If cached credentials are missing some of these keys, for example
+// because a newer version of the infrastructure requires more, Otto
+// asks the user for only the missing keys and merges them in.
+type CredsRequirer interface {
+	RequiredCreds() []string
+}
+
+// CredsDescriber is an optional interface that an Infrastructure can
+// implement to describe the keys of its credentials. Otto uses this to
+// ask for missing values with a description, to validate loaded
+// credentials, and to redact secret values from its output. This takes
+// precedence over CredsRequirer.
+type CredsDescriber interface {
+	CredsSchema() []CredField
+}
+
+// CredField describes a single key of the credentials.
+type CredField struct {
+	// Key is the key in the credentials map.
+	Key string
+
+	// Description is a human-friendly description of the value, shown
+	// when the user is asked for it.
+	Description string
+
+	// Secret is true if the value must not be shown, such as a secret
+	// access key. It is hidden as it is typed and redacted from output.
+	Secret bool
+
+	// Required is true if the value must be present and non-empty.
+	Required bool
+}
+
+// Identifier is an optional interface that an Infrastructure can
+// implement to report the identity that the loaded credentials
+// (Context.InfraCreds) belong to, such as the account ID, project, or
+// subscription, so that the user can confirm that they are about to
+// operate on the right one.
+type Identifier interface {
+	Identity(*Context) (string, error)
+}
+
+// Importer is an optional interface that an Infrastructure can implement
+// to adopt infrastructure that already exists but wasn't created by Otto.
+type Importer interface {
+	// Import is given the identifiers of the existing resources (the
+	// keys and values are specific to each infrastructure type) and
+	// should return the directory record that represents them. Otto
+	// will fill in the lookup information and store the record.
+	Import(ctx *Context, ids map[string]string) (*directory.Infra, error)
+}
+
+// Outputter is an optional interface that an Infrastructure can
+// implement to report its outputs by querying them directly rather than
+// using the outputs stored in the directory.
+type Outputter interface {
+	Outputs(*Context) (map[string]string, error)
+}
+
+// Promoter is an optional interface that an Infrastructure can implement
+// to accept build artifacts that were built for another infrastructure,
+// so that an artifact can be built once and deployed to several
+// environments. Context.InfraCreds are the credentials of this
+// infrastructure. Only the active infrastructure is compiled, so
+// Context.Dir is empty.
+type Promoter interface {
+	// Promote is given a build of another infrastructure and should make
+	// its artifact available to this one, such as by copying an image
+	// to another account or region. It returns the artifact as this
+	// infrastructure knows it, which Otto stores as a build for it.
+	Promote(ctx *Context, build *directory.Build) (map[string]string, error)
+}
+
+// CostEstimator is an optional interface that an Infrastructure can
+// implement to estimate what it will cost to run before it is built.
+// Context.Dir is the compiled directory of the infrastructure and
+// Context.Infra its configuration, including the flavor to estimate.
+type CostEstimator interface {
+	EstimateCost(*Context) (*CostEstimate, error)
+}
+
+// CostEstimate is the estimated monthly cost of an infrastructure,
+// broken down into the resources that it creates.
+type CostEstimate struct {
+	// Currency is the ISO 4217 code of the currency of the costs, such
+	// as "USD".
+	Currency string
+
+	// LineItems are the resources that make up the cost.
+	LineItems []*CostLineItem
+}
+
+// CostLineItem is the estimated cost of a single kind of resource.
+type CostLineItem struct {
+	// Name is the name of the resource, such as "NAT gateway", and
+	// Quantity is how many of it are created.
+	Name     string
+	Quantity int
+
+	// MonthlyCost is the total monthly cost of all of them.
+	MonthlyCost float64
+}
+
+// Total returns the sum of the monthly costs of all the line items.
+func (e *CostEstimate) Total() float64 {
+	var result float64
+	for _, item := range e.LineItems {
+		result += item.MonthlyCost
+	}
+
+	return result
+}
+
+// ActionLister is an optional interface that an Infrastructure can
+// implement to declare the actions that Execute supports. If it is
+// implemented, Otto validates the requested action and its arguments
+// before calling Execute and shows the available actions otherwise.
+type ActionLister interface {
+	Actions() []Action
+}
+
+// Action describes an action that can be given to Execute.
+type Action struct {
+	// Name is the name of the action. The blank name is the default
+	// action, executed when no action is given.
+	Name string
+
+	// Synopsis is a short sentence describing what the action does.
+	Synopsis string
+
+	// MinArgs and MaxArgs are the bounds on the number of arguments
+	// the action accepts. If MaxArgs is negative, there is no upper
+	// bound.
+	MinArgs int
+	MaxArgs int
+}
+
 // Context is the context for operations on infrastructures. Some of
 // the fields in this struct are only available for certain operations.
 type Context struct {
@@ -47,6 +187,12 @@ type Context struct {
 	// The infrastructure configuration itself from the Appfile. This includes
 	// the flavor of the infrastructure we want to launch.
 	Infra *appfile.Infrastructure
+
+	// AppFragments are the paths to the infrastructure fragments of the
+	// apps, dependencies first and the main application last. These are
+	// only set for Compile, and only if the apps are compiled before the
+	// infrastructure.
+	AppFragments []string
 }
 
 // RouteName implements the router.Context interface so we can use Router
@@ -65,4 +211,13 @@ func (c *Context) UI() ui.Ui {
 }
 
 // CompileResult is the structure containing compilation result values.
-type CompileResult struct{}
+type CompileResult struct {
+	// Warnings are reported to the user without failing the compilation.
+	Warnings []string `json:"warnings"`
+
+	// Outputs are the names of the outputs that the compiled
+	// infrastructure provides once it is created, such as "vpc_id".
+	// Otto verifies that they include the outputs the apps require; see
+	// app.InfraOutputRequirer.
+	Outputs []string `json:"outputs,omitempty"`
+}
@@ -6,6 +6,15 @@ type Mock struct {
 	CompileContext *Context
 	CompileResult  *CompileResult
 	CompileErr     error
+
+	ExecuteCalled  bool
+	ExecuteContext *Context
+	ExecuteErr     error
+
+	VerifyCredsCalled bool
+	VerifyCredsErr    error
+
+	FlavorsResult []string
 }
 
 func (m *Mock) Creds(ctx *Context) (map[string]string, error) {
@@ -13,11 +22,14 @@ func (m *Mock) Creds(ctx *Context) (map[string]string, error) {
 }
 
 func (m *Mock) VerifyCreds(ctx *Context) error {
-	return nil
+	m.VerifyCredsCalled = true
+	return m.VerifyCredsErr
 }
 
 func (m *Mock) Execute(ctx *Context) error {
-	return nil
+	m.ExecuteCalled = true
+	m.ExecuteContext = ctx
+	return m.ExecuteErr
 }
 
 func (m *Mock) Compile(ctx *Context) (*CompileResult, error) {
@@ -27,5 +39,5 @@ func (m *Mock) Compile(ctx *Context) (*CompileResult, error) {
 }
 
 func (m *Mock) Flavors() []string {
-	return nil
+	return m.FlavorsResult
 }
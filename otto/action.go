@@ -0,0 +1,294 @@
+package otto
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+// ActionMeta describes an action that can be run with RunAction, for
+// showing help or completing the command line.
+type ActionMeta struct {
+	// Task is the command the action belongs to: "dev" for actions of
+	// the development environment of the main application and "infra"
+	// for actions of the infrastructure.
+	Task string
+
+	// Action is the name of the action within the task. The blank name
+	// is the default action of the task.
+	Action string
+
+	// Synopsis is a short sentence describing what the action does.
+	Synopsis string
+
+	// MinArgs and MaxArgs are the bounds on the number of arguments,
+	// and ArgsHint describes them for the user. If MaxArgs is negative,
+	// there is no upper bound.
+	MinArgs  int
+	MaxArgs  int
+	ArgsHint string
+}
+
+// Name is the full name of the action as given to RunAction, such as
+// "infra destroy", or just the task for its default action.
+func (m ActionMeta) Name() string {
+	if m.Action == "" {
+		return m.Task
+	}
+
+	return m.Task + " " + m.Action
+}
+
+// InfraActions returns the actions that the infrastructure accepts for
+// Infra, sorted by name. If the infrastructure doesn't declare its
+// actions with infrastructure.ActionLister, nil is returned. This has
+// no side effects.
+func (c *Core) InfraActions() ([]infrastructure.Action, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	infra, _, err := c.infra()
+	if err != nil {
+		return nil, err
+	}
+	defer maybeClose(infra)
+
+	return infraActions(infra), nil
+}
+
+// CompletableActions returns every action that can be run with
+// RunAction, sorted by name: the default dev action, the actions of the
+// main application if it declares them with app.ActionLister, and the
+// actions of the infrastructure, including "destroy" which Otto always
+// handles. This is meant for help output and shell completion, so if
+// the app or infrastructure can't be loaded, the error is logged and
+// their default actions are still returned. This has no side effects.
+func (c *Core) CompletableActions() []ActionMeta {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	result := []ActionMeta{newActionMeta(
+		"dev", "", "Start the development environment", 0, 0)}
+	if appCtx, err := c.appContext(c.appfile); err != nil {
+		log.Printf("[WARN] error loading app for actions: %s", err)
+	} else if appImpl, err := c.app(appCtx); err != nil {
+		log.Printf("[WARN] error loading app for actions: %s", err)
+	} else {
+		result = append(result, appActions(appImpl)...)
+		maybeClose(appImpl)
+	}
+
+	// The infrastructure can override the description of the actions
+	// Otto provides, but they're always available.
+	infraMetas := map[string]ActionMeta{
+		"": newActionMeta(
+			"infra", "", "Build the infrastructure", 0, 0),
+		"destroy": newActionMeta(
+			"infra", "destroy", "Destroy the infrastructure", 0, 0),
+	}
+	if infra, _, err := c.infra(); err != nil {
+		log.Printf("[WARN] error loading infrastructure for actions: %s", err)
+	} else {
+		for _, a := range infraActions(infra) {
+			infraMetas[a.Name] = newActionMeta(
+				"infra", a.Name, a.Synopsis, a.MinArgs, a.MaxArgs)
+		}
+		maybeClose(infra)
+	}
+	for _, m := range infraMetas {
+		result = append(result, m)
+	}
+
+	sort.Sort(actionMetaSlice(result))
+	return result
+}
+
+// RunAction runs the action with the given full name, as returned by
+// ActionMeta.Name, such as "dev ssh" or "infra destroy". The action is
+// dispatched to Dev, Execute, or Infra depending on its task.
+func (c *Core) RunAction(name string, args []string) error {
+	task, action := name, ""
+	if idx := strings.Index(name, " "); idx != -1 {
+		task, action = name[:idx], strings.TrimSpace(name[idx+1:])
+	}
+
+	switch task {
+	case "dev":
+		if action == "" {
+			if len(args) > 0 {
+				return fmt.Errorf(
+					"Action '%s' %s, got %d",
+					name, actionArgsText(0, 0), len(args))
+			}
+
+			return c.Dev()
+		}
+
+		return c.Execute(&ExecuteOpts{
+			Task:   ExecuteTaskDev,
+			Action: action,
+			Args:   args,
+		})
+	case "infra":
+		return c.Infra(action, args)
+	default:
+		return fmt.Errorf(
+			"Unknown action: %s\n\n"+
+				"Actions start with the command they belong to, either\n"+
+				"\"dev\" or \"infra\", such as \"infra destroy\".", name)
+	}
+}
+
+// newActionMeta returns the metadata of an action.
+func newActionMeta(task, action, synopsis string, min, max int) ActionMeta {
+	return ActionMeta{
+		Task:     task,
+		Action:   action,
+		Synopsis: synopsis,
+		MinArgs:  min,
+		MaxArgs:  max,
+		ArgsHint: actionArgsText(min, max),
+	}
+}
+
+// appActions returns the sorted actions of the app, or nil if it
+// doesn't declare them.
+func appActions(impl app.App) []ActionMeta {
+	lister, ok := impl.(app.ActionLister)
+	if !ok {
+		return nil
+	}
+
+	actions := lister.Actions()
+	result := make([]ActionMeta, len(actions))
+	for i, a := range actions {
+		result[i] = newActionMeta("dev", a.Name, a.Synopsis, a.MinArgs, a.MaxArgs)
+	}
+	sort.Sort(actionMetaSlice(result))
+	return result
+}
+
+// infraActions returns the sorted actions of the infrastructure, or nil
+// if it doesn't declare them.
+func infraActions(infra infrastructure.Infrastructure) []infrastructure.Action {
+	lister, ok := infra.(infrastructure.ActionLister)
+	if !ok {
+		return nil
+	}
+
+	actions := lister.Actions()
+	result := make([]infrastructure.Action, len(actions))
+	copy(result, actions)
+	sort.Sort(actionSlice(result))
+	return result
+}
+
+// checkAppAction verifies that the app supports the action with the
+// given number of arguments, returning a usage error if not. Apps that
+// don't declare their actions accept anything.
+func checkAppAction(impl app.App, action string, args []string) error {
+	actions := appActions(impl)
+	if actions == nil {
+		return nil
+	}
+
+	return checkAction("dev", actions, action, args)
+}
+
+// checkInfraAction verifies that the infrastructure supports the action
+// with the given number of arguments, returning a usage error if not.
+// Infrastructures that don't declare their actions accept anything.
+func checkInfraAction(
+	infra infrastructure.Infrastructure, action string, args []string) error {
+	actions := infraActions(infra)
+	if actions == nil {
+		return nil
+	}
+
+	metas := make([]ActionMeta, len(actions))
+	for i, a := range actions {
+		metas[i] = newActionMeta("infra", a.Name, a.Synopsis, a.MinArgs, a.MaxArgs)
+	}
+
+	return checkAction("infrastructure", metas, action, args)
+}
+
+// checkAction verifies that the action is one of the given sorted
+// actions and that it accepts the number of arguments. kind is used in
+// the error messages, such as "infrastructure".
+func checkAction(kind string, actions []ActionMeta, action string, args []string) error {
+	for _, a := range actions {
+		if a.Action != action {
+			continue
+		}
+
+		if len(args) < a.MinArgs || (a.MaxArgs >= 0 && len(args) > a.MaxArgs) {
+			return fmt.Errorf(
+				"%s%s action '%s' %s, got %d",
+				strings.ToUpper(kind[:1]), kind[1:],
+				actionDisplayName(action), a.ArgsHint, len(args))
+		}
+
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf,
+		"Unsupported %s action: %s\n\n"+
+			"The available actions are shown below:\n\n",
+		kind, actionDisplayName(action))
+	longest := 0
+	for _, a := range actions {
+		if n := len(actionDisplayName(a.Action)); n > longest {
+			longest = n
+		}
+	}
+	for _, a := range actions {
+		fmt.Fprintf(&buf, "    %-*s  %s\n", longest, actionDisplayName(a.Action), a.Synopsis)
+	}
+
+	return fmt.Errorf("%s", buf.String())
+}
+
+// actionDisplayName returns the name of an action for the user, which
+// shows the default action as "(default)" like the router does.
+func actionDisplayName(name string) string {
+	if name == "" {
+		return "(default)"
+	}
+
+	return name
+}
+
+// actionArgsText describes the number of arguments an action accepts.
+func actionArgsText(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("takes at least %d argument(s)", min)
+	case min == max:
+		return fmt.Sprintf("takes exactly %d argument(s)", min)
+	default:
+		return fmt.Sprintf(
+			"takes between %d and %d arguments", min, max)
+	}
+}
+
+// actionSlice is a sortable list of actions.
+type actionSlice []infrastructure.Action
+
+func (s actionSlice) Len() int           { return len(s) }
+func (s actionSlice) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s actionSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// actionMetaSlice is a sortable list of action metadata, sorted by
+// full name.
+type actionMetaSlice []ActionMeta
+
+func (s actionMetaSlice) Len() int           { return len(s) }
+func (s actionMetaSlice) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s actionMetaSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
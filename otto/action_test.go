@@ -0,0 +1,149 @@
+package otto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreInfra_actions(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+
+	infra := &testActionLister{
+		Mock: new(infrastructure.Mock),
+		List: []infrastructure.Action{
+			{Name: "ssh", Synopsis: "SSH", MinArgs: 1, MaxArgs: 1},
+			{Name: "", Synopsis: "Build", MaxArgs: 0},
+		},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	core := testCore(t, coreConfig)
+
+	actions, err := core.InfraActions()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(actions) != 2 || actions[0].Name != "" || actions[1].Name != "ssh" {
+		t.Fatalf("bad: %#v", actions)
+	}
+
+	// Unknown actions show the available ones
+	err = core.Infra("nope", nil)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "(default)") || infra.ExecuteCalled {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// Wrong number of arguments
+	if err := core.Infra("ssh", nil); err == nil || infra.ExecuteCalled {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	if err := core.Infra("ssh", []string{"foo"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.ExecuteCalled {
+		t.Fatal("Execute should be called")
+	}
+	if !reflect.DeepEqual(infra.ExecuteContext.ActionArgs, []string{"foo"}) {
+		t.Fatalf("bad: %#v", infra.ExecuteContext)
+	}
+}
+
+func TestCoreCompletableActions(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := &testAppActionLister{
+		Mock: TestApp(t, TestAppTuple, coreConfig),
+		List: []app.Action{
+			{Name: "ssh", Synopsis: "SSH into the dev environment", MaxArgs: 0},
+		},
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	infra := &testActionLister{
+		Mock: new(infrastructure.Mock),
+		List: []infrastructure.Action{
+			{Name: "restart", Synopsis: "Restart", MinArgs: 1, MaxArgs: -1},
+		},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	core := testCore(t, coreConfig)
+
+	var names []string
+	for _, a := range core.CompletableActions() {
+		names = append(names, a.Name())
+	}
+	expected := []string{"dev", "dev ssh", "infra", "infra destroy", "infra restart"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	actions := core.CompletableActions()
+	if hint := actions[4].ArgsHint; hint != "takes at least 1 argument(s)" {
+		t.Fatalf("bad: %s", hint)
+	}
+
+	// Dispatch to the app
+	if err := core.RunAction("dev ssh", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DevCalled || appMock.DevContext.Action != "ssh" {
+		t.Fatalf("bad: %#v", appMock.DevContext)
+	}
+
+	// Arguments are validated against the declared actions
+	if err := core.RunAction("dev ssh", []string{"foo"}); err == nil {
+		t.Fatal("should error")
+	}
+	if err := core.RunAction("dev nope", nil); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Dispatch to the infrastructure
+	if err := core.RunAction("infra restart", []string{"web"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.ExecuteCalled || infra.ExecuteContext.Action != "restart" {
+		t.Fatalf("bad: %#v", infra.ExecuteContext)
+	}
+
+	if err := core.RunAction("nope", nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// testActionLister is an infrastructure that declares its actions.
+type testActionLister struct {
+	*infrastructure.Mock
+
+	List []infrastructure.Action
+}
+
+func (i *testActionLister) Actions() []infrastructure.Action {
+	return i.List
+}
+
+// testAppActionLister is an app that declares its actions.
+type testAppActionLister struct {
+	*app.Mock
+
+	List []app.Action
+}
+
+func (a *testAppActionLister) Actions() []app.Action {
+	return a.List
+}
@@ -0,0 +1,242 @@
+package otto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// AppfileDiff is the difference between the compiled Appfile of the Core
+// and a candidate one, as returned by DiffAppfile.
+type AppfileDiff struct {
+	// AddedDeps and RemovedDeps are the applications that are only in the
+	// dependency graph of the candidate and only in the current one,
+	// respectively, sorted by name and ID.
+	AddedDeps   []*DepNode
+	RemovedDeps []*DepNode
+
+	// InfraChanged is true if the active infrastructure of the main
+	// application is a different one, or the same one with a different
+	// type or flavor. OldInfra and NewInfra are the infrastructures.
+	InfraChanged bool
+	OldInfra     *appfile.Infrastructure
+	NewInfra     *appfile.Infrastructure
+
+	// Recompile are the applications of the candidate that would need to
+	// be compiled again, sorted by name and ID: those that are added or
+	// changed, those whose dependencies changed, and everything that
+	// depends on them. If the infrastructure changed, this is every
+	// application.
+	Recompile []*DepNode
+}
+
+// Empty returns true if the candidate wouldn't change anything.
+func (d *AppfileDiff) Empty() bool {
+	return len(d.AddedDeps) == 0 &&
+		len(d.RemovedDeps) == 0 &&
+		!d.InfraChanged &&
+		len(d.Recompile) == 0
+}
+
+// DiffAppfile compares the compiled Appfile of the Core with newCompiled,
+// such as the result of compiling the Appfile again after it was edited,
+// to preview the impact of the change before it is made. Applications are
+// matched by Otto ID. This has no side effects.
+func (c *Core) DiffAppfile(newCompiled *appfile.Compiled) (*AppfileDiff, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if newCompiled == nil || newCompiled.File == nil || newCompiled.Graph == nil {
+		return nil, fmt.Errorf("candidate Appfile is not compiled")
+	}
+
+	oldG := c.appfileCompiled.Graph
+	newG := newCompiled.Graph
+	oldV := diffVertices(oldG)
+	newV := diffVertices(newG)
+
+	result := &AppfileDiff{
+		OldInfra: c.appfileCompiled.File.ActiveInfrastructure(),
+		NewInfra: newCompiled.File.ActiveInfrastructure(),
+	}
+	result.InfraChanged = infraChanged(result.OldInfra, result.NewInfra)
+
+	// Find the applications that are dirty themselves
+	dirty := make(map[string]struct{})
+	for id, v := range newV {
+		old, ok := oldV[id]
+		if !ok {
+			node, err := c.diffNode(v)
+			if err != nil {
+				return nil, err
+			}
+
+			result.AddedDeps = append(result.AddedDeps, node)
+			dirty[id] = struct{}{}
+			continue
+		}
+
+		if result.InfraChanged {
+			dirty[id] = struct{}{}
+			continue
+		}
+
+		changed, err := fileChanged(old.File, v.File)
+		if err != nil {
+			return nil, err
+		}
+		if changed || !sameDeps(oldG, old, newG, v) {
+			dirty[id] = struct{}{}
+		}
+	}
+	for id, v := range oldV {
+		if _, ok := newV[id]; ok {
+			continue
+		}
+
+		node, err := c.diffNode(v)
+		if err != nil {
+			return nil, err
+		}
+
+		result.RemovedDeps = append(result.RemovedDeps, node)
+	}
+
+	// Everything that depends on a dirty application is dirty too
+	queue := make([]dag.Vertex, 0, len(dirty))
+	for id := range dirty {
+		queue = append(queue, newV[id])
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, raw := range dag.AsVertexList(newG.UpEdges(current)) {
+			id := raw.(*appfile.CompiledGraphVertex).File.ID
+			if _, ok := dirty[id]; ok {
+				continue
+			}
+
+			dirty[id] = struct{}{}
+			queue = append(queue, raw)
+		}
+	}
+	for id := range dirty {
+		node, err := c.diffNode(newV[id])
+		if err != nil {
+			return nil, err
+		}
+
+		result.Recompile = append(result.Recompile, node)
+	}
+
+	sort.Sort(depNodeSlice(result.AddedDeps))
+	sort.Sort(depNodeSlice(result.RemovedDeps))
+	sort.Sort(depNodeSlice(result.Recompile))
+	return result, nil
+}
+
+func (c *Core) diffNode(v *appfile.CompiledGraphVertex) (*DepNode, error) {
+	tuple, err := c.appTuple(v.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DepNode{
+		ID:    v.File.ID,
+		Name:  dag.VertexName(v),
+		Tuple: tuple,
+	}, nil
+}
+
+// diffVertices returns the vertices of the graph by Otto ID.
+func diffVertices(g *dag.AcyclicGraph) map[string]*appfile.CompiledGraphVertex {
+	result := make(map[string]*appfile.CompiledGraphVertex)
+	for _, raw := range g.Vertices() {
+		v := raw.(*appfile.CompiledGraphVertex)
+		result[v.File.ID] = v
+	}
+
+	return result
+}
+
+// sameDeps returns true if the application depends on the same Otto IDs
+// in both graphs.
+func sameDeps(oldG *dag.AcyclicGraph, oldV dag.Vertex, newG *dag.AcyclicGraph, newV dag.Vertex) bool {
+	ids := func(g *dag.AcyclicGraph, v dag.Vertex) map[string]struct{} {
+		result := make(map[string]struct{})
+		for _, raw := range dag.AsVertexList(g.DownEdges(v)) {
+			result[raw.(*appfile.CompiledGraphVertex).File.ID] = struct{}{}
+		}
+
+		return result
+	}
+
+	oldIDs := ids(oldG, oldV)
+	newIDs := ids(newG, newV)
+	if len(oldIDs) != len(newIDs) {
+		return false
+	}
+	for id := range newIDs {
+		if _, ok := oldIDs[id]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// appfileContents is what an Appfile configures, leaving out its ID and
+// where it was loaded from.
+type appfileContents struct {
+	Application    *appfile.Application      `json:"application"`
+	Project        *appfile.Project          `json:"project"`
+	Infrastructure []*appfile.Infrastructure `json:"infrastructure"`
+	Customization  []*appfile.Customization  `json:"customization"`
+	Imports        []*appfile.Import         `json:"imports"`
+}
+
+func contentsOf(f *appfile.File) *appfileContents {
+	result := &appfileContents{
+		Application:    f.Application,
+		Project:        f.Project,
+		Infrastructure: f.Infrastructure,
+		Imports:        f.Imports,
+	}
+	if f.Customization != nil {
+		result.Customization = f.Customization.Raw
+	}
+
+	return result
+}
+
+// fileChanged returns true if the contents of the Appfiles are different.
+// They are compared as JSON, which sorts the keys of the customizations
+// and encodes numbers the same way whether the Appfile was parsed or
+// loaded from the compiled JSON, so where the Appfiles were loaded from
+// and how they were formatted doesn't matter.
+func fileChanged(old, new *appfile.File) (bool, error) {
+	oldData, err := json.Marshal(contentsOf(old))
+	if err != nil {
+		return false, fmt.Errorf("Error encoding Appfile %s: %s", old.Path, err)
+	}
+	newData, err := json.Marshal(contentsOf(new))
+	if err != nil {
+		return false, fmt.Errorf("Error encoding Appfile %s: %s", new.Path, err)
+	}
+
+	return !bytes.Equal(oldData, newData), nil
+}
+
+func infraChanged(old, new *appfile.Infrastructure) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+
+	return old.Name != new.Name ||
+		old.Type != new.Type ||
+		old.Flavor != new.Flavor
+}
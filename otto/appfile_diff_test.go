@@ -0,0 +1,116 @@
+package otto
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/otto/appfile"
+)
+
+func TestCoreDiffAppfile(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	// The same Appfile compiled again
+	candidate := TestAppfile(t, testPath("deps", "Appfile"))
+	diff, err := core.DiffAppfile(candidate)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !diff.Empty() {
+		t.Fatalf("bad: %#v", diff)
+	}
+
+	// A changed dependency is recompiled along with what depends on it
+	child, err := findVertex(candidate, "child")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	child.File.Application.Name = "child2"
+	diff, err = core.DiffAppfile(candidate)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if diff.InfraChanged || len(diff.AddedDeps) != 0 || len(diff.RemovedDeps) != 0 {
+		t.Fatalf("bad: %#v", diff)
+	}
+	if len(diff.Recompile) != 2 {
+		t.Fatalf("bad: %#v", diff.Recompile)
+	}
+
+	// A removed dependency
+	candidate = TestAppfile(t, testPath("deps", "Appfile"))
+	child, err = findVertex(candidate, "child")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	candidate.Graph.Remove(child)
+	diff, err = core.DiffAppfile(candidate)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(diff.RemovedDeps) != 1 || diff.RemovedDeps[0].Name != "child" {
+		t.Fatalf("bad: %#v", diff.RemovedDeps)
+	}
+	if len(diff.Recompile) != 1 || diff.Recompile[0].Name != "root" {
+		t.Fatalf("bad: %#v", diff.Recompile)
+	}
+
+	// A changed flavor recompiles everything
+	candidate = TestAppfile(t, testPath("deps", "Appfile"))
+	candidate.File.ActiveInfrastructure().Flavor = "other"
+	diff, err = core.DiffAppfile(candidate)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !diff.InfraChanged || diff.NewInfra.Flavor != "other" {
+		t.Fatalf("bad: %#v", diff)
+	}
+	if len(diff.Recompile) != 2 {
+		t.Fatalf("bad: %#v", diff.Recompile)
+	}
+}
+
+func TestCoreDiffAppfile_customization(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("customization-multi", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	// The customizations are maps, so make sure their order doesn't
+	// matter, and that numbers loaded from the compiled JSON are the same.
+	data, err := json.Marshal(coreConfig.Appfile)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	loaded := new(appfile.Compiled)
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	candidates := []*appfile.Compiled{
+		TestAppfile(t, testPath("customization-multi", "Appfile")),
+		loaded,
+	}
+	for _, candidate := range candidates {
+		for i := 0; i < 20; i++ {
+			diff, err := core.DiffAppfile(candidate)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !diff.Empty() {
+				t.Fatalf("bad: %#v", diff)
+			}
+		}
+	}
+
+	// A changed customization is recompiled
+	candidate := TestAppfile(t, testPath("customization-multi", "Appfile"))
+	candidate.File.Customization.Raw[0].Config["c"] = 4
+	diff, err := core.DiffAppfile(candidate)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(diff.Recompile) != 1 {
+		t.Fatalf("bad: %#v", diff.Recompile)
+	}
+}
@@ -0,0 +1,214 @@
+package otto
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CompileArchive compiles the Appfile, like Compile, and then writes a
+// gzipped tar archive of the compiled output to w. The paths in the
+// archive are relative to the compile directory, so the app, dep, infra
+// and foundation directories are preserved.
+//
+// The archive is deterministic: entries are sorted by path and
+// modification times and ownership are zeroed, so compiling the same
+// Appfile twice results in identical archives.
+//
+// If a CompileStore is configured, the compiled files are materialized
+// first so that the archive contains them rather than the references to
+// the store.
+func (c *Core) CompileArchive(w io.Writer) error {
+	if err := c.Compile(); err != nil {
+		return err
+	}
+
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
+	if err := writeArchive(w, c.compileDir); err != nil {
+		return fmt.Errorf("Error archiving compiled output: %s", err)
+	}
+
+	return nil
+}
+
+// writeArchive writes a deterministic gzipped tar archive of dir to w.
+func writeArchive(w io.Writer, dir string) error {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if path == filepath.Join(dir, compileTreeFilename) {
+			return nil
+		}
+
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	gzipW := gzip.NewWriter(w)
+	tarW := tar.NewWriter(gzipW)
+	for _, path := range paths {
+		if err := writeArchiveEntry(tarW, dir, path); err != nil {
+			return err
+		}
+	}
+
+	if err := tarW.Close(); err != nil {
+		return err
+	}
+
+	return gzipW.Close()
+}
+
+func writeArchiveEntry(w *tar.Writer, dir, path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(rel)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	header.ModTime = time.Unix(0, 0)
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = ""
+	header.Gname = ""
+
+	if err := w.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// extractArchive extracts a gzipped tar archive written by writeArchive
+// into dir. Entries that would end up outside of dir are rejected.
+func extractArchive(r io.Reader, dir string) error {
+	gzipR, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzipR.Close()
+
+	tarR := tar.NewReader(gzipR)
+	for {
+		header, err := tarR.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !archivePathWithin(dir, path) {
+			return fmt.Errorf("archive entry outside directory: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractArchiveFile(tarR, path, header.FileInfo().Mode()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			target := header.Linkname
+			if filepath.IsAbs(target) ||
+				!archivePathWithin(dir, filepath.Join(filepath.Dir(path), target)) {
+				return fmt.Errorf(
+					"archive entry links outside directory: %s", header.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			os.Remove(path)
+			if err := os.Symlink(target, path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf(
+				"unsupported archive entry type for %s: %c",
+				header.Name, header.Typeflag)
+		}
+	}
+}
+
+func extractArchiveFile(r io.Reader, path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// archivePathWithin returns true if path is dir or within it.
+func archivePathWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,176 @@
+package otto
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreCompileArchive(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(filepath.Join(ctx.Dir, "sub"), 0755); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(ctx.Dir, "sub", "foo.txt")
+		return nil, ioutil.WriteFile(path, []byte("foo"), 0644)
+	}
+
+	var first bytes.Buffer
+	if err := core.CompileArchive(&first); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Wait so that modification times would differ
+	time.Sleep(1100 * time.Millisecond)
+
+	var second bytes.Buffer
+	if err := core.CompileArchive(&second); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatal("archives should be identical")
+	}
+
+	// Read the archive back
+	gzipR, err := gzip.NewReader(&first)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tarR := tar.NewReader(gzipR)
+
+	var names []string
+	contents := make(map[string]string)
+	for {
+		header, err := tarR.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !header.ModTime.Equal(time.Unix(0, 0)) {
+			t.Fatalf("bad: %s", header.ModTime)
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tarR); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		names = append(names, header.Name)
+		contents[header.Name] = buf.String()
+	}
+
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Fatalf("entries should be sorted: %#v", names)
+		}
+	}
+	if v := contents["app/sub/foo.txt"]; v != "foo" {
+		t.Fatalf("bad: %#v", names)
+	}
+	if _, ok := contents["metadata.json"]; !ok {
+		t.Fatalf("bad: %#v", names)
+	}
+}
+
+func TestCoreCompileArchive_store(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.CompileStore = &DirCompileStore{Path: storeDir}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(ctx.Dir, "foo.txt")
+		return nil, ioutil.WriteFile(path, []byte("foo"), 0644)
+	}
+
+	var buf bytes.Buffer
+	if err := core.CompileArchive(&buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The archive has the compiled files, not the references to them
+	gzipR, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tarR := tar.NewReader(gzipR)
+	contents := make(map[string]string)
+	for {
+		header, err := tarR.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var data bytes.Buffer
+		if _, err := io.Copy(&data, tarR); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		contents[header.Name] = data.String()
+	}
+	if v := contents["app/foo.txt"]; v != "foo" {
+		t.Fatalf("bad: %#v", contents)
+	}
+	if _, ok := contents[compileTreeFilename]; ok {
+		t.Fatalf("bad: %#v", contents)
+	}
+}
+
+func TestExtractArchive_outside(t *testing.T) {
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var buf bytes.Buffer
+	gzipW := gzip.NewWriter(&buf)
+	tarW := tar.NewWriter(gzipW)
+	err = tarW.WriteHeader(&tar.Header{
+		Name:     "../escape",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tarW.Close()
+	gzipW.Close()
+
+	dir := filepath.Join(td, "dir")
+	if err := extractArchive(&buf, dir); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(filepath.Join(td, "escape")); err == nil {
+		t.Fatal("file should not be extracted")
+	}
+}
@@ -0,0 +1,139 @@
+package otto
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// The results of an AuditEntry.
+const (
+	AuditResultSuccess = "success"
+	AuditResultError   = "error"
+)
+
+// AuditEntry is the record of a single operation that changed the
+// deployed artifacts or the infrastructure, stored in the directory
+// backend so teams have a trail of who did what through Otto.
+type AuditEntry struct {
+	// Time is when the operation finished.
+	Time time.Time `json:"time"`
+
+	// Actor is who ran the operation; see CoreConfig.Actor.
+	Actor string `json:"actor"`
+
+	// Operation is the operation that ran, such as "build", "deploy",
+	// or "infra", and Action is its sub-action, such as "destroy".
+	Operation string `json:"operation"`
+	Action    string `json:"action,omitempty"`
+
+	// AppID is the Otto ID of the main application, and Infra is the
+	// name of the active infrastructure.
+	AppID string `json:"app_id"`
+	Infra string `json:"infra"`
+
+	// Artifact is the artifact of the latest build, if there is one,
+	// at the end of a build or deploy.
+	Artifact map[string]string `json:"artifact,omitempty"`
+
+	// Result is one of the AuditResult constants, and Error is the
+	// error message if the operation failed.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AuditLog returns the audit entries of this Appfile stored in the
+// directory, oldest first. Build, Deploy, and Infra add an entry each
+// time they run, whether they succeed or not. This has no side effects.
+func (c *Core) AuditLog() ([]AuditEntry, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if err := c.checkDirectory(true); err != nil {
+		return nil, err
+	}
+
+	return c.auditEntries()
+}
+
+// auditKey returns the key of the blob in the directory that holds the
+// audit entries of the Appfile.
+func (c *Core) auditKey() string {
+	return fmt.Sprintf("audit-%s", c.appfile.ID)
+}
+
+// auditEntries reads the stored audit entries.
+func (c *Core) auditEntries() ([]AuditEntry, error) {
+	var result []AuditEntry
+	if _, err := c.getRecord(c.auditKey(), &result); err != nil {
+		return nil, fmt.Errorf("Error reading audit log: %s", err)
+	}
+
+	return result, nil
+}
+
+// audit records the result of a mutating operation. Failing to store
+// the entry doesn't fail the operation, which has already happened, so
+// it is reported as a warning instead.
+func (c *Core) audit(operation, action string, opErr error) {
+	entry := AuditEntry{
+		Time:      c.clock.Now(),
+		Actor:     c.actor(),
+		Operation: operation,
+		Action:    action,
+		AppID:     c.appfile.ID,
+		Result:    AuditResultSuccess,
+	}
+	if c.appfile.Project != nil {
+		entry.Infra = c.appfile.Project.Infrastructure
+	}
+	if opErr != nil {
+		entry.Result = AuditResultError
+		entry.Error = opErr.Error()
+	}
+	if operation == "build" || operation == "deploy" {
+		if build, err := c.builtRecord(); err == nil && build != nil {
+			entry.Artifact = build.Artifact
+		}
+	}
+
+	if err := c.putAuditEntry(entry); err != nil {
+		c.warn(WarningSourceCore, "", fmt.Sprintf(
+			"Error storing the audit log entry for %s: %s", operation, err))
+	}
+}
+
+// putAuditEntry appends the entry to the stored audit entries.
+func (c *Core) putAuditEntry(entry AuditEntry) error {
+	entries, err := c.auditEntries()
+	if err != nil {
+		return err
+	}
+
+	return c.putRecord(c.auditKey(), append(entries, entry))
+}
+
+// actor returns who is running the operations, for the audit log. This
+// is CoreConfig.Actor, or the name of the current user.
+func (c *Core) actor() string {
+	if c.actorName != "" {
+		return c.actorName
+	}
+
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if v := os.Getenv("USER"); v != "" {
+		return v
+	}
+
+	return "unknown"
+}
+
+// auditedAction returns true if the action changes something and is
+// recorded in the audit log, which is all of them except the ones that
+// only show information.
+func auditedAction(action string) bool {
+	return action != "help" && action != "info"
+}
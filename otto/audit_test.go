@@ -0,0 +1,84 @@
+package otto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreAuditLog(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	coreConfig.Actor = "ci-job"
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	entries, err := core.AuditLog()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	// The app records the build in the directory
+	tuple, err := core.RootTuple()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = coreConfig.Directory.PutBuild(&directory.Build{
+		Lookup: directory.Lookup{
+			AppID:       core.appfile.ID,
+			Infra:       tuple.Infra,
+			InfraFlavor: tuple.InfraFlavor,
+		},
+		Artifact: map[string]string{"ami": "ami-123"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Build(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Showing information isn't recorded
+	if err := core.Deploy(&DeployOpts{Action: "info"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	appMock.DeployErr = errors.New("failed")
+	if err := core.Deploy(nil); err == nil {
+		t.Fatal("should error")
+	}
+
+	entries, err = core.AuditLog()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	entry := entries[0]
+	if entry.Operation != "build" || entry.Result != AuditResultSuccess {
+		t.Fatalf("bad: %#v", entry)
+	}
+	if entry.Actor != "ci-job" || entry.AppID != core.appfile.ID {
+		t.Fatalf("bad: %#v", entry)
+	}
+	if entry.Infra != core.appfile.Project.Infrastructure || entry.Artifact["ami"] != "ami-123" {
+		t.Fatalf("bad: %#v", entry)
+	}
+
+	entry = entries[1]
+	if entry.Operation != "deploy" || entry.Result != AuditResultError {
+		t.Fatalf("bad: %#v", entry)
+	}
+	if entry.Error != "failed" {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
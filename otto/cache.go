@@ -0,0 +1,169 @@
+package otto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/otto/appfile"
+)
+
+// CacheKeyStrategy determines the key that the cached data of a
+// dependency, such as its dev dependency, is stored under in the
+// DataDir. Dependencies with the same key share their cache.
+type CacheKeyStrategy interface {
+	CacheKey(f *appfile.File) (string, error)
+}
+
+// IDCacheKey is the CacheKeyStrategy used if none is configured. It keys
+// the cache by the Otto ID of the dependency.
+type IDCacheKey struct{}
+
+func (IDCacheKey) CacheKey(f *appfile.File) (string, error) {
+	return f.ID, nil
+}
+
+// ContentCacheKey is a CacheKeyStrategy that keys the cache by the
+// contents of the directory of the dependency's Appfile, so that
+// projects using identical copies of a dependency share its cache even
+// if they were fetched separately. Otto's own ".otto" directory is
+// excluded.
+//
+// Hasher is the algorithm used to hash the contents. If it is nil, the
+// CoreConfig.Hasher is used when this is the CoreConfig.CacheKey, and
+// SHA-256 otherwise.
+//
+// Incremental, if true, caches the hash of every file by its modification
+// time and size so that only the files that changed are read again. When
+// this is the CoreConfig.CacheKey, the hashes are cached in the DataDir;
+// otherwise this has no effect. Files modified shortly before they were
+// hashed are always read again, since their times can't be trusted. The
+// keys differ from those computed without it, so changing this rebuilds
+// the cache once.
+type ContentCacheKey struct {
+	Hasher      Hasher
+	Incremental bool
+
+	// hashCache is the path of the file hash cache, set by NewCore.
+	hashCache string
+}
+
+// fileHashCachePath is the path of the file hash cache of an incremental
+// ContentCacheKey within the data directory.
+func fileHashCachePath(dataDir string) string {
+	return filepath.Join(dataDir, "cache-fingerprints.json")
+}
+
+func (k ContentCacheKey) CacheKey(f *appfile.File) (string, error) {
+	if f.Path == "" {
+		return f.ID, nil
+	}
+
+	dir := filepath.Dir(f.Path)
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".otto" {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+
+	// Hash the relative path and contents of every file
+	hasher := k.Hasher
+	if hasher == nil {
+		hasher = SHA256Hasher{}
+	}
+	if k.Incremental && k.hashCache != "" {
+		return k.incrementalKey(hasher, dir, paths)
+	}
+
+	h := hasher.New()
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+
+		fh, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, fh)
+		fh.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+
+	return "content-" + truncateHash(hex.EncodeToString(h.Sum(nil)), 32), nil
+}
+
+// incrementalKey is CacheKey with the hashes of the files from the file
+// hash cache rather than their contents.
+func (k ContentCacheKey) incrementalKey(hasher Hasher, dir string, paths []string) (string, error) {
+	fileHashCacheLock.Lock()
+	defer fileHashCacheLock.Unlock()
+
+	cache := loadFileHashCache(k.hashCache, hasher)
+	seen := make(map[string]struct{}, len(paths))
+	h := hasher.New()
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		hash, err := cache.Hash(path, info)
+		if err != nil {
+			return "", err
+		}
+
+		seen[path] = struct{}{}
+		fmt.Fprintf(h, "%s\x00%s\x00", filepath.ToSlash(rel), hash)
+	}
+
+	cache.Prune(dir, seen)
+	if err := cache.Save(); err != nil {
+		log.Printf("[WARN] error saving file hash cache %s: %s", k.hashCache, err)
+	}
+
+	return "content-" + truncateHash(hex.EncodeToString(h.Sum(nil)), 32), nil
+}
+
+// appCacheDir returns the directory in the DataDir for the cached data
+// of the application in f. The main application is always keyed by its
+// ID; the dependencies use the configured CacheKeyStrategy.
+func (c *Core) appCacheDir(f *appfile.File) (string, error) {
+	key := f.ID
+	if f.ID != c.appfile.ID {
+		var err error
+		key, err = c.cacheKey.CacheKey(f)
+		if err != nil {
+			return "", fmt.Errorf(
+				"Error determining the cache key of %s: %s", f.Path, err)
+		}
+	}
+
+	return filepath.Join(c.dataDir, "cache", key), nil
+}
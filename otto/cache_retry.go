@@ -0,0 +1,77 @@
+package otto
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+)
+
+// DefaultCacheReadBackoff is the CoreConfig.CacheReadBackoff used if none
+// is configured.
+const DefaultCacheReadBackoff = 100 * time.Millisecond
+
+// cachedDevDep returns true if the dev dependency cached at path can be
+// used. The cache may be on a network file system, so transient errors
+// are retried; if it still can't be read, a warning is reported and the
+// dependency is built again.
+func (c *Core) cachedDevDep(name, path string) bool {
+	ok, err := c.retryCacheRead(path, func() (bool, error) {
+		return readCachedDevDep(path)
+	})
+	if err != nil {
+		c.warn(WarningSourceCore, name, fmt.Sprintf(
+			"Error reading cached dev dependency, building it instead: %s", err))
+	}
+
+	return ok
+}
+
+// readCachedDevDep reads the dev dependency cached at path. The result
+// is false without an error if it isn't cached or the cache is corrupt,
+// which retrying doesn't fix.
+func readCachedDevDep(path string) (bool, error) {
+	_, err := app.ReadDevDep(path)
+	switch {
+	case err == nil:
+		return true, nil
+	case os.IsNotExist(err):
+		return false, nil
+	}
+
+	if _, ok := err.(*os.PathError); ok {
+		return false, err
+	}
+
+	log.Printf("[WARN] cached dev dependency %s is corrupt, ignoring it: %s", path, err)
+	return false, nil
+}
+
+// retryCacheRead calls read until it succeeds, reports a miss, or fails
+// more than CoreConfig.CacheReadRetries times. read returns true if the
+// data was found, and an error only for transient failures.
+func (c *Core) retryCacheRead(path string, read func() (bool, error)) (bool, error) {
+	wait := c.cacheBackoff
+	if wait <= 0 {
+		wait = DefaultCacheReadBackoff
+	}
+
+	var err error
+	for i := 0; i <= c.cacheRetries; i++ {
+		if i > 0 {
+			log.Printf(
+				"[WARN] error reading cache for %s, retrying in %s: %s", path, wait, err)
+			time.Sleep(wait)
+			wait *= 2
+		}
+
+		var ok bool
+		if ok, err = read(); err == nil {
+			return ok, nil
+		}
+	}
+
+	return false, err
+}
@@ -0,0 +1,161 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+)
+
+func TestContentCacheKey(t *testing.T) {
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Two copies of the same dependency and a different one
+	write := func(dir, contents string) *appfile.File {
+		path := filepath.Join(td, dir, "Appfile")
+		if err := os.MkdirAll(filepath.Join(td, dir, ".otto"), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// Otto's own data doesn't matter
+		junk := filepath.Join(td, dir, ".otto", dir)
+		if err := ioutil.WriteFile(junk, nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		return &appfile.File{ID: dir, Path: path}
+	}
+	a := write("a", "foo")
+	b := write("b", "foo")
+	other := write("c", "bar")
+
+	keyA, err := ContentCacheKey{}.CacheKey(a)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	keyB, err := ContentCacheKey{}.CacheKey(b)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	keyOther, err := ContentCacheKey{}.CacheKey(other)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if keyA != keyB {
+		t.Fatalf("bad: %s %s", keyA, keyB)
+	}
+	if keyA == keyOther {
+		t.Fatalf("bad: %s", keyOther)
+	}
+}
+
+func TestContentCacheKey_incremental(t *testing.T) {
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "app", "Appfile")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	write := func(contents string, mtime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	f := &appfile.File{ID: "app", Path: path}
+	k := ContentCacheKey{
+		Incremental: true,
+		hashCache:   filepath.Join(td, "hashes.json"),
+	}
+
+	old := time.Now().Add(-time.Hour)
+	write("foo", old)
+	key, err := k.CacheKey(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A file with the same time and size isn't read again
+	write("bar", old)
+	if actual, err := k.CacheKey(f); err != nil || actual != key {
+		t.Fatalf("bad: %s %v", actual, err)
+	}
+
+	// A different size is
+	write("foobar", old)
+	changed, err := k.CacheKey(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if changed == key {
+		t.Fatal("key should change")
+	}
+
+	// A file modified just before it was hashed is always read again
+	recent := time.Now()
+	write("foo", recent)
+	key, err = k.CacheKey(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	write("bar", recent)
+	if actual, err := k.CacheKey(f); err != nil || actual == key {
+		t.Fatalf("bad: %s %v", actual, err)
+	}
+
+	// A corrupt cache hashes everything again
+	if err := ioutil.WriteFile(k.hashCache, []byte("{"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	write("foo", old)
+	if actual, err := k.CacheKey(f); err != nil || actual != key {
+		t.Fatalf("bad: %s %v", actual, err)
+	}
+}
+
+func TestCoreCompile_contentCacheKey(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	coreConfig.CacheKey = ContentCacheKey{}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	var dirs []string
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		dirs = append(dirs, ctx.CacheDir)
+		return nil, nil
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The dependency is compiled first, the root is still keyed by ID
+	if len(dirs) != 2 {
+		t.Fatalf("bad: %#v", dirs)
+	}
+	if !strings.HasPrefix(filepath.Base(dirs[0]), "content-") {
+		t.Fatalf("bad: %#v", dirs)
+	}
+	if filepath.Base(dirs[1]) != coreConfig.Appfile.File.ID {
+		t.Fatalf("bad: %#v", dirs)
+	}
+}
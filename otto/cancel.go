@@ -0,0 +1,114 @@
+package otto
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CancelSummary is the result of CancelAll: the IDs of the jobs that
+// were running, by how they ended.
+type CancelSummary struct {
+	// Canceled are the jobs that stopped because they were canceled.
+	Canceled []string
+
+	// Completed are the jobs that finished on their own, successfully or
+	// not, before they noticed they were canceled.
+	Completed []string
+
+	// Running are the jobs that were still running at the deadline.
+	Running []string
+}
+
+// CancelAll cancels every job running in the background, such as those
+// started with BuildAsync on this Core or on the Cores created from it
+// with WithDirs, and waits up to timeout for them to finish cleaning up.
+// This is used to drain the operations of a server that is shutting
+// down. If timeout is zero, it waits as long as it takes.
+//
+// Jobs started after CancelAll is called aren't canceled. An error is
+// returned along with the summary if some jobs were still running at
+// the deadline.
+func (c *Core) CancelAll(timeout time.Duration) (*CancelSummary, error) {
+	jobs := c.jobs.list()
+	for _, job := range jobs {
+		job.Cancel()
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// The deadline only fires once, so once it has, the remaining jobs
+	// are only checked rather than waited for.
+	result := new(CancelSummary)
+	expired := false
+	for _, job := range jobs {
+		if !expired {
+			select {
+			case <-job.Done():
+			case <-deadline:
+				expired = true
+			}
+		}
+
+		switch job.Status() {
+		case JobRunning:
+			result.Running = append(result.Running, job.ID())
+		case JobCanceled:
+			result.Canceled = append(result.Canceled, job.ID())
+		default:
+			result.Completed = append(result.Completed, job.ID())
+		}
+	}
+
+	sort.Strings(result.Canceled)
+	sort.Strings(result.Completed)
+	sort.Strings(result.Running)
+	if len(result.Running) > 0 {
+		return result, fmt.Errorf(
+			"%d of %d operations didn't finish within %s of being canceled",
+			len(result.Running), len(jobs), timeout)
+	}
+
+	return result, nil
+}
+
+// jobSet is the set of jobs running in the background. It is safe for
+// concurrent use.
+type jobSet struct {
+	sync.Mutex
+	jobs map[*Job]struct{}
+}
+
+func (s *jobSet) add(job *Job) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = make(map[*Job]struct{})
+	}
+	s.jobs[job] = struct{}{}
+}
+
+func (s *jobSet) remove(job *Job) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.jobs, job)
+}
+
+func (s *jobSet) list() []*Job {
+	s.Lock()
+	defer s.Unlock()
+
+	result := make([]*Job, 0, len(s.jobs))
+	for job := range s.jobs {
+		result = append(result, job)
+	}
+
+	return result
+}
@@ -0,0 +1,103 @@
+package otto
+
+import (
+	"log"
+	"sort"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// CleanOpts are the options for Clean.
+type CleanOpts struct {
+	// Apps are the applications whose compiled output is removed. They
+	// are matched against the Otto ID of each Appfile in the dependency
+	// graph first, then the application name.
+	Apps []string
+
+	// Dependencies, if true, also removes the compiled output of
+	// everything the Apps depend on.
+	Dependencies bool
+
+	// Infra, if true, removes the compiled output of the infrastructure.
+	Infra bool
+}
+
+// Clean removes the compiled output of only some of the applications or
+// the infrastructure, rather than everything like Compile does. The
+// output is removed from the same directories that compilation writes
+// to. Compile must be run again before the output can be used.
+func (c *Core) Clean(opts *CleanOpts) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	// Find everything first so we don't remove anything if one of the
+	// applications can't be found.
+	var dirs []string
+	vertices, err := c.cleanVertices(opts)
+	if err != nil {
+		return err
+	}
+	for _, v := range vertices {
+		dirs = append(dirs, c.appOutputDir(v.File))
+	}
+	if opts.Infra {
+		dirs = append(dirs, c.infraOutputDir())
+	}
+
+	for _, dir := range dirs {
+		log.Printf("[INFO] core: cleaning compiled output: %s", dir)
+		if err := removeAll(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanVertices returns the vertices to clean for the options, sorted by
+// name.
+func (c *Core) cleanVertices(opts *CleanOpts) ([]*appfile.CompiledGraphVertex, error) {
+	g := c.appfileCompiled.Graph
+	seen := make(map[dag.Vertex]struct{})
+	var queue []dag.Vertex
+	for _, name := range opts.Apps {
+		v, err := findVertex(c.appfileCompiled, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			queue = append(queue, v)
+		}
+	}
+
+	if opts.Dependencies {
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, dep := range dag.AsVertexList(g.DownEdges(current)) {
+				if _, ok := seen[dep]; !ok {
+					seen[dep] = struct{}{}
+					queue = append(queue, dep)
+				}
+			}
+		}
+	}
+
+	result := make([]dag.Vertex, 0, len(seen))
+	for v := range seen {
+		result = append(result, v)
+	}
+	sort.Sort(vertexByName(result))
+
+	vertices := make([]*appfile.CompiledGraphVertex, len(result))
+	for i, v := range result {
+		vertices[i] = v.(*appfile.CompiledGraphVertex)
+	}
+
+	return vertices, nil
+}
@@ -0,0 +1,67 @@
+package otto
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreClean(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Record the output directories and write to each of them
+	dirs := make(map[string]string)
+	var lock sync.Mutex
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		dirs[ctx.Application.Name] = ctx.Dir
+		return nil, os.MkdirAll(ctx.Dir, 0755)
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.MkdirAll(core.infraOutputDir(), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Clean only the dependency
+	if err := core.Clean(&CleanOpts{Apps: []string{"child"}}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(dirs["child"]); err == nil {
+		t.Fatal("child should be removed")
+	}
+	if _, err := os.Stat(dirs["root"]); err != nil {
+		t.Fatalf("root should be kept: %s", err)
+	}
+	if _, err := os.Stat(core.infraOutputDir()); err != nil {
+		t.Fatalf("infra should be kept: %s", err)
+	}
+
+	// Clean the root with its dependencies and the infra
+	err := core.Clean(&CleanOpts{
+		Apps:         []string{"root"},
+		Dependencies: true,
+		Infra:        true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, dir := range []string{dirs["root"], core.infraOutputDir()} {
+		if _, err := os.Stat(dir); err == nil {
+			t.Fatalf("should be removed: %s", dir)
+		}
+	}
+
+	// Unknown applications fail
+	if err := core.Clean(&CleanOpts{Apps: []string{"nope"}}); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,19 @@
+package otto
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time for everything the Core
+// records or compares timestamps with, such as events and locks.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the Clock used if none is configured. It returns the
+// actual current time.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,46 @@
+package otto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreClock(t *testing.T) {
+	start := time.Date(2015, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := &TestClock{T: start}
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Clock = clock
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// The lock is timestamped with the clock, then time passes
+	var lockInfo *LockInfo
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		var err error
+		lockInfo, err = core.LockInfo()
+		clock.Advance(time.Hour)
+		return nil, err
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if lockInfo == nil || !lockInfo.Created.Equal(start) {
+		t.Fatalf("bad: %#v", lockInfo)
+	}
+
+	history := core.EventHistory()
+	if len(history) != 2 {
+		t.Fatalf("bad: %#v", history)
+	}
+	if !history[0].Time.Equal(start) {
+		t.Fatalf("bad: %#v", history[0])
+	}
+	if !history[1].Time.Equal(start.Add(time.Hour)) {
+		t.Fatalf("bad: %#v", history[1])
+	}
+}
@@ -0,0 +1,123 @@
+package otto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/otto/directory"
+)
+
+// Codec encodes and decodes the records that the Core stores in the
+// directory as blobs, such as the audit log, so that a backend can use
+// a representation that suits it better, such as a compact binary
+// format for large state.
+type Codec interface {
+	// Name identifies the format. It is stored with every record so that
+	// records written with a different codec are detected.
+	Name() string
+
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// JSONCodec is the Codec used if none is configured. Records written by
+// versions of Otto without codecs are JSON as well, and records in JSON
+// can always be read, whichever codec is configured.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSONCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// recordVersion is the version of the schema of the records the Core
+// stores. Records of a newer version aren't read.
+const recordVersion = 1
+
+// recordMagic starts the header line of every record, which is followed
+// by the schema version and the name of the codec: "otto-record/1 json".
+const recordMagic = "otto-record/"
+
+// putRecord encodes v with the configured codec and stores it in the
+// blob with the given key.
+func (c *Core) putRecord(key string, v interface{}) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s%d %s\n", recordMagic, recordVersion, c.codec.Name())
+	if err := c.codec.Encode(&buf, v); err != nil {
+		return fmt.Errorf("Error encoding %s: %s", key, err)
+	}
+
+	return c.dir.PutBlob(key, &directory.BlobData{
+		Data: bytes.NewReader(buf.Bytes()),
+	})
+}
+
+// getRecord decodes the record stored in the blob with the given key
+// into v. The result is false if there is no such blob.
+func (c *Core) getRecord(key string, v interface{}) (bool, error) {
+	blob, err := c.dir.GetBlob(key)
+	if err != nil || blob == nil {
+		return false, err
+	}
+	defer blob.Close()
+
+	r := bufio.NewReader(blob.Data)
+	codec, err := c.recordCodec(key, r)
+	if err != nil {
+		return false, err
+	}
+	if err := codec.Decode(r, v); err != nil {
+		return false, fmt.Errorf("Error decoding %s: %s", key, err)
+	}
+
+	return true, nil
+}
+
+// recordCodec reads the header of a record and returns the codec to
+// decode it with. Records without a header predate codecs and are JSON.
+func (c *Core) recordCodec(key string, r *bufio.Reader) (Codec, error) {
+	peek, _ := r.Peek(len(recordMagic))
+	if string(peek) != recordMagic {
+		return JSONCodec{}, nil
+	}
+
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Error reading header of %s: %s", key, err)
+	}
+	parts := strings.Fields(strings.TrimPrefix(header, recordMagic))
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid header of %s: %q", key, header)
+	}
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid header of %s: %q", key, header)
+	}
+	if version > recordVersion {
+		return nil, fmt.Errorf(
+			"%s was written by a newer version of Otto (record version %d,\n"+
+				"this version reads up to %d). Please upgrade Otto.",
+			key, version, recordVersion)
+	}
+
+	switch name := parts[1]; name {
+	case c.codec.Name():
+		return c.codec, nil
+	case JSONCodec{}.Name():
+		return JSONCodec{}, nil
+	default:
+		return nil, fmt.Errorf(
+			"%s was written with the codec %q, but the configured codec is %q",
+			key, name, c.codec.Name())
+	}
+}
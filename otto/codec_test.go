@@ -0,0 +1,86 @@
+package otto
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"testing"
+
+	"github.com/hashicorp/otto/directory"
+)
+
+func TestCoreRecord_codec(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Codec = testGobCodec{}
+	core := testCore(t, coreConfig)
+
+	if err := core.putRecord("key", []string{"a", "b"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var actual []string
+	ok, err := core.getRecord("key", &actual)
+	if err != nil || !ok {
+		t.Fatalf("bad: %v %s", ok, err)
+	}
+	if len(actual) != 2 || actual[1] != "b" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// A Core with the default codec can't read it
+	coreConfig.Codec = nil
+	core = testCore(t, coreConfig)
+	if _, err := core.getRecord("key", &actual); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Missing records aren't found
+	if ok, err := core.getRecord("missing", &actual); ok || err != nil {
+		t.Fatalf("bad: %v %s", ok, err)
+	}
+}
+
+func TestCoreRecord_legacy(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Codec = testGobCodec{}
+	core := testCore(t, coreConfig)
+
+	// Records without a header are JSON
+	err := core.dir.PutBlob("key", &directory.BlobData{
+		Data: bytes.NewReader([]byte(`["a"]`)),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var actual []string
+	if _, err := core.getRecord("key", &actual); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(actual) != 1 || actual[0] != "a" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Records of a newer version aren't read
+	err = core.dir.PutBlob("key", &directory.BlobData{
+		Data: bytes.NewReader([]byte("otto-record/99 json\n[\"a\"]")),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := core.getRecord("key", &actual); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+type testGobCodec struct{}
+
+func (testGobCodec) Name() string { return "gob" }
+
+func (testGobCodec) Encode(w io.Writer, v interface{}) error {
+	return gob.NewEncoder(w).Encode(v)
+}
+
+func (testGobCodec) Decode(r io.Reader, v interface{}) error {
+	return gob.NewDecoder(r).Decode(v)
+}
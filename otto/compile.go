@@ -33,10 +33,15 @@ type CompileMetadata struct {
 }
 
 func (c *Core) resetCompileMetadata() {
+	c.metadataLock.Lock()
+	defer c.metadataLock.Unlock()
 	c.metadataCache = nil
 }
 
 func (c *Core) compileMetadata() (*CompileMetadata, error) {
+	c.metadataLock.Lock()
+	defer c.metadataLock.Unlock()
+
 	if c.metadataCache != nil {
 		return c.metadataCache, nil
 	}
@@ -0,0 +1,58 @@
+package otto
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+
+	execHelper "github.com/hashicorp/otto/helper/exec"
+)
+
+// runCompileCommands runs the pre_compile or post_compile commands of
+// the project, named by phase, in the project directory with the
+// CoreConfig.Env applied. Their output is shown through the Ui and the
+// first one that fails stops compilation.
+func (c *Core) runCompileCommands(phase string, cmds []string) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	env := os.Environ()
+	keys := make([]string, 0, len(c.env))
+	for k := range c.env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", k, c.env[k]))
+	}
+
+	for _, line := range cmds {
+		c.ui.Header(fmt.Sprintf("Running %s command: %s", phase, line))
+
+		cmd := shellCommand(line)
+		cmd.Dir = c.projectDirPath()
+		cmd.Env = env
+		if err := execHelper.Run(c.ui, cmd); err != nil {
+			return fmt.Errorf(
+				"Error running %s command %q: %s\n\n"+
+					"The command is configured in the project stanza of the\n"+
+					"Appfile. Compilation stops when one of them fails.",
+				phase, line, err)
+		}
+	}
+
+	return nil
+}
+
+// shellCommand returns the command that runs line with the shell of the
+// operating system.
+func shellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+
+	return exec.Command("/bin/sh", "-c", line)
+}
@@ -0,0 +1,103 @@
+package otto
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	execHelper "github.com/hashicorp/otto/helper/exec"
+)
+
+func TestCoreCompile_commands(t *testing.T) {
+	runner := new(execHelper.MockRunner)
+	defer execHelper.TestChrunner(runner.Run)()
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Appfile.File.Project.PreCompile = []string{"echo pre1", "echo pre2"}
+	coreConfig.Appfile.File.Project.PostCompile = []string{"echo post"}
+	coreConfig.Env = map[string]string{"FOO": "bar"}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(runner.Commands) != 3 {
+		t.Fatalf("bad: %#v", runner.Commands)
+	}
+	cmd := runner.Commands[0]
+	if cmd.Args[len(cmd.Args)-1] != "echo pre1" || cmd.Dir != core.projectDirPath() {
+		t.Fatalf("bad: %#v", cmd)
+	}
+	if env := cmd.Env[len(cmd.Env)-1]; env != "FOO=bar" {
+		t.Fatalf("bad: %s", env)
+	}
+	if cmd := runner.Commands[2]; cmd.Args[len(cmd.Args)-1] != "echo post" {
+		t.Fatalf("bad: %#v", cmd)
+	}
+
+	// A failing pre-compile command stops the compilation
+	runner.Commands = nil
+	runner.CommandErrs = []error{errors.New("exit status 1")}
+	appMock.CompileCalled = false
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+	if len(runner.Commands) != 1 || appMock.CompileCalled {
+		t.Fatalf("bad: %#v", runner.Commands)
+	}
+
+	// A failing post-compile command fails the compilation, and it isn't
+	// recorded as successful.
+	mdPath := filepath.Join(coreConfig.CompileDir, "metadata.json")
+	if err := os.Remove(mdPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	runner.Commands = nil
+	runner.CommandErrs = []error{nil, nil, errors.New("exit status 1")}
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+	if !appMock.CompileCalled {
+		t.Fatal("compile should be called")
+	}
+	if _, err := os.Stat(mdPath); !os.IsNotExist(err) {
+		t.Fatalf("metadata shouldn't be saved: %v", err)
+	}
+}
+
+func TestCoreCompile_commandsScratch(t *testing.T) {
+	runner := new(execHelper.MockRunner)
+	defer execHelper.TestChrunner(runner.Run)()
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Appfile.File.Project.PreCompile = []string{"echo pre"}
+	coreConfig.Appfile.File.Project.PostCompile = []string{"echo post"}
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	runner.Commands = nil
+
+	// Previews don't run the commands
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	if err := core.CompileTo(td); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := core.DetectDrift(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(runner.Commands) != 0 {
+		t.Fatalf("bad: %#v", runner.Commands)
+	}
+}
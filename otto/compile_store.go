@@ -0,0 +1,294 @@
+package otto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// compileTreeFilename is the name of the file within the compile
+// directory that lists the compiled files kept in the CompileStore.
+const compileTreeFilename = "tree.json"
+
+// CompileStore is a content-addressed store for the contents of compiled
+// files; see CoreConfig.CompileStore. The contents are keyed by their
+// hash, so the same contents are stored once no matter how many projects
+// produce them. It must be safe for concurrent use by several Cores.
+type CompileStore interface {
+	// Has returns true if the store has the contents for key.
+	Has(key string) (bool, error)
+
+	// Put stores the contents read from r for key. The contents for a
+	// key are always the same, so it doesn't matter which of several
+	// concurrent Puts for a key wins.
+	Put(key string, r io.Reader) error
+
+	// Get returns the contents for key. If the store doesn't have them,
+	// the error satisfies os.IsNotExist.
+	Get(key string) (io.ReadCloser, error)
+}
+
+// DirCompileStore is a CompileStore that keeps each contents in a file
+// in a directory, such as one shared by the projects of a machine.
+type DirCompileStore struct {
+	// Path is the directory. It is created if it doesn't exist.
+	Path string
+}
+
+func (s *DirCompileStore) Has(key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func (s *DirCompileStore) Put(key string, r io.Reader) error {
+	if err := os.MkdirAll(s.Path, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a reader never sees
+	// partial contents.
+	f, err := ioutil.TempFile(s.Path, key+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(key))
+}
+
+func (s *DirCompileStore) Get(key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s *DirCompileStore) path(key string) string {
+	return filepath.Join(s.Path, key)
+}
+
+// compileTree is the list of the compiled files whose contents are in
+// the CompileStore, written to compileTreeFilename.
+type compileTree struct {
+	// HashAlgorithm is the name of the Hasher used for the keys.
+	HashAlgorithm string `json:"hash_algorithm"`
+
+	Files []*compileTreeFile `json:"files"`
+}
+
+type compileTreeFile struct {
+	// Path is slash-separated and relative to the compile directory.
+	Path string      `json:"path"`
+	Hash string      `json:"hash"`
+	Mode os.FileMode `json:"mode"`
+}
+
+// compileTreeFileSlice is used to sort the files of a tree by path.
+type compileTreeFileSlice []*compileTreeFile
+
+func (s compileTreeFileSlice) Len() int           { return len(s) }
+func (s compileTreeFileSlice) Less(i, j int) bool { return s[i].Path < s[j].Path }
+func (s compileTreeFileSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// compileStoreKey returns the key of contents with the given hash. The
+// algorithm is part of it so that keys don't clash across algorithms.
+func compileStoreKey(algorithm, hash string) string {
+	return algorithm + "-" + hash
+}
+
+// storeCompiled puts the contents of the files in the manifest into the
+// CompileStore, if one is configured, and replaces them with the tree
+// of references to them. Other files, such as those kept with
+// CompileKeep, are left where they are. The directories are left too,
+// since they cost nothing.
+func (c *Core) storeCompiled(m *Manifest) error {
+	if c.compileStore == nil || c.scratch {
+		return nil
+	}
+
+	tree := &compileTree{HashAlgorithm: c.hasher.Name()}
+	var err error
+	m.each(func(rel string, f *ManifestFile) {
+		if err != nil {
+			return
+		}
+
+		path := filepath.Join(c.compileDir, filepath.FromSlash(rel))
+		var info os.FileInfo
+		info, err = os.Lstat(path)
+		if err != nil {
+			return
+		}
+
+		key := compileStoreKey(tree.HashAlgorithm, f.SHA256)
+		var ok bool
+		ok, err = c.compileStore.Has(key)
+		if err == nil && !ok {
+			err = storeCompiledFile(c.compileStore, key, path)
+		}
+		if err != nil {
+			err = fmt.Errorf("Error storing compiled file %s: %s", rel, err)
+			return
+		}
+
+		tree.Files = append(tree.Files, &compileTreeFile{
+			Path: rel,
+			Hash: f.SHA256,
+			Mode: info.Mode().Perm(),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	sort.Sort(compileTreeFileSlice(tree.Files))
+
+	// Write the tree before deleting anything so that an interruption
+	// never loses a file: the ones left are skipped when materializing.
+	data, err := json.MarshalIndent(tree, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(c.compileDir, compileTreeFilename), data, 0644)
+	if err != nil {
+		return err
+	}
+	for _, f := range tree.Files {
+		err := os.Remove(filepath.Join(c.compileDir, filepath.FromSlash(f.Path)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func storeCompiledFile(s CompileStore, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.Put(key, f)
+}
+
+// Materialize writes the compiled files that are in the CompileStore
+// back into the compile directory, so that it has the complete output
+// of the last compilation. The operations that use the compiled output,
+// such as Dev and Build, call this themselves, so this is only needed
+// to use the compile directory outside of Otto. It does nothing if the
+// files aren't in a store.
+func (c *Core) Materialize() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	return c.materialize()
+}
+
+// materialize is Materialize without the lock. Files that already exist
+// are left as they are, so this is cheap once the tree is materialized.
+func (c *Core) materialize() error {
+	data, err := ioutil.ReadFile(filepath.Join(c.compileDir, compileTreeFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	var tree compileTree
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return fmt.Errorf("Error reading %s: %s", compileTreeFilename, err)
+	}
+	if c.compileStore == nil && len(tree.Files) > 0 {
+		return fmt.Errorf(
+			"The compiled files are in a compile store, but none is configured.\n" +
+				"Configure the store that was used to compile, or compile again.")
+	}
+
+	for _, f := range tree.Files {
+		if strings.HasPrefix(f.Path, "../") {
+			continue
+		}
+
+		path := filepath.Join(c.compileDir, filepath.FromSlash(f.Path))
+		if _, err := os.Lstat(path); err == nil {
+			continue
+		}
+
+		log.Printf("[DEBUG] materializing compiled file: %s", f.Path)
+		if err := c.materializeFile(&tree, f, path); err != nil {
+			return fmt.Errorf("Error materializing compiled file %s: %s", f.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// materializeFile writes the contents of f to path. The contents are
+// written to a temporary file first, so that an interruption doesn't
+// leave a partial file that would be skipped the next time.
+func (c *Core) materializeFile(tree *compileTree, f *compileTreeFile, path string) error {
+	r, err := c.compileStore.Get(compileStoreKey(tree.HashAlgorithm, f.Hash))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// Verify the contents if we can, since the store is shared.
+	var w io.Writer = tmp
+	h := c.hasher.New()
+	verify := tree.HashAlgorithm == c.hasher.Name()
+	if verify {
+		w = io.MultiWriter(tmp, h)
+	}
+	_, err = io.Copy(w, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if verify {
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != f.Hash {
+			return fmt.Errorf("the stored contents are corrupt: hash %s, expected %s",
+				actual, f.Hash)
+		}
+	}
+
+	if err := os.Chmod(tmp.Name(), f.Mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
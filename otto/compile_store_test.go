@@ -0,0 +1,119 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreCompile_store(t *testing.T) {
+	storeDir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(storeDir)
+	store := &DirCompileStore{Path: storeDir}
+
+	compile := func() (*Core, string) {
+		coreConfig := TestCoreConfig(t)
+		coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+		coreConfig.CompileStore = store
+		appMock := TestApp(t, TestAppTuple, coreConfig)
+		appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+			if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+				return nil, err
+			}
+
+			return nil, ioutil.WriteFile(
+				filepath.Join(ctx.Dir, "run.sh"), []byte("#!/bin/sh\n"), 0755)
+		}
+		core := testCore(t, coreConfig)
+		if err := core.Compile(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		return core, filepath.Join(coreConfig.CompileDir, "app", "run.sh")
+	}
+
+	core, path := compile()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("compiled file should be stored: %v", err)
+	}
+	infos, err := ioutil.ReadDir(storeDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	stored := len(infos)
+	if stored == 0 {
+		t.Fatal("store should have contents")
+	}
+
+	// The same contents of another project are stored once
+	_, otherPath := compile()
+	infos, err = ioutil.ReadDir(storeDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(infos) != stored {
+		t.Fatalf("bad: %d != %d", len(infos), stored)
+	}
+	if otherPath == path {
+		t.Fatal("projects should have different compile directories")
+	}
+
+	if err := core.Materialize(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("bad: %s", info.Mode())
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "#!/bin/sh\n" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	// Corrupt contents aren't materialized
+	for _, info := range infos {
+		err := ioutil.WriteFile(filepath.Join(storeDir, info.Name()), []byte("bad"), 0644)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Materialize(); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("corrupt file shouldn't be written: %v", err)
+	}
+}
+
+func TestCoreMaterialize_noStore(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Plain output is left as is
+	if err := core.Materialize(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, err := os.Stat(filepath.Join(coreConfig.CompileDir, compileTreeFilename))
+	if !os.IsNotExist(err) {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -18,25 +19,110 @@ import (
 	"github.com/hashicorp/otto/foundation"
 	"github.com/hashicorp/otto/helper/localaddr"
 	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/plugin"
 	"github.com/hashicorp/otto/ui"
 	"github.com/hashicorp/terraform/dag"
 	"github.com/mitchellh/copystructure"
 )
 
 // Core is the main struct to use to interact with Otto as a library.
+//
+// Only one operation that compiles or changes state (Compile, Build,
+// Deploy, Dev, Infra, Execute, Import, Shell, TestConnectivity) may run
+// on a Core at a time. Calling one of these while another is running
+// returns ErrBusy. The lock is also held in the LocalDir so that
+// operations in other processes for the same Appfile fail with a
+// *LockError; see LockInfo and ForceUnlock to recover from a process
+// that crashed.
+//
+// Read-only methods such as Status or Manifest are safe to call from
+// other goroutines while an operation runs. The Appfile can be replaced
+// between operations with Reload.
 type Core struct {
 	appfile         *appfile.File
 	appfileCompiled *appfile.Compiled
+	source          appfileSource
 	apps            map[app.Tuple]app.Factory
+	appMiddleware   func(app.Tuple, app.Factory) app.Factory
 	dir             directory.Backend
 	infras          map[string]infrastructure.Factory
 	foundationMap   map[foundation.Tuple]foundation.Factory
 	dataDir         string
 	localDir        string
 	compileDir      string
+	requireDir      bool
 	ui              ui.Ui
 
+	credsProfileName string
+	credsMaxAttempts int
+	credsReenter     bool
+	credsMinEntropy  float64
+	infraCreds       map[string]string
+	onCredsUsed      func(string) error
+	walkHook         WalkHook
+	env              map[string]string
+	resourceLimits   *app.ResourceLimits
+	dirLayout        DirLayout
+	signer           Signer
+	root             string
+	forceRebuild     bool
+	projectDir       string
+	eventHistorySize int
+	followSymlink    bool
+	preserveCompile  bool
+	compileKeep      []string
+	compileScope     string
+	compileStore     CompileStore
+	requireFresh     bool
+	nonInteractive   bool
+	devHealthTimeout time.Duration
+	varSources       []VarSource
+	cacheKey         CacheKeyStrategy
+	concurrentInfra  bool
+	compilePhases    []CompilePhase
+	requiredVars     []string
+	clock            Clock
+	hasher           Hasher
+	remoteCache      RemoteCache
+	actorName        string
+	onFileProduced   func(string)
+	credsTracker     *credsTracker
+	jobs             *jobSet
+	extraRoots       []*appfile.Compiled
+	forest           *forest
+	tempDirRoot      string
+	tempGrace        time.Duration
+	tracer           Tracer
+	codec            Codec
+	cacheRetries     int
+	cacheBackoff     time.Duration
+
+	// plugins are the clients of the plugins started for PluginDir,
+	// which Close kills. Copies made with WithDirs don't own them, so
+	// this isn't copied.
+	plugins []*plugin.Client
+
+	// The fields below are state rather than configuration. When adding
+	// configuration above, also copy it in WithDirs.
+	busy          int32
+	lockID        string
+	stateLock     sync.RWMutex
 	metadataCache *CompileMetadata
+	metadataLock  sync.Mutex
+	events        eventLog
+	warnings      []Warning
+	warningsLock  sync.Mutex
+	varsCache     map[string]string
+	varsLock      sync.Mutex
+	span          Span
+	spanLock      sync.Mutex
+	vertexSpans   vertexSpans
+	flavors       infraFlavors
+
+	// scratch is true for the copies of a Core that DetectDrift and
+	// CompileTo compile with, so that they don't replace the stored hash
+	// of the canonical compilation.
+	scratch bool
 }
 
 // CoreConfig is configuration for creating a new core with NewCore.
@@ -48,21 +134,56 @@ type CoreConfig struct {
 	// will be stored. This isn't necessarilly cleared for compilation.
 	//
 	// CompiledDir is the directory where compiled data will be written.
-	// Each compilation will clear this directory.
+	// Each compilation will clear this directory, so NewCore returns an
+	// error if it is or contains the project, DataDir, or LocalDir.
+	//
+	// DirLayout determines the names of the directories within the
+	// CompileDir. If this is nil, DefaultDirLayout is used.
 	DataDir    string
 	LocalDir   string
 	CompileDir string
+	DirLayout  DirLayout
 
 	// Appfile is the appfile that this core will be using for configuration.
 	// This must be a compiled Appfile.
 	Appfile *appfile.Compiled
 
+	// Root, if set, overrides the application that is treated as the
+	// main application. This is the Otto ID or the name of any
+	// application in the Appfile's dependency graph. Only that
+	// application and its dependencies are used by the Core.
+	Root string
+
+	// ExtraRoots are other top-level applications, such as those of a
+	// monorepo, that Compile compiles in the same walk as the main
+	// application. A dependency that several of them share, identified
+	// by its Otto ID, is only compiled once. They are compiled with the
+	// infrastructure of the Appfile and their output is written like that
+	// of a dependency. The other operations only use the main
+	// application.
+	ExtraRoots []*appfile.Compiled
+
 	// Directory is the directory where data is stored about this Appfile.
 	Directory directory.Backend
 
+	// RequireDirectory, if true, makes every operation fail if the
+	// directory backend is unavailable. By default, operations that can
+	// proceed without persistent state (such as Compile) will continue
+	// with a warning instead.
+	RequireDirectory bool
+
 	// Apps is the map of available app implementations.
 	Apps map[app.Tuple]app.Factory
 
+	// FactoryMiddleware, if set, wraps the factory of every app
+	// implementation when it is resolved for a tuple, so that cross-cutting
+	// behavior such as logging, metrics, or timing can be added to every
+	// app without changing the factories. The App that the returned
+	// factory produces is the one Otto calls. Optional interfaces such as
+	// app.HealthChecker are detected on it, so a wrapper must implement
+	// the ones of the wrapped app that should keep working.
+	FactoryMiddleware func(app.Tuple, app.Factory) app.Factory
+
 	// Infrastructures is the map of available infrastructures. The
 	// value is a factory that can create the infrastructure impl.
 	Infrastructures map[string]infrastructure.Factory
@@ -71,6 +192,247 @@ type CoreConfig struct {
 	// value is a factory that can create the impl.
 	Foundations map[foundation.Tuple]foundation.Factory
 
+	// CredsProfile is the name of the credentials profile to use for
+	// the infrastructure. Multiple named profiles can be stored for a
+	// single infrastructure, for example to use different accounts. If
+	// this is blank, the "default" profile is used.
+	CredsProfile string
+
+	// CredsMaxAttempts is the number of times the user is asked for the
+	// password of the cached credentials if it is wrong. If this is zero,
+	// the user is asked once.
+	//
+	// CredsReenterOnFailure controls what happens when every attempt
+	// fails. If it is true, the user is asked to enter new credentials.
+	// Otherwise, an error is returned.
+	CredsMaxAttempts      int
+	CredsReenterOnFailure bool
+
+	// InfraCreds, if set, are the credentials for the infrastructure.
+	// They are used as-is: Otto doesn't read, ask for, or store any
+	// credentials on disk. They are still given to the infrastructure to
+	// verify. This is for embedding Otto in systems that manage their own
+	// secrets.
+	InfraCreds map[string]string
+
+	// OnCredsUsed, if set, is called with the name of the infrastructure
+	// every time its credentials are loaded for an operation. If it
+	// returns an error, the operation is stopped. This can be used to
+	// audit or gate the use of credentials. It never receives the
+	// credentials themselves.
+	OnCredsUsed func(infra string) error
+
+	// CredsMinPasswordEntropy, if set, is the minimum estimated entropy
+	// in bits of the password used to encrypt new credentials. The
+	// strength of the password is shown and the user is asked again if
+	// it is too weak. A password like "password" is about 38 bits.
+	CredsMinPasswordEntropy float64
+
+	// OnFileProduced, if set, is called with the path of every file in
+	// the compile output as it is produced during Compile, such as to
+	// show the files in an editor as they appear. The files of each app,
+	// infrastructure, and foundation are reported when its compilation
+	// is done. It is called once per file and never concurrently. It
+	// isn't called for the scratch compilations of CompileTo and
+	// DetectDrift.
+	OnFileProduced func(path string)
+
+	// WalkHook, if set, is notified as each application in the
+	// dependency graph is processed during Compile and Dev.
+	WalkHook WalkHook
+
+	// PluginDir, if set, is a directory that is scanned for plugin
+	// binaries when the Core is created. The app implementations they
+	// provide are added to Apps, without overriding any tuples that
+	// are already set. Plugins that fail to load are skipped. The plugin
+	// processes run until Core.Close is called.
+	// Infrastructures can't be provided by plugins yet.
+	PluginDir string
+
+	// Env are environment variables that are made available to app,
+	// infrastructure, and foundation implementations through their
+	// contexts. Implementations should set them on any subprocesses
+	// they execute, overriding the inherited environment.
+	Env map[string]string
+
+	// ResourceLimits, if set, are set on the context of every app so
+	// that the development environments can be constrained to them.
+	// Whether and how they are enforced depends on the app
+	// implementation.
+	ResourceLimits *app.ResourceLimits
+
+	// Signer, if set, is used to sign builds and to verify them before
+	// they're deployed.
+	Signer Signer
+
+	// FollowCompileDirSymlink controls what Compile does if CompileDir
+	// is a symbolic link. Since each compilation clears the directory,
+	// Compile refuses to run by default so that the link isn't replaced
+	// and the contents of its target aren't deleted unexpectedly. If
+	// this is true, the contents of the target are cleared instead and
+	// the compiled output is written there through the link.
+	FollowCompileDirSymlink bool
+
+	// PreserveCompileDir, if true, keeps the compile directory rather
+	// than deleting it for each compilation. Only the files that the
+	// previous compilation produced, according to its manifest, are
+	// deleted. Any other files are left in place and aren't part of the
+	// manifest, unless they're overwritten by the compilation. This allows
+	// mixing the compiled output with files that are maintained by hand.
+	PreserveCompileDir bool
+
+	// CompileScope is the dependency scope that Compile compiles, one of
+	// the appfile.DependencyScope constants. Dependencies that are only
+	// needed for another scope are skipped, such as the dev-only
+	// dependencies when compiling for a build with DependencyScopeBuild.
+	// Dev needs its dev dependencies compiled, so only use a build scope
+	// for Cores that don't run Dev. If this is blank, every dependency
+	// is compiled.
+	CompileScope string
+
+	// CompileKeep are glob patterns of files in the compile directory
+	// that are kept when it is deleted for each compilation, such as a
+	// ".gitkeep" or an override edited by hand. Patterns with a slash are
+	// matched against the slash-separated path relative to the compile
+	// directory, the others against the file name. It is an error if the
+	// compilation produces a file that matches, and the kept copies are
+	// left in the "keep" directory of DataDir then. This has no effect
+	// with PreserveCompileDir, which keeps such files anyway.
+	CompileKeep []string
+
+	// CompileStore, if set, stores the contents of the compiled files in
+	// a content-addressed store rather than the compile directory, which
+	// only keeps references to them. Files that are the same across
+	// projects are then stored once. They are materialized back into the
+	// compile directory by the operations that use them. If this is nil,
+	// the compiled output is written to the compile directory as is.
+	CompileStore CompileStore
+
+	// ProjectDir is the root directory of the project that is made
+	// available to implementations through their contexts. If this is
+	// empty, the directory of the Appfile is used.
+	ProjectDir string
+
+	// RequireFreshAppfile, if true, makes every operation fail if the
+	// source Appfile changed since the Appfile was compiled, since the
+	// Core would operate on the old version. By default, a warning is
+	// shown instead. See IsStale.
+	RequireFreshAppfile bool
+
+	// ForceRebuild, if true, ignores any cached results and rebuilds
+	// everything from scratch, such as the dev dependencies in Dev.
+	// This is useful if a cache is suspected to be corrupt.
+	ForceRebuild bool
+
+	// DevHealthTimeout is how long Dev waits for the development
+	// environment to pass the health check of the app, if it has one;
+	// see app.HealthChecker. If this is zero, DefaultDevHealthTimeout is
+	// used.
+	DevHealthTimeout time.Duration
+
+	// EventHistorySize is the number of recent events kept for
+	// EventHistory and Subscribe. If this is zero,
+	// DefaultEventHistorySize is used. If it is negative, no history
+	// is kept.
+	EventHistorySize int
+
+	// Tracer, if set, creates spans around the major operations, such as
+	// Compile, Build, and Dev, and around every application they walk.
+	// If this is nil, NoopTracer is used.
+	Tracer Tracer
+
+	// Codec encodes the records that the Core stores in the directory,
+	// such as the audit log. If this is nil, JSONCodec is used. See
+	// Codec.
+	Codec Codec
+
+	// TempDir is the directory that temporary directories are created
+	// in, such as the one DetectDrift compiles into. Their names start
+	// with "otto-" followed by the ID of the Appfile. If this is empty,
+	// the default temporary directory of the system is used.
+	//
+	// TempGracePeriod is how old the temporary directories of this
+	// Appfile must be for Compile to remove them, as left behind by
+	// interrupted runs. If this is zero, DefaultTempGracePeriod is used.
+	// If this is negative, they are never removed.
+	TempDir         string
+	TempGracePeriod time.Duration
+
+	// Clock, if set, is the source of the current time for everything
+	// the Core timestamps, such as events and locks. If this is nil,
+	// RealClock is used. Tests can set this to control time.
+	Clock Clock
+
+	// ConcurrentInfraCompile, if true, compiles the infrastructure at
+	// the same time as the foundations and apps rather than before them.
+	// This makes compilation faster, but must only be set if none of the
+	// apps use the compiled output of the infrastructure.
+	ConcurrentInfraCompile bool
+
+	// CompilePhaseDeps orders the phases of Compile. It is keyed by phase
+	// and the values are the phases that must be compiled before it. If
+	// this is nil, DefaultCompilePhaseDeps is used. Use
+	// AppsBeforeInfraCompilePhaseDeps for an infrastructure that is
+	// generated from the apps. The phases can't depend on each other in
+	// a cycle, and ConcurrentInfraCompile can't be set if the
+	// infrastructure depends on the apps.
+	CompilePhaseDeps map[CompilePhase][]CompilePhase
+
+	// CacheKey determines the key that the cached data of each
+	// dependency is stored under in the DataDir, so that the cache can
+	// be shared between projects that use the same dependency. If this
+	// is nil, IDCacheKey is used. See ContentCacheKey.
+	CacheKey CacheKeyStrategy
+
+	// RemoteCache, if set, is a cache of dev dependencies shared with
+	// other machines that Dev reads from and writes to in addition to
+	// the cache in the DataDir. See RemoteCache.
+	RemoteCache RemoteCache
+
+	// CacheReadRetries is the number of times Dev retries reading a
+	// cached dev dependency, locally or from the RemoteCache, after a
+	// transient error such as a network failure before building the
+	// dependency instead. A dependency that isn't cached is never
+	// retried. CacheReadBackoff is the wait before the first retry,
+	// which doubles after each one. If it is zero,
+	// DefaultCacheReadBackoff is used.
+	CacheReadRetries int
+	CacheReadBackoff time.Duration
+
+	// Actor identifies who is running the operations in the audit log,
+	// such as a user name or the name of a CI job. If this is empty,
+	// the name of the current user is used. See Core.AuditLog.
+	Actor string
+
+	// Hasher is the hash algorithm used to fingerprint compiled output
+	// and cached data. If this is nil, SHA256Hasher is used. See Hasher
+	// for the implications of choosing a non-cryptographic hash.
+	Hasher Hasher
+
+	// VarSources are the sources of the variables that are given to the
+	// implementations through their contexts. They're read in order, so
+	// later sources override the values of earlier ones. The variables
+	// are resolved again for each compilation.
+	//
+	// RequiredVars are the names of the variables that must have a
+	// value. Compilation fails early if any of them doesn't.
+	VarSources   []VarSource
+	RequiredVars []string
+
+	// InputAnswers are canned answers to the questions asked of the
+	// user, keyed by the Id of the input, such as "creds_password".
+	// Questions without an answer are asked through Ui.
+	InputAnswers map[string]string
+
+	// NonInteractive, if true, means nobody can answer the questions
+	// asked through Ui, such as when Otto runs as a daemon. Questions are
+	// then answered from InputAnswers or the environment variables of the
+	// input, and an operation fails before asking anything if any of the
+	// values it needs is missing, with an error listing all of them.
+	// This is also the case if Ui reports that it isn't interactive,
+	// such as ui.Null.
+	NonInteractive bool
+
 	// Ui is the Ui that will be used to communicate with the user.
 	Ui ui.Ui
 }
@@ -79,25 +441,327 @@ type CoreConfig struct {
 //
 // Once this function is called, this CoreConfig should not be used again
 // or modified, since the Core may use parts of it without deep copying.
-func NewCore(c *CoreConfig) (*Core, error) {
-	return &Core{
-		appfile:         c.Appfile.File,
-		appfileCompiled: c.Appfile,
+func NewCore(c *CoreConfig) (_ *Core, err error) {
+	var plugins []*plugin.Client
+	if c.PluginDir != "" {
+		if c.Apps == nil {
+			c.Apps = make(map[app.Tuple]app.Factory)
+		}
+
+		plugins, err = discoverPlugins(c.PluginDir, c.Apps)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Error discovering plugins in %s: %s", c.PluginDir, err)
+		}
+
+		// The plugins are only used by this Core
+		defer func() {
+			if err != nil {
+				killPlugins(plugins)
+			}
+		}()
+	}
+
+	layout := c.DirLayout
+	if layout == nil {
+		layout = DefaultDirLayout{}
+	}
+
+	clock := c.Clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+
+	tracer := c.Tracer
+	if tracer == nil {
+		tracer = NoopTracer{}
+	}
+
+	codec := c.Codec
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+
+	hasher := c.Hasher
+	if hasher == nil {
+		hasher = SHA256Hasher{}
+	}
+
+	cacheKey := c.CacheKey
+	if cacheKey == nil {
+		cacheKey = IDCacheKey{}
+	}
+	if k, ok := cacheKey.(ContentCacheKey); ok {
+		if k.Hasher == nil {
+			k.Hasher = hasher
+		}
+		if k.Incremental {
+			k.hashCache = fileHashCachePath(c.DataDir)
+		}
+		cacheKey = k
+	}
+
+	phaseDeps := c.CompilePhaseDeps
+	if phaseDeps == nil {
+		phaseDeps = DefaultCompilePhaseDeps
+	}
+	phases, err := compilePhaseOrder(phaseDeps)
+	if err != nil {
+		return nil, err
+	}
+	if c.ConcurrentInfraCompile && len(phaseDeps[CompilePhaseInfra]) > 0 {
+		return nil, fmt.Errorf(
+			"ConcurrentInfraCompile can't be set when the infrastructure " +
+				"compile depends on other phases")
+	}
+	switch c.CompileScope {
+	case appfile.DependencyScopeAll, appfile.DependencyScopeDev, appfile.DependencyScopeBuild:
+	default:
+		return nil, fmt.Errorf("invalid CompileScope: %q", c.CompileScope)
+	}
+
+	u := c.Ui
+	nonInteractive := c.NonInteractive || (u != nil && !ui.IsInteractive(u))
+	if _, ok := u.(*ui.NonInteractive); nonInteractive && !ok {
+		u = &ui.NonInteractive{Ui: u}
+	}
+	if len(c.InputAnswers) > 0 {
+		u = &ui.Canned{Ui: u, Answers: c.InputAnswers}
+	}
+
+	compiled := c.Appfile
+	if c.Root != "" {
+		var err error
+		compiled, err = compiledWithRoot(compiled, c.Root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	core := &Core{
+		appfile:         compiled.File,
+		appfileCompiled: compiled,
+		source:          sourceOf(c.Appfile),
 		apps:            c.Apps,
+		appMiddleware:   c.FactoryMiddleware,
 		dir:             c.Directory,
 		infras:          c.Infrastructures,
 		foundationMap:   c.Foundations,
 		dataDir:         c.DataDir,
 		localDir:        c.LocalDir,
 		compileDir:      c.CompileDir,
-		ui:              c.Ui,
-	}, nil
+		requireDir:      c.RequireDirectory,
+		ui:              u,
+
+		credsProfileName: c.CredsProfile,
+		credsMaxAttempts: c.CredsMaxAttempts,
+		credsReenter:     c.CredsReenterOnFailure,
+		credsMinEntropy:  c.CredsMinPasswordEntropy,
+		infraCreds:       c.InfraCreds,
+		onCredsUsed:      c.OnCredsUsed,
+		walkHook:         c.WalkHook,
+		env:              c.Env,
+		resourceLimits:   c.ResourceLimits,
+		dirLayout:        layout,
+		signer:           c.Signer,
+		root:             c.Root,
+		forceRebuild:     c.ForceRebuild,
+		projectDir:       c.ProjectDir,
+		eventHistorySize: c.EventHistorySize,
+		followSymlink:    c.FollowCompileDirSymlink,
+		preserveCompile:  c.PreserveCompileDir,
+		compileKeep:      c.CompileKeep,
+		compileScope:     c.CompileScope,
+		compileStore:     c.CompileStore,
+		requireFresh:     c.RequireFreshAppfile,
+		nonInteractive:   nonInteractive,
+		devHealthTimeout: c.DevHealthTimeout,
+		varSources:       c.VarSources,
+		cacheKey:         cacheKey,
+		concurrentInfra:  c.ConcurrentInfraCompile,
+		compilePhases:    phases,
+		requiredVars:     c.RequiredVars,
+		clock:            clock,
+		hasher:           hasher,
+		remoteCache:      c.RemoteCache,
+		cacheRetries:     c.CacheReadRetries,
+		cacheBackoff:     c.CacheReadBackoff,
+		actorName:        c.Actor,
+		onFileProduced:   c.OnFileProduced,
+		credsTracker:     new(credsTracker),
+		jobs:             new(jobSet),
+		extraRoots:       c.ExtraRoots,
+		tempDirRoot:      c.TempDir,
+		tempGrace:        c.TempGracePeriod,
+		tracer:           tracer,
+		codec:            codec,
+		plugins:          plugins,
+	}
+
+	// Catch directories that would be deleted along with the compile
+	// directory now rather than on the first compilation.
+	if core.compileDir != "" {
+		if err := core.checkCompileDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.ExtraRoots) > 0 {
+		core.forest, err = newForest(compiled, c.ExtraRoots)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return core, nil
+}
+
+// CompiledAppfileDir is the directory within the LocalDir where
+// NewCoreFromFile stores the compiled Appfile.
+const CompiledAppfileDir = "appfile"
+
+// NewCoreFromFile creates a new core from an Appfile that hasn't been
+// compiled yet. The Appfile is compiled, fetching its dependencies, into
+// the CompiledAppfileDir directory of the LocalDir so that it can later
+// be loaded again with appfile.LoadCompiled. The Appfile field of the
+// CoreConfig is ignored.
+func NewCoreFromFile(f *appfile.File, c *CoreConfig) (*Core, error) {
+	if f == nil {
+		return nil, fmt.Errorf("an Appfile is required")
+	}
+
+	compiler, err := appfile.NewCompiler(&appfile.CompileOpts{
+		Dir: filepath.Join(c.LocalDir, CompiledAppfileDir),
+	})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error initializing Appfile compiler: %s", err)
+	}
+
+	compiled, err := compiler.Compile(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling Appfile: %s", err)
+	}
+
+	config := *c
+	config.Appfile = compiled
+	return NewCore(&config)
+}
+
+// WithDirs returns a copy of this Core that uses the given data, local,
+// and compile directories. Empty values keep the directory of this Core.
+// This is cheaper than creating a new Core when operating on many
+// projects with the same configuration.
+//
+// The copy shares the configuration of this Core, such as the Appfile
+// and the implementations. It has its own compile metadata cache and
+// tracks the credentials it uses on its own. The set of background jobs
+// is shared on purpose, so that CancelAll on either cancels the jobs of
+// both.
+//
+// Operations take a lock file in the local directory, so the copy can
+// only run operations at the same time as this Core if its localDir is
+// different. Otherwise they fail with a *LockError while the other holds
+// the lock.
+func (c *Core) WithDirs(dataDir, localDir, compileDir string) *Core {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if dataDir == "" {
+		dataDir = c.dataDir
+	}
+	if localDir == "" {
+		localDir = c.localDir
+	}
+	if compileDir == "" {
+		compileDir = c.compileDir
+	}
+
+	// The file hash cache of an incremental content key is kept in the
+	// data directory
+	cacheKey := c.cacheKey
+	if k, ok := cacheKey.(ContentCacheKey); ok && k.hashCache != "" {
+		k.hashCache = fileHashCachePath(dataDir)
+		cacheKey = k
+	}
+
+	return &Core{
+		appfile:         c.appfile,
+		appfileCompiled: c.appfileCompiled,
+		source:          c.source,
+		apps:            c.apps,
+		appMiddleware:   c.appMiddleware,
+		dir:             c.dir,
+		infras:          c.infras,
+		foundationMap:   c.foundationMap,
+		dataDir:         dataDir,
+		localDir:        localDir,
+		compileDir:      compileDir,
+		requireDir:      c.requireDir,
+		ui:              c.ui,
+
+		credsProfileName: c.credsProfileName,
+		credsMaxAttempts: c.credsMaxAttempts,
+		credsReenter:     c.credsReenter,
+		credsMinEntropy:  c.credsMinEntropy,
+		infraCreds:       c.infraCreds,
+		onCredsUsed:      c.onCredsUsed,
+		walkHook:         c.walkHook,
+		env:              c.env,
+		resourceLimits:   c.resourceLimits,
+		dirLayout:        c.dirLayout,
+		signer:           c.signer,
+		root:             c.root,
+		forceRebuild:     c.forceRebuild,
+		projectDir:       c.projectDir,
+		eventHistorySize: c.eventHistorySize,
+		followSymlink:    c.followSymlink,
+		preserveCompile:  c.preserveCompile,
+		compileKeep:      c.compileKeep,
+		compileScope:     c.compileScope,
+		compileStore:     c.compileStore,
+		requireFresh:     c.requireFresh,
+		nonInteractive:   c.nonInteractive,
+		devHealthTimeout: c.devHealthTimeout,
+		varSources:       c.varSources,
+		cacheKey:         cacheKey,
+		hasher:           c.hasher,
+		remoteCache:      c.remoteCache,
+		cacheRetries:     c.cacheRetries,
+		cacheBackoff:     c.cacheBackoff,
+		actorName:        c.actorName,
+		onFileProduced:   c.onFileProduced,
+		credsTracker:     new(credsTracker),
+		jobs:             c.jobs,
+		extraRoots:       c.extraRoots,
+		forest:           c.forest,
+		tempDirRoot:      c.tempDirRoot,
+		tempGrace:        c.tempGrace,
+		tracer:           c.tracer,
+		codec:            c.codec,
+		concurrentInfra:  c.concurrentInfra,
+		compilePhases:    c.compilePhases,
+		requiredVars:     c.requiredVars,
+		clock:            c.clock,
+	}
+}
+
+// Close stops the plugin processes that were started for
+// CoreConfig.PluginDir. Copies made with WithDirs use the same plugins,
+// so they must not be used after this is called.
+func (c *Core) Close() error {
+	killPlugins(c.plugins)
+	c.plugins = nil
+	return nil
 }
 
 // App returns the app implementation and context for this configured Core.
 //
 // If App implements io.Closer, it is up to the caller to call Close on it.
 func (c *Core) App() (app.App, *app.Context, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
 	root, err := c.appfileCompiled.Graph.Root()
 	if err != nil {
 		return nil, nil, err
@@ -116,12 +780,106 @@ func (c *Core) App() (app.App, *app.Context, error) {
 	return rootApp, rootCtx, nil
 }
 
+// RootTuple returns the tuple of the main application. This has no
+// side effects and can be used to inspect the Appfile prior to calling
+// any other operation.
+func (c *Core) RootTuple() (app.Tuple, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return app.Tuple{}, err
+	}
+
+	return c.appTuple(root.(*appfile.CompiledGraphVertex).File)
+}
+
+// ActiveInfra returns the configuration of the infrastructure that is
+// active for this Appfile. This has no side effects.
+func (c *Core) ActiveInfra() (*appfile.Infrastructure, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	config := c.appfile.ActiveInfrastructure()
+	if config == nil {
+		return nil, errInfraNotFound(c.appfile, c.appfile.Project.Infrastructure)
+	}
+
+	return config, nil
+}
+
 // Compile takes the Appfile and compiles all the resulting data.
+//
+// The compile directory is deleted and recreated for every compilation,
+// unless CoreConfig.PreserveCompileDir is set. If it is a symbolic link,
+// see CoreConfig.FollowCompileDirSymlink.
 func (c *Core) Compile() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	return c.compile()
+}
+
+// CompileTo is like Compile but writes the compiled output to dir rather
+// than the configured compile directory, which is left untouched. This
+// is useful to produce the output in a scratch location, for example to
+// compare it to the current output with CompileDiff.
+//
+// The directory is cleared first, with the same checks as Compile. The
+// pre-compile and post-compile commands of the project aren't run, and
+// CoreConfig.OnFileProduced isn't called.
+func (c *Core) CompileTo(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("A directory to compile to must be given.")
+	}
+
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	other := c.WithDirs("", "", dir)
+	other.scratch = true
+	return other.compile()
+}
+
+// compile does the work of Compile. The caller must hold the lock.
+func (c *Core) compile() (err error) {
+	_, end := c.startSpan(SpanCompile, nil)
+	defer func() { end(err) }()
+
 	// md stores the metadata about the compilation. This is only written
 	// on a successful compile.
 	var md CompileMetadata
 
+	// Clean up after interrupted runs. Scratch compiles are part of other
+	// operations, such as DetectDrift, and leave this to Compile.
+	if !c.scratch {
+		c.sweepTempDirs()
+	}
+
+	// Resolve the variables now so that a missing one fails before
+	// anything is compiled.
+	c.resetVars()
+	if _, err := c.vars(); err != nil {
+		return err
+	}
+
+	// The project can prepare the inputs of the compilation. Scratch
+	// compiles are previews that must not have side effects, so they
+	// don't run the commands.
+	var preCmds, postCmds []string
+	if c.appfile.Project != nil && !c.scratch {
+		preCmds = c.appfile.Project.PreCompile
+		postCmds = c.appfile.Project.PostCompile
+	}
+	if err := c.runCompileCommands("pre-compile", preCmds); err != nil {
+		return err
+	}
+
 	// Get the infra implementation for this
 	infra, infraCtx, err := c.infra()
 	if err != nil {
@@ -139,146 +897,359 @@ func (c *Core) Compile() error {
 		defer maybeClose(f)
 	}
 
-	// Delete the prior output directory
-	log.Printf("[INFO] deleting prior compilation contents: %s", c.compileDir)
-	if err := os.RemoveAll(c.compileDir); err != nil {
+	// Compilation doesn't require persistent state, so we only fail
+	// here if we've been configured to be strict about the directory.
+	if err := c.checkDirectory(c.requireDir); err != nil {
+		return err
+	}
+
+	// Stash the files we're configured to keep before they're deleted,
+	// and put them back if the compilation fails.
+	keep, err := c.stashKeptFiles()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil && keep != nil {
+			keep.restoreOnError(c)
+		}
+	}()
+
+	// Delete the prior output directory, or only the files we produced
+	// if we're preserving it. kept are the other files, which aren't
+	// part of the output.
+	var kept map[string]string
+	if c.preserveCompile {
+		log.Printf("[INFO] deleting prior compiled files: %s", c.compileDir)
+		kept, err = c.removeCompiledFiles()
+	} else {
+		log.Printf("[INFO] deleting prior compilation contents: %s", c.compileDir)
+		err = c.clearCompileDir()
+	}
+	if err != nil {
 		return err
 	}
 
 	// Reset the metadata cache so we don't have that
 	c.resetCompileMetadata()
 
-	// Compile the infrastructure for our application
-	log.Printf("[INFO] running infra compile...")
-	c.ui.Message("Compiling infra...")
-	infraResult, err := infra.Compile(infraCtx)
-	if err != nil {
+	// produced reports the compiled files as they are written. Scratch
+	// compilations don't write the compile output, so they report nothing.
+	var onProduced func(string)
+	if !c.scratch {
+		onProduced = c.onFileProduced
+	}
+	produced := newProducedFiles(onProduced)
+
+	// Compile the infrastructure for our application. Apps can require
+	// outputs from it, which are verified before they're compiled if the
+	// infrastructure was compiled first, and once it is otherwise.
+	var infraWg sync.WaitGroup
+	var infraErr error
+	var infraDone bool
+	var outputs infraOutputs
+	compileInfra := func() (err error) {
+		span := c.startChildSpan(SpanCompileInfra, map[string]string{
+			SpanAttrInfra: infraCtx.Infra.Name,
+		})
+		defer func() { span.End(err) }()
+
+		log.Printf("[INFO] running infra compile...")
+		c.ui.Message("Compiling infra...")
+		infraResult, err := infra.Compile(infraCtx)
+		produced.dir(infraCtx.Dir)
+		md.Infra = infraResult
+		if infraResult != nil {
+			for _, w := range infraResult.Warnings {
+				c.warn(WarningSourceInfra, "", w)
+			}
+		}
+
 		return err
 	}
-	md.Infra = infraResult
 
-	// Compile the foundation (not tied to any app). This compilation
-	// of the foundation is used for `otto infra` to set everything up.
-	log.Printf("[INFO] running foundation compilations")
-	md.Foundations = make(map[string]*foundation.CompileResult, len(foundations))
-	for i, f := range foundations {
-		ctx := foundationCtxs[i]
-		c.ui.Message(fmt.Sprintf(
-			"Compiling foundation: %s", ctx.Tuple.Type))
-		result, err := f.Compile(ctx)
+	// Compile the foundations and apps.
+	compileApps := func() error {
+		// Compile the foundation (not tied to any app). This compilation
+		// of the foundation is used for `otto infra` to set everything up.
+		log.Printf("[INFO] running foundation compilations")
+		md.Foundations = make(map[string]*foundation.CompileResult, len(foundations))
+		for i, f := range foundations {
+			ctx := foundationCtxs[i]
+			c.ui.Message(fmt.Sprintf(
+				"Compiling foundation: %s", ctx.Tuple.Type))
+			result, err := f.Compile(ctx)
+			produced.dir(ctx.Dir)
+			if err != nil {
+				return err
+			}
+			if result != nil {
+				for _, w := range result.Warnings {
+					c.warn(WarningSourceFoundation, "", w)
+				}
+			}
+
+			md.Foundations[ctx.Tuple.Type] = result
+		}
+
+		fo, err := c.compileForest()
 		if err != nil {
 			return err
 		}
 
-		md.Foundations[ctx.Tuple.Type] = result
-	}
+		// Walk through the dependencies in the configured scope and
+		// compile them. By default that is all of them, since the
+		// compiled output is used for every operation.
+		//
+		// The extra roots are compiled in the same walk, each shared
+		// dependency once. Their results are stored like dependencies.
+		var mdLock sync.Mutex
+		md.AppDeps = make(map[string]*app.CompileResult)
+		depNames := make(map[string]string)
+		return c.walkForest(fo, c.compileScope, func(app app.App, ctx *app.Context, root bool) error {
+			main := ctx.Appfile.ID == c.appfile.ID
+			if !root {
+				c.ui.Header(fmt.Sprintf(
+					"Compiling dependency '%s'...",
+					ctx.Appfile.Application.Name))
+			} else if !main {
+				c.ui.Header(fmt.Sprintf(
+					"Compiling application '%s'...",
+					ctx.Appfile.Application.Name))
+			} else {
+				c.ui.Header(fmt.Sprintf(
+					"Compiling main application..."))
+			}
 
-	// Walk through the dependencies and compile all of them.
-	// We have to compile every dependency for dev building.
-	var mdLock sync.Mutex
-	md.AppDeps = make(map[string]*app.CompileResult)
-	err = c.walk(func(app app.App, ctx *app.Context, root bool) error {
-		if !root {
-			c.ui.Header(fmt.Sprintf(
-				"Compiling dependency '%s'...",
-				ctx.Appfile.Application.Name))
-		} else {
-			c.ui.Header(fmt.Sprintf(
-				"Compiling main application..."))
-		}
+			// If this is a root, we set the dev dep fragments of the
+			// dependencies that it has.
+			if root {
+				// We grab the lock just in case although if we're the
+				// only root this should be serialized.
+				mdLock.Lock()
+
+				// The results were collected in parallel, so sort them by
+				// ID to give the fragments a stable order. Otherwise the
+				// compiled output could change between runs.
+				ids := make([]string, 0, len(md.AppDeps))
+				for id := range md.AppDeps {
+					ids = append(ids, id)
+				}
+				sort.Strings(ids)
+
+				ctx.DevDepFragments = make([]string, 0, len(md.AppDeps))
+				for _, id := range ids {
+					path := md.AppDeps[id].DevDepFragmentPath
+					if path == "" || !fo.Feeds(id, ctx.Appfile.ID) {
+						continue
+					}
+
+					// Verify the fragment actually exists so that a bad
+					// dependency doesn't cause a confusing root failure.
+					if _, err := os.Stat(path); err != nil {
+						mdLock.Unlock()
+						return fmt.Errorf(
+							"Dependency '%s' reported a dev dependency fragment at\n"+
+								"'%s', but it couldn't be read: %s",
+							depNames[id], path, err)
+					}
+
+					ctx.DevDepFragments = append(ctx.DevDepFragments, path)
+				}
+				mdLock.Unlock()
+			}
 
-		// If this is the root, we set the dev dep fragments.
-		if root {
-			// We grab the lock just in case although if we're the
-			// root this should be serialized.
-			mdLock.Lock()
-			ctx.DevDepFragments = make([]string, 0, len(md.AppDeps))
-			for _, result := range md.AppDeps {
-				if result.DevDepFragmentPath != "" {
-					ctx.DevDepFragments = append(
-						ctx.DevDepFragments, result.DevDepFragmentPath)
+			// Compile the foundations for this app
+			subdirs := []string{"app-dev", "app-dev-dep", "app-build", "app-deploy"}
+			for i, f := range foundations {
+				fCtx := foundationCtxs[i]
+				fCtx.Dir = ctx.FoundationDirs[i]
+
+				if _, err := f.Compile(fCtx); err != nil {
+					return err
+				}
+
+				// Make sure the subdirs exist
+				for _, dir := range subdirs {
+					if err := os.MkdirAll(filepath.Join(fCtx.Dir, dir), 0755); err != nil {
+						return err
+					}
 				}
 			}
-			mdLock.Unlock()
-		}
 
-		// Compile the foundations for this app
-		subdirs := []string{"app-dev", "app-dev-dep", "app-build", "app-deploy"}
-		for i, f := range foundations {
-			fCtx := foundationCtxs[i]
-			fCtx.Dir = ctx.FoundationDirs[i]
+			if required := requiredInfraOutputs(app); len(required) > 0 {
+				name := ctx.Appfile.Application.Name
+				if !infraDone {
+					outputs.later(name, required)
+				} else if err := checkInfraOutputs(
+					name, infraCtx.Infra.Name, required,
+					outputs.get(c, &md, infraCtx.Infra.Name)); err != nil {
+					return err
+				}
+			}
 
-			if _, err := f.Compile(fCtx); err != nil {
+			// Compile!
+			result, err := app.Compile(ctx)
+			produced.dir(ctx.Dir)
+			if err != nil {
 				return err
 			}
+			if result != nil {
+				for _, w := range result.Warnings {
+					c.warn(WarningSourceApp, ctx.Appfile.Application.Name, w)
+				}
+			}
+
+			// Compile the foundations for this app
+			for i, f := range foundations {
+				fCtx := foundationCtxs[i]
+				fCtx.Dir = ctx.FoundationDirs[i]
+				if result != nil {
+					fCtx.AppConfig = &result.FoundationConfig
+				}
 
-			// Make sure the subdirs exist
-			for _, dir := range subdirs {
-				if err := os.MkdirAll(filepath.Join(fCtx.Dir, dir), 0755); err != nil {
+				if _, err := f.Compile(fCtx); err != nil {
 					return err
 				}
-			}
-		}
 
-		// Compile!
-		result, err := app.Compile(ctx)
-		if err != nil {
-			return err
-		}
+				// Make sure the subdirs exist
+				for _, dir := range subdirs {
+					if err := os.MkdirAll(filepath.Join(fCtx.Dir, dir), 0755); err != nil {
+						return err
+					}
+				}
 
-		// Compile the foundations for this app
-		for i, f := range foundations {
-			fCtx := foundationCtxs[i]
-			fCtx.Dir = ctx.FoundationDirs[i]
-			if result != nil {
-				fCtx.AppConfig = &result.FoundationConfig
+				produced.dir(fCtx.Dir)
 			}
 
-			if _, err := f.Compile(fCtx); err != nil {
-				return err
+			// Store the compilation result in the metadata
+			mdLock.Lock()
+			defer mdLock.Unlock()
+
+			if main {
+				md.App = result
+			} else {
+				// Don't store the result if its nil because it is pointless
+				if result != nil {
+					md.AppDeps[ctx.Appfile.ID] = result
+					depNames[ctx.Appfile.ID] = ctx.Appfile.Application.Name
+				}
 			}
 
-			// Make sure the subdirs exist
-			for _, dir := range subdirs {
-				if err := os.MkdirAll(filepath.Join(fCtx.Dir, dir), 0755); err != nil {
+			return nil
+		})
+	}
+
+	// Run the phases in the configured order. If we're allowed to, the
+	// infrastructure is compiled while the apps are and we wait for it
+	// before finishing. We always wait so it isn't left running if
+	// something else fails.
+	var appsDone bool
+	for _, phase := range c.compilePhases {
+		switch phase {
+		case CompilePhaseInfra:
+			if appsDone {
+				infraCtx.AppFragments = infraFragments(&md)
+			}
+			if c.concurrentInfra {
+				infraWg.Add(1)
+				go func() {
+					defer infraWg.Done()
+					infraErr = compileInfra()
+				}()
+				defer infraWg.Wait()
+			} else {
+				if err := compileInfra(); err != nil {
 					return err
 				}
+				infraDone = true
+			}
+		case CompilePhaseApps:
+			if err := compileApps(); err != nil {
+				return err
 			}
+			appsDone = true
 		}
+	}
 
-		// Store the compilation result in the metadata
-		mdLock.Lock()
-		defer mdLock.Unlock()
+	infraWg.Wait()
+	if infraErr != nil {
+		return infraErr
+	}
+	if err := outputs.checkPending(c, &md, infraCtx.Infra.Name); err != nil {
+		return err
+	}
 
-		if root {
-			md.App = result
-		} else {
-			// Don't store the result if its nil because it is pointless
-			if result != nil {
-				md.AppDeps[ctx.Appfile.ID] = result
-			}
+	// Restore the kept files, which aren't part of the output either
+	if keep != nil {
+		if kept == nil {
+			kept = make(map[string]string)
 		}
 
-		return nil
-	})
+		err := keep.restore(c, kept)
+		keep = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	// Record everything we produced so it can be verified later
+	manifest, err := c.saveManifest(kept)
 	if err != nil {
+		return fmt.Errorf("Error writing compilation manifest: %s", err)
+	}
+
+	// Run the post-compile commands before recording anything that says
+	// the compilation succeeded, since it fails if they do.
+	if err := c.runCompileCommands("post-compile", postCmds); err != nil {
 		return err
 	}
+	if !c.scratch {
+		// The directory isn't required for compilation, so this is only
+		// used for DetectDrift later if it works.
+		if err := c.putCompileHash(manifest.HashWith(c.hasher)); err != nil {
+			log.Printf("[WARN] error storing compilation hash: %s", err)
+		}
+	}
 
 	// We had no compilation errors! Let's save the metadata
-	return c.saveCompileMetadata(&md)
+	if err := c.saveCompileMetadata(&md); err != nil {
+		return err
+	}
+	produced.file(filepath.Join(c.compileDir, manifestFilename))
+	produced.file(filepath.Join(c.compileDir, "metadata.json"))
+
+	// Move the compiled files to the store last, since the steps above
+	// read them.
+	return c.storeCompiled(manifest)
 }
 
-func (c *Core) walk(f func(app.App, *app.Context, bool) error) error {
-	root, err := c.appfileCompiled.Graph.Root()
+// walk calls f for every app in the dependency graph that is in the
+// given scope, dependencies before the apps that depend on them.
+func (c *Core) walk(scope string, f func(app.App, *app.Context, bool) error) error {
+	fo, err := c.mainForest()
 	if err != nil {
-		return fmt.Errorf(
-			"Error loading app: %s", err)
+		return err
 	}
 
+	return c.walkForest(fo, scope, f)
+}
+
+// walkForest is walk for every root of the forest. f is told whether
+// the app is one of the roots.
+func (c *Core) walkForest(
+	fo *forest, scope string, f func(app.App, *app.Context, bool) error) error {
+	// Track the progress so we can estimate the remaining time
+	progress := newWalkProgress(c.ui, c.dataDir, c.walkTuples(fo.Graph, scope))
+	defer func() {
+		if err := progress.Save(); err != nil {
+			log.Printf("[WARN] error saving timings: %s", err)
+		}
+	}()
+
 	// Walk the appfile graph.
 	var stop int32 = 0
-	return c.appfileCompiled.Graph.Walk(func(raw dag.Vertex) (err error) {
+	return fo.Graph.Walk(func(raw dag.Vertex) (err error) {
 		// If we're told to stop (something else had an error), then stop early.
 		// Graphs walks by default will complete all disjoint parts of the
 		// graph before failing, but Otto doesn't have to do that.
@@ -286,16 +1257,65 @@ func (c *Core) walk(f func(app.App, *app.Context, bool) error) error {
 			return nil
 		}
 
-		// If we exit with an error, then mark the stop atomic
+		// Skip the apps that aren't needed for this operation
+		if v := raw.(*appfile.CompiledGraphVertex); !v.InScope(scope) {
+			log.Printf(
+				"[DEBUG] core: skipping '%s', only needed for scope '%s'",
+				dag.VertexName(raw), v.Scope)
+			return nil
+		}
+
+		start := c.clock.Now()
+		defer func() {
+			progress.Done(raw, c.clock.Now().Sub(start), err)
+		}()
+
+		// If we exit with an error, then mark the stop atomic and
+		// annotate the error with how we got to this vertex.
 		defer func() {
 			if err != nil {
 				atomic.StoreInt32(&stop, 1)
+				err = &WalkError{
+					Name: dag.VertexName(raw),
+					Path: fo.Path(raw),
+					Err:  err,
+				}
 			}
 		}()
 
 		// Convert to the rich vertex type so that we can access data
 		v := raw.(*appfile.CompiledGraphVertex)
 
+		// Notify the hook and subscribers that we're starting and when
+		// we're done. The tuple may be empty if the Appfile is invalid,
+		// but that error will be reported below.
+		tuple, _ := c.appTuple(v.File)
+		name := v.File.Application.Name
+		span := c.startChildSpan(SpanVertex, map[string]string{
+			SpanAttrAppfileID: v.File.ID,
+			SpanAttrApp:       name,
+			SpanAttrTuple:     tuple.String(),
+		})
+		c.vertexSpans.put(v.File.ID, span)
+		defer func() {
+			c.vertexSpans.put(v.File.ID, nil)
+			span.End(err)
+		}()
+		c.event(CoreEvent{Type: CoreEventVertexStart, Tuple: tuple, Name: name})
+		defer func() {
+			e := CoreEvent{Type: CoreEventVertexDone, Tuple: tuple, Name: name}
+			if err != nil {
+				e.Error = err.Error()
+			}
+			c.event(e)
+		}()
+		if c.walkHook != nil {
+			c.walkHook.OnVertexStart(tuple, name)
+			defer func() {
+				c.walkHook.OnVertexDone(tuple, name, err)
+			}()
+		}
+
 		// Do some logging to help ourselves out
 		log.Printf("[DEBUG] core walking app: %s", v.File.Application.Name)
 
@@ -315,13 +1335,36 @@ func (c *Core) walk(f func(app.App, *app.Context, bool) error) error {
 		defer maybeClose(app)
 
 		// Call our callback
-		return f(app, appCtx, raw == root)
+		return f(app, appCtx, fo.IsRoot(raw))
 	})
 }
 
 // Build builds the deployable artifact for the currently compiled
-// Appfile.
+// Appfile. See BuildAsync to build in the background.
 func (c *Core) Build() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	err := c.build(nil)
+	c.audit("build", "", err)
+	return err
+}
+
+// build builds the main application. If cancelCh is closed, the build
+// stops at the next opportunity with ErrCanceled. The lock must be held.
+func (c *Core) build(cancelCh <-chan struct{}) (err error) {
+	_, end := c.startSpan(SpanBuild, nil)
+	defer func() { end(err) }()
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
 	// Get the infra implementation for this
 	infra, infraCtx, err := c.infra()
 	if err != nil {
@@ -331,6 +1374,9 @@ func (c *Core) Build() error {
 		return err
 	}
 	defer maybeClose(infra)
+	if isCanceled(cancelCh) {
+		return ErrCanceled
+	}
 
 	// We only use the root application for this task, upstream dependencies
 	// don't have an effect on the build process.
@@ -352,15 +1398,68 @@ func (c *Core) Build() error {
 
 	// Just update our shared data so we get the creds
 	rootCtx.Shared.InfraCreds = infraCtx.Shared.InfraCreds
+	rootCtx.Shared.Cancel = cancelCh
+
+	name := rootCtx.Appfile.Application.Name
+	c.event(CoreEvent{Type: CoreEventBuildStart, Tuple: rootCtx.Tuple, Name: name})
+	defer func() {
+		e := CoreEvent{Type: CoreEventBuildDone, Tuple: rootCtx.Tuple, Name: name}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		c.event(e)
+	}()
+
+	if err := rootApp.Build(rootCtx); err != nil {
+		// The app may have stopped early because it was canceled
+		if isCanceled(cancelCh) {
+			return ErrCanceled
+		}
+
+		return err
+	}
 
-	return rootApp.Build(rootCtx)
+	if c.signer != nil {
+		return c.signBuild(directory.Lookup{
+			AppID:       rootCtx.Appfile.ID,
+			Infra:       rootCtx.Tuple.Infra,
+			InfraFlavor: rootCtx.Tuple.InfraFlavor,
+		})
+	}
+
+	return nil
 }
 
 // Deploy deploys the application.
 //
-// Deploy supports subactions, which can be specified with action and args.
-// Action can be "" to get the default deploy behavior.
-func (c *Core) Deploy(action string, args []string) error {
+// Deploy supports subactions, which can be specified with the Action and
+// Args options. Action can be "" to get the default deploy behavior. By
+// default the latest build artifact is deployed; see DeployOpts for
+// deploying a specific artifact. A build promoted to the active
+// infrastructure by Promote from one of the same type and flavor is
+// deployed in place of the builds of that type and flavor.
+func (c *Core) Deploy(opts *DeployOpts) (err error) {
+	if opts == nil {
+		opts = new(DeployOpts)
+	}
+	action := opts.Action
+	args := opts.Args
+
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+	if auditedAction(action) {
+		defer func() { c.audit("deploy", action, err) }()
+	}
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
 	// Get the infra implementation for this
 	infra, infraCtx, err := c.infra()
 	if err != nil {
@@ -373,6 +1472,9 @@ func (c *Core) Deploy(action string, args []string) error {
 		if err := c.creds(infra, infraCtx); err != nil {
 			return err
 		}
+		if action == "destroy" {
+			c.showIdentity(infra, infraCtx)
+		}
 	}
 
 	// TODO: Verify that upstream dependencies are deployed
@@ -402,13 +1504,126 @@ func (c *Core) Deploy(action string, args []string) error {
 	rootCtx.Action = action
 	rootCtx.ActionArgs = args
 
+	lookup := directory.Lookup{
+		AppID:       rootCtx.Appfile.ID,
+		Infra:       rootCtx.Tuple.Infra,
+		InfraFlavor: rootCtx.Tuple.InfraFlavor,
+	}
+	verify := c.signer != nil && action == ""
+
+	// Builds promoted to this infrastructure from one of the same type
+	// and flavor are scoped to it by name. They take precedence, and
+	// only apps that deploy a given artifact can deploy them.
+	if action == "" {
+		scoped := lookup
+		scoped.InfraName = infraCtx.Infra.Name
+		build, err := c.dir.GetBuild(&directory.Build{Lookup: scoped, ID: opts.ArtifactID})
+		if err != nil {
+			return fmt.Errorf("Error loading build artifact: %s", err)
+		}
+		if build != nil {
+			deployer, ok := rootApp.(app.ArtifactDeployer)
+			if !ok {
+				return fmt.Errorf(
+					"The app type '%s' can't deploy the build artifact '%s'\n"+
+						"promoted to '%s'. Build the application for it instead.",
+					rootCtx.Tuple.App, build.ID, scoped.InfraName)
+			}
+			if verify {
+				if err := c.verifyBuild(build); err != nil {
+					return err
+				}
+			}
+
+			return deployer.DeployArtifact(rootCtx, build)
+		}
+	}
+
+	// If a specific artifact was requested or builds are signed, look
+	// up the artifact so we can verify it.
+	if opts.ArtifactID == "" && !verify {
+		return rootApp.Deploy(rootCtx)
+	}
+
+	build, err := c.dir.GetBuild(&directory.Build{Lookup: lookup, ID: opts.ArtifactID})
+	if err != nil {
+		return fmt.Errorf("Error loading build artifact: %s", err)
+	}
+
+	var deployer app.ArtifactDeployer
+	if opts.ArtifactID != "" {
+		if build == nil {
+			return fmt.Errorf(
+				"Build artifact '%s' not found for this application and\n"+
+					"infrastructure flavor.", opts.ArtifactID)
+		}
+
+		// Apps that don't deploy a given artifact look up the latest one
+		// on their own, so they can only deploy that.
+		var ok bool
+		deployer, ok = rootApp.(app.ArtifactDeployer)
+		if !ok {
+			latest, err := c.dir.GetBuild(&directory.Build{Lookup: lookup})
+			if err != nil {
+				return fmt.Errorf("Error loading build artifact: %s", err)
+			}
+			if latest == nil || latest.ID != build.ID {
+				return fmt.Errorf(
+					"The app type '%s' can only deploy the latest build artifact,\n"+
+						"and '%s' is an earlier one. Build again to deploy it.",
+					rootCtx.Tuple.App, opts.ArtifactID)
+			}
+		}
+	}
+
+	// A missing build is left to the app to report, as without signing.
+	if verify && build != nil {
+		if err := c.verifyBuild(build); err != nil {
+			return err
+		}
+	}
+
+	if deployer != nil {
+		return deployer.DeployArtifact(rootCtx, build)
+	}
+
 	return rootApp.Deploy(rootCtx)
 }
 
 // Dev starts a dev environment for the current application. For destroying
 // and other tasks against the dev environment, use the generic `Execute`
 // method.
+//
+// If the app implements app.HealthChecker, Dev only returns once the
+// environment passes the health check; see CoreConfig.DevHealthTimeout.
 func (c *Core) Dev() error {
+	return c.dev(false)
+}
+
+// DevDetached is like Dev but starts the dev environment in the
+// background, records it in the directory and returns. The app must
+// implement app.DevDetacher, which app plugins can't do yet. Use DevStop
+// to stop it later.
+func (c *Core) DevDetached() error {
+	return c.dev(true)
+}
+
+func (c *Core) dev(detach bool) (err error) {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	_, end := c.startSpan(SpanDev, nil)
+	defer func() { end(err) }()
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
 	// We need to get the root data separately since we need that for
 	// all the function calls into the dependencies.
 	root, err := c.appfileCompiled.Graph.Root()
@@ -427,9 +1642,27 @@ func (c *Core) Dev() error {
 	}
 	defer maybeClose(rootApp)
 
+	// Verify that the environment can be detached before building the
+	// dependencies for it.
+	var detacher app.DevDetacher
+	if detach {
+		detacher, err = c.devDetacher(rootApp, rootCtx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.forceRebuild {
+		log.Printf("[INFO] core: force rebuild, ignoring cached dev dependencies")
+		c.ui.Message(
+			"[yellow]Ignoring cached dev dependencies and rebuilding them[reset]")
+	}
+
 	// Go through all the dependencies and build their immutable
 	// dev environment pieces for the final configuration.
-	err = c.walk(func(appImpl app.App, ctx *app.Context, root bool) error {
+	// Dependencies that are only needed to build aren't part of the
+	// dev environment.
+	err = c.walk(appfile.DependencyScopeDev, func(appImpl app.App, ctx *app.Context, root bool) error {
 		// If it is the root, we just return and do nothing else since
 		// the root is a special case where we're building the actual
 		// dev environment.
@@ -439,14 +1672,44 @@ func (c *Core) Dev() error {
 
 		// Get the path to where we'd cache the dependency if we have
 		// cached it...
-		cachePath := filepath.Join(ctx.CacheDir, "dev-dep.json")
+		cachePath, err := devDepCachePath(c.hasher, appImpl, ctx)
+		if err != nil {
+			return fmt.Errorf(
+				"Error determining the cache key for dev dependency '%s': %s",
+				ctx.Appfile.Application.Name,
+				err)
+		}
 
-		// Check if we've cached this. If so, then use the cache.
-		if _, err := app.ReadDevDep(cachePath); err == nil {
+		// Check if we've cached this. If so, then use the cache unless
+		// we're forced to rebuild.
+		span := c.vertexSpans.get(ctx.Appfile.ID)
+		span.SetAttribute(SpanAttrCacheHit, "false")
+		if c.forceRebuild {
+			log.Printf(
+				"[DEBUG] core: bypassing dev dependency cache for '%s'",
+				ctx.Appfile.Application.Name)
+		} else if c.cachedDevDep(ctx.Appfile.Application.Name, cachePath) {
+			span.SetAttribute(SpanAttrCacheHit, "true")
 			ctx.Ui.Header(fmt.Sprintf(
 				"Using cached dev dependency for '%s'",
 				ctx.Appfile.Application.Name))
 			return nil
+		} else if c.remoteCache != nil {
+			ok, err := c.retryCacheRead(cachePath, func() (bool, error) {
+				return c.getRemoteDevDep(ctx, cachePath)
+			})
+			if err != nil {
+				c.warn(WarningSourceCore, ctx.Appfile.Application.Name, fmt.Sprintf(
+					"Error reading dev dependency from the remote cache, "+
+						"building it instead: %s", err))
+			}
+			if ok {
+				span.SetAttribute(SpanAttrCacheHit, "true")
+				ctx.Ui.Header(fmt.Sprintf(
+					"Using remotely cached dev dependency for '%s'",
+					ctx.Appfile.Application.Name))
+				return nil
+			}
 		}
 
 		// Copy the root context so it isn't modified by the call below
@@ -458,10 +1721,9 @@ func (c *Core) Dev() error {
 			ctx.Appfile.Application.Name)
 		dep, err := appImpl.DevDep(&rootCtxCopy, ctx)
 		if err != nil {
-			return fmt.Errorf(
+			return wrapErrorf(err,
 				"Error building dependency for dev '%s': %s",
-				ctx.Appfile.Application.Name,
-				err)
+				ctx.Appfile.Application.Name)
 		}
 
 		// If we have a dependency with files, then verify the files
@@ -481,6 +1743,13 @@ func (c *Core) Dev() error {
 					ctx.Appfile.Application.Name,
 					err)
 			}
+
+			if c.remoteCache != nil {
+				if err := c.putRemoteDevDep(ctx, cachePath); err != nil {
+					c.warn(WarningSourceCore, ctx.Appfile.Application.Name, fmt.Sprintf(
+						"Error storing dev dependency in the remote cache: %s", err))
+				}
+			}
 		}
 
 		return nil
@@ -494,7 +1763,34 @@ func (c *Core) Dev() error {
 	log.Printf(
 		"[DEBUG] core: calling Dev for root app '%s'",
 		rootCtx.Appfile.Application.Name)
-	return rootApp.Dev(rootCtx)
+	if detacher != nil {
+		if err := c.devStartDetached(detacher, rootCtx); err != nil {
+			return err
+		}
+	} else if err := rootApp.Dev(rootCtx); err != nil {
+		return err
+	}
+
+	// Don't return until the environment can actually be used
+	return c.devHealthCheck(rootApp, rootCtx)
+}
+
+// devDepCachePath returns the path where the dev dependency of the app
+// is cached. If the app implements app.CacheKeyer, the key is part of
+// the path so that a different key isn't served the old dependency.
+func devDepCachePath(h Hasher, impl app.App, ctx *app.Context) (string, error) {
+	keyer, ok := impl.(app.CacheKeyer)
+	if !ok {
+		return filepath.Join(ctx.CacheDir, "dev-dep.json"), nil
+	}
+
+	key, err := keyer.CacheKey(ctx)
+	if err != nil || key == "" {
+		return filepath.Join(ctx.CacheDir, "dev-dep.json"), err
+	}
+
+	return filepath.Join(ctx.CacheDir, fmt.Sprintf(
+		"dev-dep-%s.json", truncateHash(hashBytes(h, []byte(key)), 16))), nil
 }
 
 // Infra manages the infrastructure for this Appfile.
@@ -502,17 +1798,45 @@ func (c *Core) Dev() error {
 // Infra supports subactions, which can be specified with action and args.
 // Infra recognizes two special actions: "" (blank string) and "destroy".
 // The former expects to create or update the complete infrastructure,
-// and the latter will destroy the infrastructure.
-func (c *Core) Infra(action string, args []string) error {
+// and the latter will destroy the infrastructure. If the infrastructure
+// declares its actions with infrastructure.ActionLister, any other
+// action and its arguments are validated against them; see InfraActions.
+func (c *Core) Infra(action string, args []string) (err error) {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+	if auditedAction(action) {
+		defer func() { c.audit("infra", action, err) }()
+	}
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
 	// Get the infra implementation for this
 	infra, infraCtx, err := c.infra()
 	if err != nil {
 		return err
 	}
+	if action != "destroy" {
+		// Destroying is handled by Otto, everything else is passed
+		// to the infrastructure so make sure it understands it.
+		if err := checkInfraAction(infra, action, args); err != nil {
+			maybeClose(infra)
+			return err
+		}
+	}
 	if action == "" || action == "destroy" {
 		if err := c.creds(infra, infraCtx); err != nil {
 			return err
 		}
+		if action == "destroy" {
+			c.showIdentity(infra, infraCtx)
+		}
 	}
 	defer maybeClose(infra)
 
@@ -599,6 +1923,9 @@ func (c *Core) Infra(action string, args []string) error {
 
 // Status outputs to the UI the status of all the stages of this application.
 func (c *Core) Status() error {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
 	// Start loading the status info in a goroutine
 	statusCh := make(chan *statusInfo, 1)
 	go c.statusInfo(statusCh)
@@ -662,8 +1989,18 @@ func (c *Core) Status() error {
 	return nil
 }
 
-// Execute executes the given task for this Appfile.
+// Execute executes the given task for this Appfile. An error is returned
+// if the options aren't valid; see ExecuteOpts.Validate.
 func (c *Core) Execute(opts *ExecuteOpts) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
 	switch opts.Task {
 	case ExecuteTaskDev:
 		return c.executeApp(opts)
@@ -672,31 +2009,84 @@ func (c *Core) Execute(opts *ExecuteOpts) error {
 	}
 }
 
-// creds reads the credentials if we have them, or queries the user
+// checkDirectory verifies that the directory backend is available. If
+// required is false, an unavailable backend only results in a warning
+// to the UI and nil is returned.
+func (c *Core) checkDirectory(required bool) error {
+	err := directory.Ping(c.dir)
+	if err == nil {
+		return nil
+	}
+
+	log.Printf("[WARN] directory backend unavailable: %s", err)
+	if required {
+		return fmt.Errorf(
+			"The directory backend is unavailable: %s\n\n"+
+				"This operation requires access to the directory backend to\n"+
+				"store and retrieve state. Please verify the backend is reachable\n"+
+				"and try again.", err)
+	}
+
+	c.ui.Message(fmt.Sprintf(
+		"[yellow]WARNING: The directory backend is unavailable: %s\n"+
+			"Otto will continue since this operation doesn't require it, but\n"+
+			"any state that would be read from the directory will be missing.",
+		err))
+	c.warn(WarningSourceCore, "", fmt.Sprintf(
+		"The directory backend is unavailable: %s", err))
+	return nil
+}
+
+// loadCreds reads the credentials if we have them, or queries the user
 // for infrastructure credentials using the infrastructure if we
 // don't have them.
-func (c *Core) creds(
+func (c *Core) loadCreds(
 	infra infrastructure.Infrastructure,
 	infraCtx *infrastructure.Context) error {
+	// If we were given creds, use them without touching disk.
+	if c.infraCreds != nil {
+		creds := make(map[string]string, len(c.infraCreds))
+		for k, v := range c.infraCreds {
+			creds[k] = v
+		}
+
+		log.Printf("[INFO] core: using configured infrastructure creds")
+		infraCtx.InfraCreds = creds
+		return infra.VerifyCreds(infraCtx)
+	}
+
 	// Output to the user some information about what is about to
 	// happen here...
-	infraCtx.Ui.Header(fmt.Sprintf(
-		"Detecting infrastructure credentials for: %s (%s)",
-		infraCtx.Infra.Name, infraCtx.Infra.Type))
+	profile := c.credsProfile()
+	if profile == credsDefaultProfile {
+		infraCtx.Ui.Header(fmt.Sprintf(
+			"Detecting infrastructure credentials for: %s (%s)",
+			infraCtx.Infra.Name, infraCtx.Infra.Type))
+	} else {
+		infraCtx.Ui.Header(fmt.Sprintf(
+			"Detecting infrastructure credentials for: %s (%s), profile: %s",
+			infraCtx.Infra.Name, infraCtx.Infra.Type, profile))
+	}
 
 	// The path to where we put the encrypted creds
-	path := filepath.Join(c.dataDir, "cache", "creds", infraCtx.Infra.Name)
+	path := c.credsPath(infraCtx.Infra.Name)
 
-	// Determine whether we believe the creds exist already or not
+	// Determine whether we believe the creds exist already or not. If
+	// they don't, make sure we can store them before asking for them.
 	var exists bool
 	if _, err := os.Stat(path); err == nil {
 		exists = true
 	} else {
 		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			return err
+			return fmt.Errorf(strings.TrimSpace(credsErrDir), filepath.Dir(path), err)
 		}
 	}
 
+	// data is the full decrypted contents of the creds file, which may
+	// contain profiles other than the one we're using. password is the
+	// password that data was decrypted with.
+	var data *credsData
+	var password string
 	var creds map[string]string
 	if exists {
 		infraCtx.Ui.Message(
@@ -704,31 +2094,80 @@ func (c *Core) creds(
 				"Otto will now ask you for the password to decrypt these\n" +
 				"credentials.\n\n")
 
-		// If they exist, ask for the password
-		value, err := infraCtx.Ui.Input(&ui.InputOpts{
-			Id:          "creds_password",
-			Query:       "Encrypted Credentials Password",
-			Description: strings.TrimSpace(credsQueryPassExists),
-			Hide:        true,
-			EnvVars:     []string{"OTTO_CREDS_PASSWORD"},
-		})
-		if err != nil {
+		attempts := c.credsMaxAttempts
+		if attempts < 1 {
+			attempts = 1
+		}
+		if err := c.requireInputs(infraCtx.Ui, credsPasswordInput(true)); err != nil {
 			return err
 		}
 
-		// If the password is not blank, then just read the credentials
-		if value != "" {
+		for i := 1; i <= attempts; i++ {
+			// If they exist, ask for the password
+			value, err := infraCtx.Ui.Input(credsPasswordInput(true))
+			if err != nil {
+				return err
+			}
+
+			// A blank password means the user wants to enter new creds
+			if value == "" {
+				break
+			}
+
+			// Read the credentials
 			plaintext, err := cryptRead(path, value)
 			if err == nil {
-				err = json.Unmarshal(plaintext, &creds)
+				data, err = parseCredsData(plaintext)
 			}
-			if err != nil {
+			if err == nil {
+				password = value
+				creds = data.Profiles[profile]
+				break
+			}
+
+			if i < attempts {
+				infraCtx.Ui.Message(fmt.Sprintf(
+					"[yellow]Error reading encrypted credentials: %s\n"+
+						"Please try again (attempt %d of %d).\n", err, i+1, attempts))
+				continue
+			}
+
+			if !c.credsReenter {
 				return fmt.Errorf(
 					"error reading encrypted credentials: %s\n\n"+
 						"If this error persists, you can force Otto to ask for credentials\n"+
 						"again by inputting the empty password as the password.",
 					err)
 			}
+
+			infraCtx.Ui.Message(fmt.Sprintf(
+				"[yellow]Error reading encrypted credentials: %s\n"+
+					"Otto will ask for new credentials instead.\n", err))
+		}
+	}
+
+	// If the cached creds are missing keys that the infrastructure
+	// requires, then we only ask for the missing keys and merge them in.
+	var save bool
+	if creds != nil {
+		missing := missingCreds(infra, creds)
+		if len(missing) > 0 {
+			infraCtx.Ui.Message(fmt.Sprintf(
+				"The cached infrastructure credentials are missing some values\n"+
+					"that are now required: %s. Otto will ask you for only\n"+
+					"these values and save them with the existing credentials.\n\n",
+				strings.Join(credKeys(missing), ", ")))
+
+			if err := c.requireInputs(infraCtx.Ui, credInputs(missing)...); err != nil {
+				return err
+			}
+			merged, err := promptCreds(infraCtx, creds, missing)
+			if err != nil {
+				return err
+			}
+
+			creds = merged
+			save = true
 		}
 	}
 
@@ -749,26 +2188,79 @@ func (c *Core) creds(
 			return err
 		}
 
-		// Now that we have the credentials, we need to ask for the
-		// password to encrypt and store them.
-		var password string
-		for password == "" {
-			password, err = infraCtx.Ui.Input(&ui.InputOpts{
-				Id:          "creds_password",
-				Query:       "Password for Encrypting Credentials",
-				Description: strings.TrimSpace(credsQueryPassNew),
-				Hide:        true,
-				EnvVars:     []string{"OTTO_CREDS_PASSWORD"},
-			})
+		// Ask for any required values the infrastructure didn't set,
+		// verifying first that everything we'll ask for has an answer
+		// if we can't prompt.
+		missing := missingCreds(infra, creds)
+		inputs := credInputs(missing)
+		if data == nil && password == "" {
+			inputs = append(inputs, credsPasswordInput(false))
+		}
+		if err := c.requireInputs(infraCtx.Ui, inputs...); err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			creds, err = promptCreds(infraCtx, creds, missing)
 			if err != nil {
 				return err
 			}
 		}
 
-		// With the password, encrypt and write the data
-		plaintext, err := json.Marshal(creds)
+		// If we didn't decrypt existing data, then we're starting fresh
+		// and we need to ask for the password to encrypt and store them.
+		if data == nil {
+			data = &credsData{
+				Version:  credsVersion,
+				Profiles: make(map[string]map[string]string),
+			}
+
+			var weak int
+			for password == "" {
+				password, err = infraCtx.Ui.Input(credsPasswordInput(false))
+				if err != nil {
+					return err
+				}
+
+				// If we're configured with a minimum strength, show the
+				// strength of the password and ask again if it is too weak.
+				if password != "" && c.credsMinEntropy > 0 {
+					bits := passwordEntropy(password)
+					if bits < c.credsMinEntropy {
+						// The password may not come from the user, so don't
+						// ask forever.
+						weak++
+						if weak >= credsWeakAttempts {
+							return fmt.Errorf(
+								"the password for encrypting credentials is too weak: "+
+									"about %.0f bits, at least %.0f bits are required",
+								bits, c.credsMinEntropy)
+						}
+
+						infraCtx.Ui.Message(fmt.Sprintf(
+							"[yellow]Password strength: %s (about %.0f bits). A password of\n"+
+								"at least %.0f bits is required, please try again with a longer\n"+
+								"password or more kinds of characters.\n",
+							passwordStrength(bits), bits, c.credsMinEntropy))
+						password = ""
+						continue
+					}
+
+					infraCtx.Ui.Message(fmt.Sprintf(
+						"Password strength: %s (about %.0f bits)",
+						passwordStrength(bits), bits))
+				}
+			}
+		}
+
+		save = true
+	}
+
+	// With the password, encrypt and write the data
+	if save {
+		data.Profiles[profile] = creds
+		plaintext, err := json.Marshal(data)
 		if err != nil {
-			// creds is a map[string]string, so this shouldn't ever fail
+			// data is only maps of strings, so this shouldn't ever fail
 			panic(err)
 		}
 
@@ -780,6 +2272,7 @@ func (c *Core) creds(
 
 	// Set the credentials
 	infraCtx.InfraCreds = creds
+	log.Printf("[DEBUG] core: infrastructure creds: %v", redactCreds(infra, creds))
 
 	// Let the infrastructure do whatever it likes to verify that the credentials
 	// are good, so we can fail fast in case there's a problem.
@@ -791,6 +2284,10 @@ func (c *Core) creds(
 }
 
 func (c *Core) executeApp(opts *ExecuteOpts) error {
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
 	// Get the infra implementation for this
 	appCtx, err := c.appContext(c.appfile)
 	if err != nil {
@@ -801,6 +2298,9 @@ func (c *Core) executeApp(opts *ExecuteOpts) error {
 		return err
 	}
 	defer maybeClose(app)
+	if err := checkAppAction(app, opts.Action, opts.Args); err != nil {
+		return err
+	}
 
 	// Set the action and action args
 	appCtx.Action = opts.Action
@@ -811,8 +2311,26 @@ func (c *Core) executeApp(opts *ExecuteOpts) error {
 	case ExecuteTaskDev:
 		return app.Dev(appCtx)
 	default:
-		panic(fmt.Sprintf("uknown task: %s", opts.Task))
+		return fmt.Errorf("unknown task: %s", opts.Task)
+	}
+}
+
+// appOutputDir returns the directory the compiled output of the given
+// Appfile is written to. This is either the main app so it goes directly
+// into the app folder or it is a dependency and goes into a dep folder.
+func (c *Core) appOutputDir(f *appfile.File) string {
+	if f.ID == c.appfile.ID {
+		return filepath.Join(c.compileDir, c.dirLayout.AppDir())
 	}
+
+	return filepath.Join(c.compileDir, c.dirLayout.DepDir(f.ID))
+}
+
+// infraOutputDir returns the directory the compiled output of the active
+// infrastructure is written to.
+func (c *Core) infraOutputDir() string {
+	return filepath.Join(
+		c.compileDir, c.dirLayout.InfraDir(c.appfile.Project.Infrastructure))
 }
 
 func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
@@ -822,32 +2340,22 @@ func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
 	// We need the configuration for the active infrastructure
 	// so that we can build the tuple below
 	config := f.ActiveInfrastructure()
-	if config == nil {
-		return nil, fmt.Errorf(
-			"infrastructure not found in appfile: %s",
-			f.Project.Infrastructure)
+	tuple, err := c.appTuple(f)
+	if err != nil {
+		return nil, err
 	}
-
-	// The tuple we're looking for is the application type, the
-	// infrastructure type, and the infrastructure flavor. Build that
-	// tuple.
-	tuple := app.Tuple{
-		App:         f.Application.Type,
-		Infra:       config.Type,
-		InfraFlavor: config.Flavor,
+	if err := c.validateFlavor(config); err != nil {
+		return nil, err
 	}
 
-	// The output directory for data. This is either the main app so
-	// it goes directly into "app" or it is a dependency and goes into
-	// a dep folder.
-	outputDir := filepath.Join(c.compileDir, "app")
-	if !root {
-		outputDir = filepath.Join(
-			c.compileDir, fmt.Sprintf("dep-%s", f.ID))
-	}
+	// The output directory for data.
+	outputDir := c.appOutputDir(f)
 
 	// The cache directory for this app
-	cacheDir := filepath.Join(c.dataDir, "cache", f.ID)
+	cacheDir, err := c.appCacheDir(f)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return nil, fmt.Errorf(
 			"error making cache directory '%s': %s",
@@ -870,6 +2378,11 @@ func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
 			outputDir, fmt.Sprintf("foundation-%s", f.Name))
 	}
 
+	vars, err := c.vars()
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the dev IP address
 	ipDB := &localaddr.CachedDB{
 		DB:        &localaddr.DB{Path: filepath.Join(c.dataDir, "ip.db")},
@@ -913,24 +2426,56 @@ func (c *Core) appContext(f *appfile.File) (*app.Context, error) {
 	}
 
 	return &app.Context{
-		CompileResult: compileResult,
-		Dir:           outputDir,
-		CacheDir:      cacheDir,
-		LocalDir:      c.localDir,
-		GlobalDir:     globalDir,
-		Tuple:         tuple,
-		Application:   f.Application,
-		DevIPAddress:  ip.String(),
+		CompileResult:  compileResult,
+		Dir:            outputDir,
+		CacheDir:       cacheDir,
+		LocalDir:       c.localDir,
+		GlobalDir:      globalDir,
+		Tuple:          tuple,
+		Application:    f.Application,
+		DevIPAddress:   ip.String(),
+		ResourceLimits: c.resourceLimits,
 		Shared: context.Shared{
 			Appfile:        f,
 			FoundationDirs: foundationDirs,
 			InstallDir:     filepath.Join(c.dataDir, "binaries"),
 			Directory:      c.dir,
 			Ui:             c.ui,
+			Env:            c.env,
+			Vars:           vars,
+			ProjectDir:     c.projectDirPath(),
+			CredRead:       c.credsTracker.Read,
 		},
 	}, nil
 }
 
+// projectDirPath returns the root directory of the project.
+func (c *Core) projectDirPath() string {
+	if c.projectDir != "" {
+		return c.projectDir
+	}
+	if c.appfile.Path != "" {
+		return filepath.Dir(c.appfile.Path)
+	}
+
+	return ""
+}
+
+// appTuple returns the tuple for the given Appfile: the application
+// type, the infrastructure type, and the infrastructure flavor.
+func (c *Core) appTuple(f *appfile.File) (app.Tuple, error) {
+	config := f.ActiveInfrastructure()
+	if config == nil {
+		return app.Tuple{}, errInfraNotFound(f, f.Project.Infrastructure)
+	}
+
+	return app.Tuple{
+		App:         f.Application.Type,
+		Infra:       config.Type,
+		InfraFlavor: config.Flavor,
+	}, nil
+}
+
 func (c *Core) app(ctx *app.Context) (app.App, error) {
 	log.Printf("[INFO] Loading app implementation for Tuple: %s", ctx.Tuple)
 
@@ -940,6 +2485,12 @@ func (c *Core) app(ctx *app.Context) (app.App, error) {
 		return nil, fmt.Errorf(
 			"app implementation for tuple not found: %s", ctx.Tuple)
 	}
+	if c.appMiddleware != nil {
+		if f = c.appMiddleware(ctx.Tuple, f); f == nil {
+			return nil, fmt.Errorf(
+				"app factory middleware returned nil for tuple: %s", ctx.Tuple)
+		}
+	}
 
 	// Start the impl.
 	result, err := f()
@@ -947,6 +2498,10 @@ func (c *Core) app(ctx *app.Context) (app.App, error) {
 		return nil, fmt.Errorf(
 			"app failed to start properly: %s", err)
 	}
+	if result == nil {
+		return nil, fmt.Errorf(
+			"app factory for tuple %s returned nil", ctx.Tuple)
+	}
 
 	return result, nil
 }
@@ -955,11 +2510,16 @@ func (c *Core) infra() (infrastructure.Infrastructure, *infrastructure.Context,
 	// Get the infrastructure configuration
 	config := c.appfile.ActiveInfrastructure()
 	if config == nil {
-		return nil, nil, fmt.Errorf(
-			"infrastructure not found in appfile: %s",
-			c.appfile.Project.Infrastructure)
+		return nil, nil, errInfraNotFound(c.appfile, c.appfile.Project.Infrastructure)
 	}
 
+	return c.infraFor(config)
+}
+
+// infraFor is like infra but for the given infrastructure of the
+// Appfile, which doesn't have to be the active one.
+func (c *Core) infraFor(config *appfile.Infrastructure) (
+	infrastructure.Infrastructure, *infrastructure.Context, error) {
 	// Get the infrastructure factory
 	f, ok := c.infras[config.Type]
 	if !ok {
@@ -968,15 +2528,28 @@ func (c *Core) infra() (infrastructure.Infrastructure, *infrastructure.Context,
 			config.Type)
 	}
 
+	vars, err := c.vars()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Start the infrastructure implementation
 	infra, err := f()
 	if err != nil {
 		return nil, nil, err
 	}
+	if infra == nil {
+		return nil, nil, fmt.Errorf(
+			"infrastructure factory for type %s returned nil", config.Type)
+	}
+	flavors := c.flavors.put(config.Type, infra.Flavors())
+	if err := checkFlavor(config, flavors); err != nil {
+		maybeClose(infra)
+		return nil, nil, err
+	}
 
 	// The output directory for data
-	outputDir := filepath.Join(
-		c.compileDir, fmt.Sprintf("infra-%s", c.appfile.Project.Infrastructure))
+	outputDir := filepath.Join(c.compileDir, c.dirLayout.InfraDir(config.Name))
 
 	// Build the context
 	return infra, &infrastructure.Context{
@@ -987,6 +2560,10 @@ func (c *Core) infra() (infrastructure.Infrastructure, *infrastructure.Context,
 			InstallDir: filepath.Join(c.dataDir, "binaries"),
 			Directory:  c.dir,
 			Ui:         c.ui,
+			Env:        c.env,
+			Vars:       vars,
+			ProjectDir: c.projectDirPath(),
+			CredRead:   c.credsTracker.Read,
 		},
 	}, nil
 }
@@ -995,9 +2572,7 @@ func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, er
 	// Get the infrastructure configuration
 	config := c.appfile.ActiveInfrastructure()
 	if config == nil {
-		return nil, nil, fmt.Errorf(
-			"infrastructure not found in appfile: %s",
-			c.appfile.Project.Infrastructure)
+		return nil, nil, errInfraNotFound(c.appfile, c.appfile.Project.Infrastructure)
 	}
 
 	// If there are no foundations, return nothing.
@@ -1005,6 +2580,11 @@ func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, er
 		return nil, nil, nil
 	}
 
+	vars, err := c.vars()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Create the arrays for our list
 	fs := make([]foundation.Foundation, 0, len(config.Foundations))
 	ctxs := make([]*foundation.Context, 0, cap(fs))
@@ -1031,6 +2611,10 @@ func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, er
 		if err != nil {
 			return nil, nil, err
 		}
+		if impl == nil {
+			return nil, nil, fmt.Errorf(
+				"foundation factory for tuple %s returned nil", tuple)
+		}
 
 		// The output directory for data
 		outputDir := filepath.Join(
@@ -1046,6 +2630,10 @@ func (c *Core) foundations() ([]foundation.Foundation, []*foundation.Context, er
 				InstallDir: filepath.Join(c.dataDir, "binaries"),
 				Directory:  c.dir,
 				Ui:         c.ui,
+				Env:        c.env,
+				Vars:       vars,
+				ProjectDir: c.projectDirPath(),
+				CredRead:   c.credsTracker.Read,
 			},
 		}
 
@@ -1068,3 +2656,13 @@ const credsQueryPassNew = `
 This password will be used to encrypt and save the credentials so they
 don't need to be repeated multiple times.
 `
+
+const credsErrDir = `
+Error creating the directory for the encrypted infrastructure
+credentials, %s: %s
+
+Otto saves the credentials it asks for encrypted in its data directory.
+Make sure the data directory is writable, or, if credentials are managed
+elsewhere, give them to Otto with CoreConfig.InfraCreds so that nothing
+is written to disk.
+`
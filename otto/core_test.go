@@ -1,11 +1,20 @@
 package otto
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
 )
 
 func TestCoreApp(t *testing.T) {
@@ -26,6 +35,144 @@ func TestCoreApp(t *testing.T) {
 	}
 }
 
+func TestCoreRootTuple(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	tuple, err := core.RootTuple()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if tuple != TestAppTuple {
+		t.Fatalf("bad: %#v", tuple)
+	}
+
+	infra, err := core.ActiveInfra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra.Type != "test" || infra.Flavor != "test" {
+		t.Fatalf("bad: %#v", infra)
+	}
+}
+
+func TestNewCoreFromFile(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	f := TestAppfile(t, testPath("deps", "Appfile")).File
+	core, err := NewCoreFromFile(f, coreConfig)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if v := len(core.appfileCompiled.Graph.Vertices()); v != 2 {
+		t.Fatalf("bad: %d", v)
+	}
+
+	// The compiled Appfile should be stored for later use
+	compiled, err := appfile.LoadCompiled(
+		filepath.Join(coreConfig.LocalDir, CompiledAppfileDir))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if compiled.File.ID != f.ID {
+		t.Fatalf("bad: %#v", compiled.File)
+	}
+}
+
+func TestNewCoreFromFile_invalid(t *testing.T) {
+	_, err := NewCoreFromFile(new(appfile.File), TestCoreConfig(t))
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "Error compiling Appfile") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestCoreApp_env(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Env = map[string]string{"FOO": "bar"}
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	_, ctx, err := core.App()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ctx.Env["FOO"] != "bar" {
+		t.Fatalf("bad: %#v", ctx.Env)
+	}
+}
+
+func TestCoreApp_projectDir(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Defaults to the directory of the Appfile
+	_, ctx, err := core.App()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ctx.ProjectDir != filepath.Dir(coreConfig.Appfile.File.Path) {
+		t.Fatalf("bad: %s", ctx.ProjectDir)
+	}
+
+	coreConfig.ProjectDir = "/foo"
+	core = testCore(t, coreConfig)
+	_, ctx, err = core.App()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ctx.ProjectDir != "/foo" {
+		t.Fatalf("bad: %s", ctx.ProjectDir)
+	}
+}
+
+func TestCoreApp_resourceLimits(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.ResourceLimits = &app.ResourceLimits{CPUs: 2, MemoryMB: 512}
+	core := testCore(t, coreConfig)
+
+	_, ctx, err := core.App()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(ctx.ResourceLimits, coreConfig.ResourceLimits) {
+		t.Fatalf("bad: %#v", ctx.ResourceLimits)
+	}
+}
+
+func TestCoreRoot_override(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	coreConfig.Root = "child"
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Only the child should've been compiled, as the root
+	if appMock.CompileContext.Application.Name != "child" {
+		t.Fatalf("bad: %#v", appMock.CompileContext.Application)
+	}
+	if !strings.HasSuffix(appMock.CompileContext.Dir, "app") {
+		t.Fatalf("bad: %s", appMock.CompileContext.Dir)
+	}
+
+	// Unknown roots are an error
+	coreConfig.Root = "nope"
+	if _, err := NewCore(coreConfig); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestCoreCompile_close(t *testing.T) {
 	// Make a core that returns a fixed app
 	coreConfig := TestCoreConfig(t)
@@ -46,6 +193,41 @@ func TestCoreCompile_close(t *testing.T) {
 	}
 }
 
+func TestCoreCompile_nilFactory(t *testing.T) {
+	// A factory that returns a nil app
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return nil, nil
+	}
+	core := testCore(t, coreConfig)
+
+	err := core.Compile()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "returned nil") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// A factory that returns a nil infrastructure
+	coreConfig = TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return nil, nil
+	}
+	core = testCore(t, coreConfig)
+
+	err = core.Compile()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "returned nil") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
 func TestCoreCompile_customizationFilter(t *testing.T) {
 	// Make a core that returns a fixed app
 	coreConfig := TestCoreConfig(t)
@@ -77,6 +259,361 @@ func TestCoreCompile_customizationFilter(t *testing.T) {
 	}
 }
 
+func TestCoreCompile_directoryUnavailable(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+
+	// Point the backend at a file so it can never be opened
+	tf, err := ioutil.TempFile("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+	coreConfig.Directory = &directory.BoltBackend{Dir: tf.Name()}
+
+	// Compile should work since it doesn't require the directory
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.CompileCalled {
+		t.Fatal("compile should be called")
+	}
+
+	// If we require the directory, it should fail
+	coreConfig.RequireDirectory = true
+	core = testCore(t, coreConfig)
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreCompile_dirLayout(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.DirLayout = testDirLayout{}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(ctx.Dir, "foo.txt")
+		return nil, ioutil.WriteFile(path, []byte("foo"), 0644)
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := filepath.Join(coreConfig.CompileDir, "application", "foo.txt")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	m, err := core.Manifest()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if m.App == nil || m.App.Dir != "application" || len(m.App.Files) != 1 {
+		t.Fatalf("bad: %#v", m.App)
+	}
+}
+
+func TestCoreOutputs(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	infra, err := core.ActiveInfra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Record some outputs in the directory
+	err = coreConfig.Directory.PutInfra(&directory.Infra{
+		Lookup:  directory.Lookup{Infra: infra.Name},
+		State:   directory.InfraStateReady,
+		Outputs: map[string]string{"ip": "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = coreConfig.Directory.PutDeploy(&directory.Deploy{
+		Lookup: directory.Lookup{
+			AppID:       core.appfile.ID,
+			Infra:       infra.Type,
+			InfraFlavor: infra.Flavor,
+		},
+		State:  directory.DeployStateSuccess,
+		Deploy: map[string]string{"url": "http://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual, err := core.Outputs()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]map[string]string{
+		infra.Name:                    map[string]string{"ip": "127.0.0.1"},
+		core.appfile.Application.Name: map[string]string{"url": "http://example.com"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestCoreManifest(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// No compilation yet, so no manifest
+	m, err := core.Manifest()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if m != nil {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(ctx.Dir, "foo.txt")
+		return nil, ioutil.WriteFile(path, []byte("foo"), 0644)
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	m, err = core.Manifest()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if m == nil || m.App == nil || len(m.App.Files) != 1 {
+		t.Fatalf("bad: %#v", m)
+	}
+
+	f := m.App.Files[0]
+	if f.Path != "foo.txt" || f.Size != 3 {
+		t.Fatalf("bad: %#v", f)
+	}
+	expected := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	if f.SHA256 != expected {
+		t.Fatalf("bad: %s", f.SHA256)
+	}
+}
+
+func TestCoreCompile_walkHook(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	hook := new(testWalkHook)
+	coreConfig.WalkHook = hook
+	core := testCore(t, coreConfig)
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(hook.Started) != 1 || len(hook.Done) != 1 {
+		t.Fatalf("bad: %#v", hook)
+	}
+	if hook.Started[0] != TestAppTuple {
+		t.Fatalf("bad: %#v", hook.Started)
+	}
+}
+
+func TestCoreCompile_devDepFragmentMissing(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// The dependency reports a fragment that doesn't exist
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if ctx.Application.Name != "child" {
+			return nil, nil
+		}
+
+		return &app.CompileResult{
+			DevDepFragmentPath: filepath.Join(ctx.Dir, "nope"),
+		}, nil
+	}
+
+	err := core.Compile()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "'child'") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestCoreCompile_devDepFragmentOrder(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps-multi", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Every dependency reports a fragment
+	var fragments []string
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if ctx.Application.Name == "root" {
+			fragments = ctx.DevDepFragments
+			return nil, nil
+		}
+
+		path := filepath.Join(ctx.Dir, "fragment")
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			return nil, err
+		}
+
+		return &app.CompileResult{DevDepFragmentPath: path}, nil
+	}
+
+	var expected []string
+	for i := 0; i < 10; i++ {
+		if err := core.Compile(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if len(fragments) != 2 {
+			t.Fatalf("bad: %#v", fragments)
+		}
+
+		if expected == nil {
+			expected = fragments
+		}
+		if !reflect.DeepEqual(fragments, expected) {
+			t.Fatalf("bad: %#v", fragments)
+		}
+	}
+
+	// They're ordered by the ID of the dependency, which is "0e9d..." for b
+	if !strings.Contains(expected[0], "0e9d8c7b") {
+		t.Fatalf("bad: %#v", expected)
+	}
+}
+
+func TestCoreCompileTo(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "compile")
+	if err := core.CompileTo(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestFilename)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The configured directory and stored hash are untouched
+	if _, err := os.Stat(coreConfig.CompileDir); !os.IsNotExist(err) {
+		t.Fatalf("compile dir should not exist: %s", err)
+	}
+	if hash, err := core.getCompileHash(); err != nil || hash != "" {
+		t.Fatalf("bad: %q %s", hash, err)
+	}
+
+	// The same checks as Compile apply
+	if err := core.CompileTo(filepath.Dir(coreConfig.LocalDir)); err == nil {
+		t.Fatal("should error")
+	}
+	if err := core.CompileTo(""); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreCompile_concurrentInfra(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.ConcurrentInfraCompile = true
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+
+	// The infra compile only finishes once the app compile started,
+	// which would deadlock if they ran one after the other.
+	started := make(chan struct{})
+	infra := &testWaitInfra{Mock: new(infrastructure.Mock), Wait: started}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		close(started)
+		return nil, nil
+	}
+
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Errors from the infra compile are still returned
+	started = make(chan struct{})
+	infra.Wait = started
+	infra.CompileErr = errors.New("foo")
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreCompile_busy(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Block the compilation until we've verified other operations fail
+	startCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		close(startCh)
+		<-doneCh
+		return nil, nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- core.Compile() }()
+	<-startCh
+
+	if err := core.Build(); err != ErrBusy {
+		t.Fatalf("bad: %#v", err)
+	}
+	if err := core.Compile(); err != ErrBusy {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	close(doneCh)
+	if err := <-errCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Once the compilation is done, we can run another
+	appMock.CompileFunc = nil
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestCoreDev_compileMetadata(t *testing.T) {
 	// Make a core that returns a fixed app
 	coreConfig := TestCoreConfig(t)
@@ -106,6 +643,200 @@ func TestCoreDev_compileMetadata(t *testing.T) {
 	}
 }
 
+func TestCoreDev_forceRebuild(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DevDepCalled {
+		t.Fatal("DevDep should be called")
+	}
+
+	// Cache the dependency
+	cachePath := filepath.Join(
+		appMock.DevDepContextSrc.CacheDir, "dev-dep.json")
+	if err := app.WriteDevDep(cachePath, &app.DevDep{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The cache is used normally
+	appMock.DevDepCalled = false
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.DevDepCalled {
+		t.Fatal("DevDep should not be called")
+	}
+
+	// The cache is ignored when forced
+	coreConfig.ForceRebuild = true
+	core = testCore(t, coreConfig)
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DevDepCalled {
+		t.Fatal("DevDep should be called")
+	}
+}
+
+func TestCoreDev_cacheKey(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := &testCacheKeyer{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Cache the dependency with the current key
+	appMock.Key = "foo"
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cachePath, err := devDepCachePath(core.hasher, appMock, appMock.DevDepContextSrc)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := app.WriteDevDep(cachePath, &app.DevDep{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The same key uses the cache
+	appMock.DevDepCalled = false
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.DevDepCalled {
+		t.Fatal("DevDep should not be called")
+	}
+
+	// A new key rebuilds it
+	appMock.Key = "bar"
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DevDepCalled {
+		t.Fatal("DevDep should be called")
+	}
+}
+
+func TestCoreDev_scope(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps-scope", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Compile still includes the build-only dependency
+	var compiled []string
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		compiled = append(compiled, ctx.Application.Name)
+		return nil, nil
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(compiled) != 2 {
+		t.Fatalf("bad: %#v", compiled)
+	}
+
+	// Dev doesn't build it
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.DevDepCalled {
+		t.Fatal("DevDep should not be called")
+	}
+	if !appMock.DevCalled {
+		t.Fatal("Dev should be called")
+	}
+}
+
+func TestCoreCompile_scope(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps-scope", "Appfile"))
+	coreConfig.CompileScope = appfile.DependencyScopeDev
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// The build-only dependency is out of scope
+	var compiled []string
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		compiled = append(compiled, ctx.Application.Name)
+		return nil, nil
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(compiled) != 1 || compiled[0] != "root" {
+		t.Fatalf("bad: %#v", compiled)
+	}
+
+	// It is in the build scope
+	compiled = nil
+	coreConfig.CompileScope = appfile.DependencyScopeBuild
+	core = testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(compiled) != 2 {
+		t.Fatalf("bad: %#v", compiled)
+	}
+
+	coreConfig.CompileScope = "bad"
+	if _, err := NewCore(coreConfig); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+type testWaitInfra struct {
+	*infrastructure.Mock
+
+	Wait chan struct{}
+}
+
+func (i *testWaitInfra) Compile(ctx *infrastructure.Context) (*infrastructure.CompileResult, error) {
+	<-i.Wait
+	return i.Mock.Compile(ctx)
+}
+
+type testCacheKeyer struct {
+	*app.Mock
+
+	Key string
+}
+
+func (c *testCacheKeyer) CacheKey(*app.Context) (string, error) {
+	return c.Key, nil
+}
+
+type testWalkHook struct {
+	sync.Mutex
+
+	Started []app.Tuple
+	Done    []app.Tuple
+}
+
+func (h *testWalkHook) OnVertexStart(tuple app.Tuple, name string) {
+	h.Lock()
+	defer h.Unlock()
+	h.Started = append(h.Started, tuple)
+}
+
+func (h *testWalkHook) OnVertexDone(tuple app.Tuple, name string, err error) {
+	h.Lock()
+	defer h.Unlock()
+	h.Done = append(h.Done, tuple)
+}
+
 func testCore(t *testing.T, config *CoreConfig) *Core {
 	core, err := NewCore(config)
 	if err != nil {
@@ -114,3 +845,219 @@ func testCore(t *testing.T, config *CoreConfig) *Core {
 
 	return core
 }
+
+func TestCoreExecute_invalid(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	cases := []struct {
+		Opts *ExecuteOpts
+		Err  bool
+	}{
+		{nil, true},
+		{&ExecuteOpts{Task: ExecuteTaskInvalid, Action: "destroy"}, true},
+		{&ExecuteOpts{Task: ExecuteTask(42), Action: "destroy"}, true},
+		{&ExecuteOpts{Task: ExecuteTaskDev}, true},
+		{&ExecuteOpts{Task: ExecuteTaskDev, Action: "destroy"}, false},
+	}
+
+	for i, tc := range cases {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("%d: panic: %s", i, r)
+				}
+			}()
+
+			err := core.Execute(tc.Opts)
+			if (err != nil) != tc.Err {
+				t.Fatalf("%d: err: %s", i, err)
+			}
+		}()
+	}
+}
+
+func TestCoreReload(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	compiled := TestAppfile(t, testPath("deps", "Appfile"))
+	if err := core.Reload(compiled); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if core.appfileCompiled != compiled || core.appfile != compiled.File {
+		t.Fatal("appfile should be replaced")
+	}
+
+	_, ctx, err := core.App()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ctx.Application.Name != "root" {
+		t.Fatalf("bad: %#v", ctx.Application)
+	}
+}
+
+func TestCoreReload_incompatible(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	// No implementation exists for this app type
+	compiled := TestAppfile(t, testPath("basic", "Appfile"))
+	compiled.File.Application.Type = "unknown"
+	if err := core.Reload(compiled); err == nil {
+		t.Fatal("should error")
+	}
+	if core.appfileCompiled != coreConfig.Appfile {
+		t.Fatal("appfile should not be replaced")
+	}
+}
+
+func TestCoreReload_busy(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	if err := core.lock(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer core.unlock()
+
+	err := core.Reload(TestAppfile(t, testPath("deps", "Appfile")))
+	if err != ErrBusy {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+// testDirLayout is a DirLayout that only changes the app directory.
+type testDirLayout struct {
+	DefaultDirLayout
+}
+
+func (testDirLayout) AppDir() string {
+	return "application"
+}
+
+func TestCoreWithDirs(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	other := core.WithDirs("", filepath.Join(td, "local"), filepath.Join(td, "compile"))
+	if other.dataDir != core.dataDir {
+		t.Fatalf("bad: %s", other.dataDir)
+	}
+
+	// The copy can run while the original holds its lock
+	if err := core.lock(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer core.unlock()
+
+	if err := other.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(td, "compile", "metadata.json")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(coreConfig.CompileDir, "metadata.json")); err == nil {
+		t.Fatal("original compile dir should not be used")
+	}
+
+	// The credentials used are tracked separately
+	other.credsTracker.Read("aws_access_key")
+	if keys := core.UsedCredKeys(); len(keys) != 0 {
+		t.Fatalf("bad: %#v", keys)
+	}
+	if keys := other.UsedCredKeys(); len(keys) != 1 {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	// The incremental file hash cache follows the data directory
+	coreConfig.CacheKey = ContentCacheKey{Incremental: true}
+	core = testCore(t, coreConfig)
+	other = core.WithDirs(filepath.Join(td, "data"), "", "")
+	k, ok := other.cacheKey.(ContentCacheKey)
+	if !ok || k.hashCache != filepath.Join(td, "data", "cache-fingerprints.json") {
+		t.Fatalf("bad: %#v", other.cacheKey)
+	}
+	if k := core.cacheKey.(ContentCacheKey); k.hashCache != filepath.Join(
+		coreConfig.DataDir, "cache-fingerprints.json") {
+		t.Fatalf("bad: %#v", core.cacheKey)
+	}
+}
+
+func TestCoreQuery(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	if v := core.AppName(); v != "root" {
+		t.Fatalf("bad: %s", v)
+	}
+	if v := core.AppType(); v != "test" {
+		t.Fatalf("bad: %s", v)
+	}
+	if v := core.InfraName(); v != coreConfig.Appfile.File.Project.Infrastructure {
+		t.Fatalf("bad: %s", v)
+	}
+
+	expected := []string{"child"}
+	if v := core.DependencyNames(); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
+func TestCoreListApps(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, app.Tuple{App: "go", Infra: "aws", InfraFlavor: "*"}, coreConfig)
+	TestInfra(t, "aws", coreConfig)
+	core := testCore(t, coreConfig)
+
+	apps := core.ListApps()
+	expected := []app.Tuple{
+		{App: "go", Infra: "aws", InfraFlavor: "*"},
+		TestAppTuple,
+	}
+	if !reflect.DeepEqual(apps, expected) {
+		t.Fatalf("bad: %#v", apps)
+	}
+
+	infras := core.ListInfrastructures()
+	if !reflect.DeepEqual(infras, []string{"aws", "test"}) {
+		t.Fatalf("bad: %#v", infras)
+	}
+}
+
+func TestCoreSelfTest(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	if err := core.SelfTest(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.CompileCalled {
+		t.Fatal("compile should be called")
+	}
+	if appMock.CompileContext.Dir == coreConfig.CompileDir {
+		t.Fatal("should not use the compile dir")
+	}
+
+	// A failing implementation fails the self test
+	appMock.CompileErr = errors.New("broken")
+	if err := core.SelfTest(); err == nil {
+		t.Fatal("should error")
+	}
+}
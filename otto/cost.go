@@ -0,0 +1,56 @@
+package otto
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+// EstimateCost asks the active infrastructure to estimate the monthly
+// cost of the configured flavor from its compiled configuration, so that
+// it can be reviewed before anything is built. Compile must be called
+// first. The infrastructure must implement infrastructure.CostEstimator.
+// Credentials aren't loaded, so the estimate can't depend on the account.
+func (c *Core) EstimateCost() (*infrastructure.CostEstimate, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	if err := c.materialize(); err != nil {
+		return nil, err
+	}
+
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return nil, err
+	}
+	defer maybeClose(infra)
+
+	estimator, ok := infra.(infrastructure.CostEstimator)
+	if !ok {
+		return nil, fmt.Errorf(
+			"Cost estimates are not supported by the infrastructure type '%s'.",
+			infraCtx.Infra.Type)
+	}
+	if _, err := os.Stat(infraCtx.Dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf(
+				"The infrastructure '%s' hasn't been compiled. Run `otto compile`\n"+
+					"before estimating its cost.", infraCtx.Infra.Name)
+		}
+
+		return nil, err
+	}
+
+	estimate, err := estimator.EstimateCost(infraCtx)
+	if err != nil {
+		return nil, fmt.Errorf("Error estimating infrastructure cost: %s", err)
+	}
+	if estimate == nil {
+		estimate = new(infrastructure.CostEstimate)
+	}
+
+	return estimate, nil
+}
@@ -0,0 +1,91 @@
+package otto
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+func TestCoreEstimateCost(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	estimator := &testCostEstimator{
+		Mock: new(infrastructure.Mock),
+		Estimate: &infrastructure.CostEstimate{
+			Currency: "USD",
+			LineItems: []*infrastructure.CostLineItem{
+				{Name: "instance", Quantity: 2, MonthlyCost: 20},
+				{Name: "NAT gateway", Quantity: 1, MonthlyCost: 32.5},
+			},
+		},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return estimator, nil
+	}
+	core := testCore(t, coreConfig)
+
+	// The infrastructure must be compiled first
+	if _, err := core.EstimateCost(); err == nil {
+		t.Fatal("should error")
+	}
+	if estimator.Context != nil {
+		t.Fatal("estimate shouldn't be called")
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	estimate, err := core.EstimateCost()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if estimate != estimator.Estimate {
+		t.Fatalf("bad: %#v", estimate)
+	}
+	if estimate.Total() != 52.5 {
+		t.Fatalf("bad: %f", estimate.Total())
+	}
+	if estimator.Context.Infra.Flavor != "test" {
+		t.Fatalf("bad: %#v", estimator.Context.Infra)
+	}
+}
+
+func TestCoreEstimateCost_unsupported(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	_, err := core.EstimateCost()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+// testCostEstimator is an infrastructure that estimates its cost. Its
+// compile creates the compiled directory like real infrastructures do.
+type testCostEstimator struct {
+	*infrastructure.Mock
+
+	Estimate *infrastructure.CostEstimate
+	Context  *infrastructure.Context
+}
+
+func (i *testCostEstimator) EstimateCost(
+	ctx *infrastructure.Context) (*infrastructure.CostEstimate, error) {
+	i.Context = ctx
+	return i.Estimate, nil
+}
+
+func (i *testCostEstimator) Compile(
+	ctx *infrastructure.Context) (*infrastructure.CompileResult, error) {
+	if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return i.Mock.Compile(ctx)
+}
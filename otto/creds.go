@@ -0,0 +1,427 @@
+package otto
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/ui"
+)
+
+// credsDefaultProfile is the name of the profile used when no profile
+// is configured.
+const credsDefaultProfile = "default"
+
+// credsVersion is the current version of the plaintext credentials
+// format that is encrypted on disk.
+const credsVersion = 1
+
+// credsData is the structure of the plaintext credentials that are
+// encrypted and stored on disk. A single file can hold multiple named
+// profiles of credentials for the same infrastructure.
+type credsData struct {
+	Version  int                          `json:"version"`
+	Profiles map[string]map[string]string `json:"profiles"`
+}
+
+// parseCredsData parses the plaintext of a decrypted credentials file.
+//
+// Credentials saved prior to profile support are a flat map of the
+// credentials themselves. These are loaded as the default profile.
+func parseCredsData(plaintext []byte) (*credsData, error) {
+	var result credsData
+	if err := json.Unmarshal(plaintext, &result); err == nil && result.Version > 0 {
+		if result.Profiles == nil {
+			result.Profiles = make(map[string]map[string]string)
+		}
+
+		return &result, nil
+	}
+
+	var legacy map[string]string
+	if err := json.Unmarshal(plaintext, &legacy); err != nil {
+		return nil, err
+	}
+
+	return &credsData{
+		Version: credsVersion,
+		Profiles: map[string]map[string]string{
+			credsDefaultProfile: legacy,
+		},
+	}, nil
+}
+
+// credsProfile returns the name of the creds profile to use.
+func (c *Core) credsProfile() string {
+	if c.credsProfileName != "" {
+		return c.credsProfileName
+	}
+
+	return credsDefaultProfile
+}
+
+// creds loads the credentials for the infrastructure with loadCreds and
+// notifies the OnCredsUsed callback, which can veto their use.
+func (c *Core) creds(
+	infra infrastructure.Infrastructure,
+	infraCtx *infrastructure.Context) (err error) {
+	span := c.startChildSpan(SpanCreds, map[string]string{
+		SpanAttrInfra: infraCtx.Infra.Name,
+	})
+	defer func() { span.End(err) }()
+
+	// Only the credentials read during this operation are reported
+	c.credsTracker.Reset()
+	if err := c.loadCreds(infra, infraCtx); err != nil {
+		return err
+	}
+
+	if c.onCredsUsed != nil {
+		if err := c.onCredsUsed(infraCtx.Infra.Name); err != nil {
+			infraCtx.InfraCreds = nil
+			return fmt.Errorf(
+				"Use of the credentials for %s was denied: %s",
+				infraCtx.Infra.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// TestConnectivity loads the infrastructure credentials, asking for them
+// if necessary, and verifies with the infrastructure that they work
+// without performing any other operation. This can be used to catch
+// expired or incorrect credentials before starting a long build.
+//
+// Verification is done by the VerifyCreds method of the infrastructure,
+// so it is only as thorough as that implementation.
+func (c *Core) TestConnectivity() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+	defer maybeClose(infra)
+
+	if err := c.creds(infra, infraCtx); err != nil {
+		return fmt.Errorf(
+			"Error verifying credentials for %s: %s", infraCtx.Infra.Name, err)
+	}
+
+	c.ui.Header(fmt.Sprintf(
+		"[green]Infrastructure credentials for %s are valid!",
+		infraCtx.Infra.Name))
+	return nil
+}
+
+// IdentityUnknown is the identity WhoAmI returns if the infrastructure
+// doesn't implement infrastructure.Identifier.
+const IdentityUnknown = "unknown"
+
+// WhoAmI loads the infrastructure credentials, asking for them if
+// necessary, and returns the identity they belong to, such as the
+// account ID, as reported by the infrastructure. This is used to
+// confirm which account the operations will affect. If the
+// infrastructure doesn't implement infrastructure.Identifier, the result
+// is IdentityUnknown.
+func (c *Core) WhoAmI() (string, error) {
+	if err := c.lock(); err != nil {
+		return "", err
+	}
+	defer c.unlock()
+
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return "", err
+	}
+	defer maybeClose(infra)
+
+	if err := c.creds(infra, infraCtx); err != nil {
+		return "", err
+	}
+
+	return credsIdentity(infra, infraCtx)
+}
+
+// credsIdentity returns the identity of the loaded credentials.
+func credsIdentity(
+	infra infrastructure.Infrastructure,
+	infraCtx *infrastructure.Context) (string, error) {
+	identifier, ok := infra.(infrastructure.Identifier)
+	if !ok {
+		return IdentityUnknown, nil
+	}
+
+	identity, err := identifier.Identity(infraCtx)
+	if err != nil {
+		return "", fmt.Errorf(
+			"Error determining the identity of the credentials for %s: %s",
+			infraCtx.Infra.Name, err)
+	}
+	if identity == "" {
+		identity = IdentityUnknown
+	}
+
+	return identity, nil
+}
+
+// showIdentity shows the identity of the loaded credentials before a
+// destructive operation, so that the user can stop it if it is the
+// wrong account. Failing to determine the identity only results in a
+// warning, since it doesn't affect the operation.
+func (c *Core) showIdentity(
+	infra infrastructure.Infrastructure,
+	infraCtx *infrastructure.Context) {
+	if _, ok := infra.(infrastructure.Identifier); !ok {
+		return
+	}
+
+	identity, err := credsIdentity(infra, infraCtx)
+	if err != nil {
+		c.warn(WarningSourceCore, infraCtx.Infra.Name, err.Error())
+		return
+	}
+
+	c.ui.Message(fmt.Sprintf(
+		"[yellow]Using the credentials of: %s", identity))
+}
+
+// credsPath returns the path of the encrypted credentials file of the
+// infrastructure with the given name.
+func (c *Core) credsPath(infra string) string {
+	return filepath.Join(c.dataDir, "cache", "creds", infra)
+}
+
+// CredsErrorReason is the reason ValidateCreds found the stored
+// credentials to be invalid.
+type CredsErrorReason string
+
+const (
+	CredsErrNotFound CredsErrorReason = "not found"
+	CredsErrPassword CredsErrorReason = "wrong password"
+	CredsErrCorrupt  CredsErrorReason = "corrupt"
+	CredsErrMissing  CredsErrorReason = "missing keys"
+)
+
+// CredsError is the error returned by ValidateCreds.
+type CredsError struct {
+	Reason CredsErrorReason
+
+	// Missing are the required keys that don't have a value, if Reason
+	// is CredsErrMissing.
+	Missing []string
+
+	// Err is the underlying error, if there is one.
+	Err error
+}
+
+func (e *CredsError) Error() string {
+	switch {
+	case e.Reason == CredsErrMissing:
+		return fmt.Sprintf(
+			"the stored credentials are missing required values: %s",
+			strings.Join(e.Missing, ", "))
+	case e.Err != nil:
+		return fmt.Sprintf("the stored credentials are %s: %s", e.Reason, e.Err)
+	default:
+		return fmt.Sprintf("the stored credentials are %s", e.Reason)
+	}
+}
+
+// errwrap.Wrapper impl.
+func (e *CredsError) WrappedErrors() []error { return []error{e.Err} }
+
+// ValidateCreds checks that the stored infrastructure credentials can be
+// decrypted with the given password and contain every value that the
+// infrastructure requires for the configured profile. If they can't be
+// used, a *CredsError says why.
+//
+// Unlike the operations that use the credentials, this never asks for
+// or stores credentials and doesn't ask the infrastructure to verify
+// them, so it has no side effects.
+func (c *Core) ValidateCreds(password string) error {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+	defer maybeClose(infra)
+
+	path := c.credsPath(infraCtx.Infra.Name)
+	if _, err := os.Stat(path); err != nil {
+		return &CredsError{Reason: CredsErrNotFound, Err: err}
+	}
+
+	plaintext, err := cryptRead(path, password)
+	if err == errCryptAuth {
+		return &CredsError{Reason: CredsErrPassword, Err: err}
+	}
+	if err != nil {
+		return &CredsError{Reason: CredsErrCorrupt, Err: err}
+	}
+	data, err := parseCredsData(plaintext)
+	if err != nil {
+		return &CredsError{Reason: CredsErrCorrupt, Err: err}
+	}
+
+	profile := c.credsProfile()
+	creds, ok := data.Profiles[profile]
+	if !ok {
+		return &CredsError{
+			Reason: CredsErrNotFound,
+			Err:    fmt.Errorf("profile '%s' doesn't exist", profile),
+		}
+	}
+	if missing := missingCreds(infra, creds); len(missing) > 0 {
+		return &CredsError{Reason: CredsErrMissing, Missing: credKeys(missing)}
+	}
+
+	return nil
+}
+
+// credsSchema returns the description of the credential keys of the
+// infrastructure. Infrastructures that only implement CredsRequirer are
+// described as requiring secret values for their keys. If neither is
+// implemented, nil is returned and the credentials are free-form.
+func credsSchema(infra infrastructure.Infrastructure) []infrastructure.CredField {
+	if d, ok := infra.(infrastructure.CredsDescriber); ok {
+		return d.CredsSchema()
+	}
+
+	r, ok := infra.(infrastructure.CredsRequirer)
+	if !ok {
+		return nil
+	}
+
+	keys := r.RequiredCreds()
+	result := make([]infrastructure.CredField, len(keys))
+	for i, k := range keys {
+		result[i] = infrastructure.CredField{Key: k, Secret: true, Required: true}
+	}
+
+	return result
+}
+
+// missingCreds returns the fields the infrastructure requires that aren't
+// set in the given creds, in the order the infrastructure declares them.
+func missingCreds(
+	infra infrastructure.Infrastructure, creds map[string]string) []infrastructure.CredField {
+	var result []infrastructure.CredField
+	for _, f := range credsSchema(infra) {
+		if f.Required && creds[f.Key] == "" {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// credKeys returns the keys of the fields, for display.
+func credKeys(fields []infrastructure.CredField) []string {
+	result := make([]string, len(fields))
+	for i, f := range fields {
+		result[i] = f.Key
+	}
+
+	return result
+}
+
+// credInputs returns the inputs that promptCreds asks for the given
+// fields.
+func credInputs(fields []infrastructure.CredField) []*ui.InputOpts {
+	result := make([]*ui.InputOpts, len(fields))
+	for i, f := range fields {
+		result[i] = &ui.InputOpts{
+			Id:          fmt.Sprintf("creds_%s", f.Key),
+			Query:       f.Key,
+			Description: f.Description,
+			Hide:        f.Secret,
+		}
+	}
+
+	return result
+}
+
+// credsPasswordInput returns the input for the password that encrypts
+// the saved credentials. If exists is true, it asks for the password of
+// credentials that are already saved, and otherwise for a new one.
+func credsPasswordInput(exists bool) *ui.InputOpts {
+	if exists {
+		return &ui.InputOpts{
+			Id:          "creds_password",
+			Query:       "Encrypted Credentials Password",
+			Description: strings.TrimSpace(credsQueryPassExists),
+			Hide:        true,
+			EnvVars:     []string{"OTTO_CREDS_PASSWORD"},
+		}
+	}
+
+	return &ui.InputOpts{
+		Id:          "creds_password",
+		Query:       "Password for Encrypting Credentials",
+		Description: strings.TrimSpace(credsQueryPassNew),
+		Hide:        true,
+		EnvVars:     []string{"OTTO_CREDS_PASSWORD"},
+	}
+}
+
+// promptCreds asks the user for the values of the given fields and
+// returns a copy of creds with them added.
+func promptCreds(
+	ctx *infrastructure.Context,
+	creds map[string]string,
+	fields []infrastructure.CredField) (map[string]string, error) {
+	result := make(map[string]string, len(creds)+len(fields))
+	for k, v := range creds {
+		result[k] = v
+	}
+
+	for i, opts := range credInputs(fields) {
+		f := fields[i]
+		value, err := ctx.Ui.Input(opts)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			return nil, fmt.Errorf("a value for credential %q is required", f.Key)
+		}
+
+		result[f.Key] = value
+	}
+
+	return result, nil
+}
+
+// redactCreds returns a copy of creds with the values of the secret
+// fields replaced so they can be shown or logged. If the infrastructure
+// doesn't describe a key, its value is considered secret.
+func redactCreds(
+	infra infrastructure.Infrastructure, creds map[string]string) map[string]string {
+	public := make(map[string]bool)
+	for _, f := range credsSchema(infra) {
+		public[f.Key] = !f.Secret
+	}
+
+	result := make(map[string]string, len(creds))
+	for k, v := range creds {
+		if !public[k] {
+			v = credsRedacted
+		}
+
+		result[k] = v
+	}
+
+	return result
+}
+
+// credsRedacted replaces secret credential values in output.
+const credsRedacted = "<redacted>"
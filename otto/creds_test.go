@@ -0,0 +1,554 @@
+package otto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestParseCredsData(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected map[string]map[string]string
+	}{
+		// Legacy format is loaded as the default profile
+		{
+			`{"foo":"bar"}`,
+			map[string]map[string]string{
+				"default": map[string]string{"foo": "bar"},
+			},
+		},
+
+		// Legacy format with a key that looks like our own
+		{
+			`{"profiles":"bar"}`,
+			map[string]map[string]string{
+				"default": map[string]string{"profiles": "bar"},
+			},
+		},
+
+		{
+			`{"version":1,"profiles":{"prod":{"foo":"bar"}}}`,
+			map[string]map[string]string{
+				"prod": map[string]string{"foo": "bar"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := parseCredsData([]byte(tc.Input))
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+
+		if !reflect.DeepEqual(actual.Profiles, tc.Expected) {
+			t.Fatalf("%s: bad: %#v", tc.Input, actual.Profiles)
+		}
+	}
+}
+
+func TestCoreCreds_partial(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+
+	infra := &testCredsRequirer{
+		Mock: new(infrastructure.Mock),
+		Keys: []string{"access_key", "region"},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	core := testCore(t, coreConfig)
+
+	_, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Store creds that only have some of the required keys
+	path := filepath.Join(
+		coreConfig.DataDir, "cache", "creds", infraCtx.Infra.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := cryptWrite(path, "foo", []byte(`{"access_key": "bar"}`)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.creds(infra, infraCtx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{"access_key": "bar", "region": "foo"}
+	if !reflect.DeepEqual(infraCtx.InfraCreds, expected) {
+		t.Fatalf("bad: %#v", infraCtx.InfraCreds)
+	}
+
+	// The merged creds should have been saved
+	plaintext, err := cryptRead(path, "foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data, err := parseCredsData(plaintext)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(data.Profiles[credsDefaultProfile], expected) {
+		t.Fatalf("bad: %#v", data)
+	}
+}
+
+// testCredsRequirer is an infrastructure that requires specific creds.
+type testCredsRequirer struct {
+	*infrastructure.Mock
+
+	Keys []string
+}
+
+func (i *testCredsRequirer) RequiredCreds() []string {
+	return i.Keys
+}
+
+func TestCoreTestConnectivity(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	infra := TestInfra(t, "test", coreConfig)
+	core := testCore(t, coreConfig)
+
+	if err := core.TestConnectivity(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.VerifyCredsCalled {
+		t.Fatal("VerifyCreds should be called")
+	}
+
+	infra.VerifyCredsErr = fmt.Errorf("expired")
+	if err := core.TestConnectivity(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreCreds_attempts(t *testing.T) {
+	cases := []struct {
+		Inputs   []string
+		Attempts int
+		Reenter  bool
+		Err      bool
+		Password string
+	}{
+		// Wrong password once, but we get multiple attempts
+		{[]string{"wrong", "right"}, 2, false, false, "right"},
+
+		// Wrong password on every attempt
+		{[]string{"wrong", "wrong"}, 2, false, true, ""},
+
+		// Wrong password, then enter new creds with a new password
+		{[]string{"wrong", "new"}, 0, true, false, "new"},
+	}
+
+	for i, tc := range cases {
+		coreConfig := TestCoreConfig(t)
+		coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+		coreConfig.Ui = &testInputUi{Mock: new(ui.Mock), Inputs: tc.Inputs}
+		coreConfig.CredsMaxAttempts = tc.Attempts
+		coreConfig.CredsReenterOnFailure = tc.Reenter
+		core := testCore(t, coreConfig)
+
+		infra, infraCtx, err := core.infra()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		path := filepath.Join(
+			coreConfig.DataDir, "cache", "creds", infraCtx.Infra.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := cryptWrite(path, "right", []byte(`{"key": "value"}`)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		err = core.creds(infra, infraCtx)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if _, err := cryptRead(path, tc.Password); err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+	}
+}
+
+// testInputUi is a ui.Ui that returns the given inputs in order.
+type testInputUi struct {
+	*ui.Mock
+
+	Inputs []string
+}
+
+func (u *testInputUi) Input(opts *ui.InputOpts) (string, error) {
+	if len(u.Inputs) == 0 {
+		return "", fmt.Errorf("unexpected input: %s", opts.Id)
+	}
+
+	result := u.Inputs[0]
+	u.Inputs = u.Inputs[1:]
+	return result, nil
+}
+
+func TestCoreCreds_inputAnswers(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "wrong"}
+	coreConfig.InputAnswers = map[string]string{"creds_password": "right"}
+	core := testCore(t, coreConfig)
+
+	infra, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := filepath.Join(
+		coreConfig.DataDir, "cache", "creds", infraCtx.Infra.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := cryptWrite(path, "right", []byte(`{"key": "value"}`)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.creds(infra, infraCtx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if coreConfig.Ui.(*ui.Mock).InputCalled {
+		t.Fatal("the Ui should not be asked")
+	}
+}
+
+func TestCoreCreds_schema(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+
+	infra := &testCredsDescriber{
+		Mock: new(infrastructure.Mock),
+		Fields: []infrastructure.CredField{
+			{Key: "region", Description: "Region", Required: true},
+			{Key: "token", Secret: true, Required: true},
+			{Key: "optional"},
+		},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	core := testCore(t, coreConfig)
+
+	_, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The infrastructure returns no creds, so the required ones are asked
+	if err := core.creds(infra, infraCtx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{"region": "foo", "token": "foo"}
+	if !reflect.DeepEqual(infraCtx.InfraCreds, expected) {
+		t.Fatalf("bad: %#v", infraCtx.InfraCreds)
+	}
+
+	redacted := redactCreds(infra, map[string]string{
+		"region": "foo", "token": "foo", "unknown": "foo"})
+	expected = map[string]string{
+		"region": "foo", "token": credsRedacted, "unknown": credsRedacted}
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Fatalf("bad: %#v", redacted)
+	}
+}
+
+// testCredsDescriber is an infrastructure that describes its creds.
+type testCredsDescriber struct {
+	*infrastructure.Mock
+
+	Fields []infrastructure.CredField
+}
+
+func (i *testCredsDescriber) CredsSchema() []infrastructure.CredField {
+	return i.Fields
+}
+
+func TestCoreCreds_configured(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.InfraCreds = map[string]string{"key": "value"}
+	uiMock := new(ui.Mock)
+	coreConfig.Ui = uiMock
+	infra := TestInfra(t, "test", coreConfig)
+	core := testCore(t, coreConfig)
+
+	_, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.creds(infra, infraCtx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(infraCtx.InfraCreds, coreConfig.InfraCreds) {
+		t.Fatalf("bad: %#v", infraCtx.InfraCreds)
+	}
+	if uiMock.InputCalled {
+		t.Fatal("should not ask for input")
+	}
+	if !infra.VerifyCredsCalled {
+		t.Fatal("should verify creds")
+	}
+	if _, err := os.Stat(filepath.Join(coreConfig.DataDir, "cache", "creds")); err == nil {
+		t.Fatal("should not store creds")
+	}
+}
+
+func TestCoreCreds_onCredsUsed(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.InfraCreds = map[string]string{"key": "value"}
+
+	var used []string
+	var veto error
+	coreConfig.OnCredsUsed = func(infra string) error {
+		used = append(used, infra)
+		return veto
+	}
+	core := testCore(t, coreConfig)
+
+	if err := core.TestConnectivity(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(used) != 1 || used[0] != coreConfig.Appfile.File.Project.Infrastructure {
+		t.Fatalf("bad: %#v", used)
+	}
+
+	veto = fmt.Errorf("denied")
+	if err := core.TestConnectivity(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreValidateCreds(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	infra := &testCredsRequirer{
+		Mock: new(infrastructure.Mock),
+		Keys: []string{"access_key", "region"},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	core := testCore(t, coreConfig)
+
+	reason := func(err error) CredsErrorReason {
+		if cerr, ok := err.(*CredsError); ok {
+			return cerr.Reason
+		}
+
+		t.Fatalf("bad: %#v", err)
+		return ""
+	}
+
+	if r := reason(core.ValidateCreds("foo")); r != CredsErrNotFound {
+		t.Fatalf("bad: %s", r)
+	}
+
+	_, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := core.credsPath(infraCtx.Infra.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Corrupt data
+	if err := ioutil.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if r := reason(core.ValidateCreds("foo")); r != CredsErrCorrupt {
+		t.Fatalf("bad: %s", r)
+	}
+
+	// Missing keys, then the wrong password
+	if err := cryptWrite(path, "foo", []byte(`{"access_key": "bar"}`)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = core.ValidateCreds("foo")
+	if r := reason(err); r != CredsErrMissing {
+		t.Fatalf("bad: %s", r)
+	}
+	if m := err.(*CredsError).Missing; !reflect.DeepEqual(m, []string{"region"}) {
+		t.Fatalf("bad: %#v", m)
+	}
+	if r := reason(core.ValidateCreds("bar")); r != CredsErrPassword {
+		t.Fatalf("bad: %s", r)
+	}
+
+	data := []byte(`{"access_key": "bar", "region": "baz"}`)
+	if err := cryptWrite(path, "foo", data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.ValidateCreds("foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The infrastructure isn't asked to verify them
+	if infra.VerifyCredsCalled {
+		t.Fatal("VerifyCreds should not be called")
+	}
+}
+
+func TestCoreCreds_dirError(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = new(ui.Mock)
+	infra := TestInfra(t, "test", coreConfig)
+
+	// The creds directory can't be created under a file
+	if err := os.MkdirAll(coreConfig.DataDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(
+		filepath.Join(coreConfig.DataDir, "cache"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	core := testCore(t, coreConfig)
+
+	_, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = core.creds(infra, infraCtx)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "InfraCreds") {
+		t.Fatalf("bad: %s", err)
+	}
+	if infra.VerifyCredsCalled {
+		t.Fatal("should not verify creds")
+	}
+
+	// Given credentials don't need the directory
+	core.infraCreds = map[string]string{"key": "value"}
+	if err := core.creds(infra, infraCtx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCoreUsedCredKeys(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	coreConfig.InfraCreds = map[string]string{
+		"access_key": "foo",
+		"secret_key": "bar",
+	}
+	appMock := &testCredsReader{
+		Mock: TestApp(t, TestAppTuple, coreConfig),
+		Keys: []string{"access_key"},
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	if keys := core.UsedCredKeys(); len(keys) != 0 {
+		t.Fatalf("bad: %#v", keys)
+	}
+	if err := core.Build(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keys := core.UsedCredKeys(); !reflect.DeepEqual(keys, []string{"access_key"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	// Every operation starts over
+	appMock.Keys = []string{"secret_key"}
+	if err := core.Build(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keys := core.UsedCredKeys(); !reflect.DeepEqual(keys, []string{"secret_key"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
+
+// testCredsReader is an app that reads the given credentials when it
+// builds.
+type testCredsReader struct {
+	*app.Mock
+
+	Keys []string
+}
+
+func (a *testCredsReader) Build(ctx *app.Context) error {
+	for _, k := range a.Keys {
+		ctx.Cred(k)
+	}
+
+	return a.Mock.Build(ctx)
+}
+
+func TestCoreWhoAmI(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	core := testCore(t, coreConfig)
+
+	// The mock infrastructure doesn't know its identity
+	identity, err := core.WhoAmI()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if identity != IdentityUnknown {
+		t.Fatalf("bad: %s", identity)
+	}
+
+	infra := &testIdentifier{Mock: new(infrastructure.Mock), ID: "123456789012"}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	core = testCore(t, coreConfig)
+	identity, err = core.WhoAmI()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if identity != "123456789012" {
+		t.Fatalf("bad: %s", identity)
+	}
+	if !infra.VerifyCredsCalled {
+		t.Fatal("creds should be loaded")
+	}
+}
+
+// testIdentifier is an infrastructure that reports a fixed identity.
+type testIdentifier struct {
+	*infrastructure.Mock
+
+	ID string
+}
+
+func (i *testIdentifier) Identity(*infrastructure.Context) (string, error) {
+	return i.ID, nil
+}
@@ -0,0 +1,54 @@
+package otto
+
+import (
+	"sort"
+	"sync"
+)
+
+// credsTracker records the keys of the infrastructure credentials read
+// with context.Shared.Cred, for UsedCredKeys. It is safe for concurrent
+// use.
+type credsTracker struct {
+	lock sync.Mutex
+	used map[string]struct{}
+}
+
+// Read records that the credential with the given key was read. This is
+// given to the contexts as context.Shared.CredRead.
+func (t *credsTracker) Read(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.used == nil {
+		t.used = make(map[string]struct{})
+	}
+	t.used[key] = struct{}{}
+}
+
+// Used returns the sorted keys of the credentials that were read.
+func (t *credsTracker) Used() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	result := make([]string, 0, len(t.used))
+	for k := range t.used {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Reset forgets the credentials that were read.
+func (t *credsTracker) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.used = nil
+}
+
+// UsedCredKeys returns the sorted keys of the infrastructure credentials
+// that the infrastructure and apps read during the last operation that
+// loaded them, to find credentials that aren't needed. Only reads with
+// context.Shared.Cred are seen, including those of plugins.
+func (c *Core) UsedCredKeys() []string {
+	return c.credsTracker.Used()
+}
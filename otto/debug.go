@@ -0,0 +1,90 @@
+package otto
+
+import (
+	"sort"
+
+	"github.com/hashicorp/otto/app"
+)
+
+// DebugConfig is the effective configuration that a Core is running
+// with. This is meant to be included in bug reports, so it never
+// contains any credentials or other secrets.
+type DebugConfig struct {
+	// DataDir, LocalDir, and CompileDir are the resolved directories
+	// the Core is using.
+	DataDir    string
+	LocalDir   string
+	CompileDir string
+
+	// RootID and RootName are the Otto ID and application name of
+	// the main application.
+	RootID   string
+	RootName string
+
+	// Infra, InfraType, and InfraFlavor describe the active
+	// infrastructure. These are empty if it can't be found.
+	Infra       string
+	InfraType   string
+	InfraFlavor string
+
+	// Apps are the registered app tuples, and Infrastructures are the
+	// registered infrastructure types. Both are sorted.
+	Apps            []app.Tuple
+	Infrastructures []string
+
+	// CredsProfile is the name of the credentials profile in use.
+	CredsProfile string
+
+	// RequireDirectory is whether operations fail if the directory
+	// backend is unavailable.
+	RequireDirectory bool
+}
+
+// DebugConfig returns the effective configuration of this Core.
+func (c *Core) DebugConfig() *DebugConfig {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	result := &DebugConfig{
+		DataDir:          c.dataDir,
+		LocalDir:         c.localDir,
+		CompileDir:       c.compileDir,
+		RootID:           c.appfile.ID,
+		Apps:             c.appTuples(),
+		Infrastructures:  c.infraNames(),
+		CredsProfile:     c.credsProfile(),
+		RequireDirectory: c.requireDir,
+	}
+	if c.appfile.Application != nil {
+		result.RootName = c.appfile.Application.Name
+	}
+	if infra := c.appfile.ActiveInfrastructure(); infra != nil {
+		result.Infra = infra.Name
+		result.InfraType = infra.Type
+		result.InfraFlavor = infra.Flavor
+	}
+
+	return result
+}
+
+// appTuples returns the sorted list of registered app tuples.
+func (c *Core) appTuples() []app.Tuple {
+	result := make([]app.Tuple, 0, len(c.apps))
+	for t := range c.apps {
+		result = append(result, t)
+	}
+	sort.Sort(app.TupleSlice(result))
+
+	return result
+}
+
+// infraNames returns the sorted list of registered infrastructure types.
+func (c *Core) infraNames() []string {
+	result := make([]string, 0, len(c.infras))
+	for n := range c.infras {
+		result = append(result, n)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,19 @@
+package otto
+
+// DeployOpts are the options used for deploying with Core.Deploy.
+type DeployOpts struct {
+	// Action is a sub-action to take, such as "destroy". The blank
+	// action is the default deploy behavior.
+	Action string
+
+	// Args are additional arguments to the action.
+	Args []string
+
+	// ArtifactID, if set, is the ID of the build artifact to deploy,
+	// which can be an earlier build than the latest one. Deploying fails
+	// if the artifact can't be found. Apps that don't implement
+	// app.ArtifactDeployer, which includes all the apps of plugins, can
+	// only deploy the latest artifact. If this is blank, the latest build
+	// artifact is deployed.
+	ArtifactID string
+}
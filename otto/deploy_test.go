@@ -0,0 +1,156 @@
+package otto
+
+import (
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreDeploy_artifactID(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	appMock := &testArtifactDeployer{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	lookup := directory.Lookup{
+		AppID:       core.appfile.ID,
+		Infra:       TestAppTuple.Infra,
+		InfraFlavor: TestAppTuple.InfraFlavor,
+	}
+	older := &directory.Build{Lookup: lookup, Artifact: map[string]string{"ami": "ami-1"}}
+	latest := &directory.Build{Lookup: lookup, Artifact: map[string]string{"ami": "ami-2"}}
+	for _, b := range []*directory.Build{older, latest} {
+		if err := coreConfig.Directory.PutBuild(b); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// An unknown artifact
+	if err := core.Deploy(&DeployOpts{ArtifactID: "unknown"}); err == nil {
+		t.Fatal("should error")
+	}
+	if appMock.Deployed != nil || appMock.DeployCalled {
+		t.Fatal("nothing should be deployed")
+	}
+
+	// An earlier artifact
+	if err := core.Deploy(&DeployOpts{ArtifactID: older.ID}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.Deployed == nil || appMock.Deployed.Artifact["ami"] != "ami-1" {
+		t.Fatalf("bad: %#v", appMock.Deployed)
+	}
+	if appMock.DeployCalled {
+		t.Fatal("Deploy shouldn't be called")
+	}
+}
+
+func TestCoreDeploy_artifactIDLatestOnly(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	lookup := directory.Lookup{
+		AppID:       core.appfile.ID,
+		Infra:       TestAppTuple.Infra,
+		InfraFlavor: TestAppTuple.InfraFlavor,
+	}
+	older := &directory.Build{Lookup: lookup}
+	latest := &directory.Build{Lookup: lookup}
+	for _, b := range []*directory.Build{older, latest} {
+		if err := coreConfig.Directory.PutBuild(b); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Apps that deploy on their own can only deploy the latest artifact
+	if err := core.Deploy(&DeployOpts{ArtifactID: older.ID}); err == nil {
+		t.Fatal("should error")
+	}
+	if appMock.DeployCalled {
+		t.Fatal("Deploy shouldn't be called")
+	}
+	if err := core.Deploy(&DeployOpts{ArtifactID: latest.ID}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DeployCalled {
+		t.Fatal("Deploy should be called")
+	}
+}
+
+func TestCoreDeploy_promoted(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	appMock := &testArtifactDeployer{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	lookup := directory.Lookup{
+		AppID:       core.appfile.ID,
+		Infra:       TestAppTuple.Infra,
+		InfraFlavor: TestAppTuple.InfraFlavor,
+	}
+	built := &directory.Build{Lookup: lookup, Artifact: map[string]string{"ami": "ami-1"}}
+	promoted := &directory.Build{Lookup: lookup, Artifact: map[string]string{"ami": "ami-2"}}
+	promoted.Lookup.InfraName = core.appfile.Project.Infrastructure
+	for _, b := range []*directory.Build{built, promoted} {
+		if err := coreConfig.Directory.PutBuild(b); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// The build promoted to the active infrastructure is deployed
+	if err := core.Deploy(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.Deployed == nil || appMock.Deployed.Artifact["ami"] != "ami-2" {
+		t.Fatalf("bad: %#v", appMock.Deployed)
+	}
+	if appMock.DeployCalled {
+		t.Fatal("Deploy shouldn't be called")
+	}
+
+	// The builds of the type and flavor can still be deployed by ID
+	if err := core.Deploy(&DeployOpts{ArtifactID: built.ID}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.Deployed.Artifact["ami"] != "ami-1" {
+		t.Fatalf("bad: %#v", appMock.Deployed)
+	}
+
+	// Apps that deploy on their own can't deploy promoted builds
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock.Mock, nil
+	}
+	core = testCore(t, coreConfig)
+	if err := core.Deploy(nil); err == nil {
+		t.Fatal("should error")
+	}
+	if appMock.DeployCalled {
+		t.Fatal("Deploy shouldn't be called")
+	}
+}
+
+// testArtifactDeployer is an app that deploys the artifact it is given.
+type testArtifactDeployer struct {
+	*app.Mock
+
+	Deployed *directory.Build
+}
+
+func (a *testArtifactDeployer) DeployArtifact(
+	ctx *app.Context, build *directory.Build) error {
+	a.Deployed = build
+	return nil
+}
@@ -0,0 +1,131 @@
+package otto
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// Describe returns a summary of what Otto would work with for this
+// Appfile, meant to be shown to the user verbatim before running an
+// operation: the main application, the active infrastructure, the
+// dependencies in the order they're processed along with whether their
+// dev dependency is cached, and the directories the output is compiled
+// into.
+//
+// Parts that can't be determined, such as the tuple of an application
+// without a type, are marked as unresolved rather than causing an
+// error. This has no side effects.
+func (c *Core) Describe() (string, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Application: %s\n", c.describeApp(c.appfile))
+
+	infra := "(unresolved: no infrastructure is configured)"
+	if c.appfile.Project != nil {
+		infra = fmt.Sprintf("(unresolved: %s is not configured)",
+			c.appfile.Project.Infrastructure)
+		if config := c.appfile.ActiveInfrastructure(); config != nil {
+			infra = fmt.Sprintf("%s (type: %s, flavor: %s)",
+				config.Name, config.Type, config.Flavor)
+		}
+	}
+	fmt.Fprintf(&buf, "Infrastructure: %s\n", infra)
+
+	buf.WriteString("Dependencies:\n")
+	order, err := describeOrder(c.appfileCompiled.Graph)
+	if err != nil {
+		fmt.Fprintf(&buf, "  (unresolved: %s)\n", err)
+	}
+	deps := 0
+	for _, v := range order {
+		if v.File.ID == c.appfile.ID {
+			continue
+		}
+
+		deps++
+		fmt.Fprintf(&buf, "  %s, %s\n", c.describeApp(v.File), c.describeCache(v.File))
+	}
+	if deps == 0 && err == nil {
+		buf.WriteString("  (none)\n")
+	}
+
+	buf.WriteString("Output directories:\n")
+	for _, v := range order {
+		fmt.Fprintf(&buf, "  %s: %s\n", dag.VertexName(v), c.appOutputDir(v.File))
+	}
+	if c.appfile.Project != nil {
+		fmt.Fprintf(&buf, "  infrastructure: %s\n", c.infraOutputDir())
+	}
+
+	return buf.String(), nil
+}
+
+// describeApp returns the name and tuple of the application in f.
+func (c *Core) describeApp(f *appfile.File) string {
+	name := "(unnamed)"
+	if f.Application != nil && f.Application.Name != "" {
+		name = f.Application.Name
+	}
+
+	tuple, err := c.appTuple(f)
+	if err != nil {
+		return fmt.Sprintf("%s (unresolved: %s)", name, err)
+	}
+
+	return fmt.Sprintf("%s %s", name, tuple)
+}
+
+// describeCache returns whether a dev dependency is cached for f.
+func (c *Core) describeCache(f *appfile.File) string {
+	dir, err := c.appCacheDir(f)
+	if err != nil {
+		return fmt.Sprintf("cache unresolved: %s", err)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "dev-dep*.json"))
+	if err != nil {
+		return fmt.Sprintf("cache unresolved: %s", err)
+	}
+	if len(matches) == 0 {
+		return "not cached"
+	}
+
+	return "cached"
+}
+
+// describeOrder returns the applications in the graph in the order they
+// are processed, dependencies first and the root last. Applications
+// that are processed in parallel are ordered by name.
+func describeOrder(g *dag.AcyclicGraph) ([]*appfile.CompiledGraphVertex, error) {
+	root, err := g.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*appfile.CompiledGraphVertex
+	seen := make(map[dag.Vertex]struct{})
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if _, ok := seen[v]; ok {
+			return
+		}
+		seen[v] = struct{}{}
+
+		deps := dag.AsVertexList(g.DownEdges(v))
+		sort.Sort(vertexByName(deps))
+		for _, dep := range deps {
+			visit(dep)
+		}
+
+		result = append(result, v.(*appfile.CompiledGraphVertex))
+	}
+	visit(root)
+
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package otto
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCoreDescribe(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	actual, err := core.Describe()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		`Application: root ("test", "test", "test")`,
+		"Infrastructure: deps (type: test, flavor: test)",
+		`  child ("test", "test", "test"), not cached`,
+		"  child: " + filepath.Join(coreConfig.CompileDir, "dep-"),
+		"  root: " + filepath.Join(coreConfig.CompileDir, "app"),
+	}
+	for _, e := range expected {
+		if !strings.Contains(actual, e) {
+			t.Fatalf("missing %q:\n\n%s", e, actual)
+		}
+	}
+
+	// Describing doesn't create anything
+	if _, err := os.Stat(coreConfig.DataDir); !os.IsNotExist(err) {
+		t.Fatalf("data dir should not exist: %s", err)
+	}
+}
@@ -0,0 +1,143 @@
+package otto
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+)
+
+// DevStop stops the dev environment that DevDetached started in the
+// background, possibly from another process, and removes the record of
+// it from the directory.
+func (c *Core) DevStop() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
+	handle, err := c.devHandle()
+	if err != nil {
+		return err
+	}
+	if handle == nil {
+		return fmt.Errorf(
+			"No detached development environment is running for this\n" +
+				"application. Only environments started detached can be stopped.")
+	}
+
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return err
+	}
+	rootCtx, err := c.appContext(root.(*appfile.CompiledGraphVertex).File)
+	if err != nil {
+		return fmt.Errorf(
+			"Error loading App: %s", err)
+	}
+	rootApp, err := c.app(rootCtx)
+	if err != nil {
+		return fmt.Errorf(
+			"Error loading App: %s", err)
+	}
+	defer maybeClose(rootApp)
+
+	detacher, ok := rootApp.(app.DevDetacher)
+	if !ok {
+		return fmt.Errorf(
+			"The app type '%s' doesn't support detached development environments.",
+			rootCtx.Tuple.App)
+	}
+
+	log.Printf(
+		"[DEBUG] core: calling DevStop for root app '%s'",
+		rootCtx.Appfile.Application.Name)
+	if err := detacher.DevStop(rootCtx, handle); err != nil {
+		return wrapErrorf(err,
+			"Error stopping the development environment: %s")
+	}
+
+	return c.putDevHandle(nil)
+}
+
+// devDetacher returns the root app as an app.DevDetacher, and verifies
+// that it doesn't already run in the background.
+func (c *Core) devDetacher(impl app.App, ctx *app.Context) (app.DevDetacher, error) {
+	detacher, ok := impl.(app.DevDetacher)
+	if !ok {
+		return nil, fmt.Errorf(
+			"The app type '%s' doesn't support detached development environments.",
+			ctx.Tuple.App)
+	}
+
+	handle, err := c.devHandle()
+	if err != nil {
+		return nil, err
+	}
+	if handle != nil {
+		return nil, fmt.Errorf(
+			"A detached development environment is already running for this\n" +
+				"application. Stop it with DevStop before starting another one.")
+	}
+
+	return detacher, nil
+}
+
+// devStartDetached starts the dev environment in the background and
+// records its handle.
+func (c *Core) devStartDetached(detacher app.DevDetacher, ctx *app.Context) error {
+	handle, err := detacher.DevDetached(ctx)
+	if err != nil {
+		return err
+	}
+	if handle == nil {
+		handle = new(app.DevHandle)
+	}
+
+	if err := c.putDevHandle(handle); err != nil {
+		return fmt.Errorf(
+			"The development environment was started, but recording it failed,\n"+
+				"so it must be stopped manually: %s", err)
+	}
+
+	return nil
+}
+
+// devHandleKey returns the blob key of the handle of the detached dev
+// environment of this project.
+func (c *Core) devHandleKey() string {
+	return fmt.Sprintf("dev-detached-%s", c.appfile.ID)
+}
+
+// devHandleRecord is the record of the detached dev environment.
+type devHandleRecord struct {
+	// Handle is nil once the environment is stopped, since blobs can't be
+	// deleted.
+	Handle *app.DevHandle
+}
+
+// devHandle returns the handle of the detached dev environment, or nil
+// if none is running.
+func (c *Core) devHandle() (*app.DevHandle, error) {
+	var result devHandleRecord
+	if _, err := c.getRecord(c.devHandleKey(), &result); err != nil {
+		return nil, fmt.Errorf(
+			"Error reading detached development environment: %s", err)
+	}
+
+	return result.Handle, nil
+}
+
+// putDevHandle records the handle of the detached dev environment. A nil
+// handle records that none is running.
+func (c *Core) putDevHandle(handle *app.DevHandle) error {
+	return c.putRecord(c.devHandleKey(), &devHandleRecord{Handle: handle})
+}
@@ -0,0 +1,89 @@
+package otto
+
+import (
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreDevDetached(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := &testDevDetacher{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Nothing to stop yet
+	if err := core.DevStop(); err == nil {
+		t.Fatal("should error")
+	}
+
+	if err := core.DevDetached(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.DevCalled {
+		t.Fatal("Dev shouldn't be called")
+	}
+	if !appMock.DetachedCalled {
+		t.Fatal("DevDetached should be called")
+	}
+
+	// Only one can run at a time
+	if err := core.DevDetached(); err == nil {
+		t.Fatal("should error")
+	}
+
+	if err := core.DevStop(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.StopHandle == nil || appMock.StopHandle.PID != 42 {
+		t.Fatalf("bad: %#v", appMock.StopHandle)
+	}
+
+	// Stopped, so it can be started again
+	if err := core.DevStop(); err == nil {
+		t.Fatal("should error")
+	}
+	if err := core.DevDetached(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCoreDevDetached_unsupported(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.DevDetached(); err == nil {
+		t.Fatal("should error")
+	}
+	if appMock.DevCalled {
+		t.Fatal("Dev shouldn't be called")
+	}
+}
+
+type testDevDetacher struct {
+	*app.Mock
+
+	DetachedCalled bool
+	StopHandle     *app.DevHandle
+}
+
+func (d *testDevDetacher) DevDetached(*app.Context) (*app.DevHandle, error) {
+	d.DetachedCalled = true
+	return &app.DevHandle{PID: 42}, nil
+}
+
+func (d *testDevDetacher) DevStop(ctx *app.Context, h *app.DevHandle) error {
+	d.StopHandle = h
+	return nil
+}
@@ -0,0 +1,177 @@
+package otto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+)
+
+// DevDepCacheEntry describes a dev dependency cached in the DataDir.
+type DevDepCacheEntry struct {
+	// Key is the key the cache is stored under; see CacheKeyStrategy.
+	// It is given to ClearDevDepCache to remove the entry.
+	Key string
+
+	// AppID and Name are the Otto ID and application name of the
+	// dependency. The cache is shared by every project, so these are
+	// only known for the dependencies of this Appfile. For others, AppID
+	// is the Key if the cache is keyed by ID, and both are blank
+	// otherwise.
+	AppID string
+	Name  string
+
+	// Dir is the cache directory, Size is the total size in bytes of
+	// the files in it, and FileCount is the number of files in the
+	// newest cached dev dependency.
+	Dir       string
+	Size      int64
+	FileCount int
+
+	// ModTime is when the newest dev dependency was cached.
+	ModTime time.Time
+}
+
+// ListDevDepCache returns the dev dependencies cached in the DataDir,
+// sorted by key. Cache directories without a dev dependency, such as
+// the one of the main application, are left out. This has no side
+// effects.
+func (c *Core) ListDevDepCache() ([]DevDepCacheEntry, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	// The dependencies of this Appfile, by cache directory
+	known := make(map[string]*appfile.File)
+	for _, raw := range c.appfileCompiled.Graph.Vertices() {
+		f := raw.(*appfile.CompiledGraphVertex).File
+		dir, err := c.appCacheDir(f)
+		if err != nil {
+			return nil, err
+		}
+
+		known[dir] = f
+	}
+
+	root := filepath.Join(c.dataDir, "cache")
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result []DevDepCacheEntry
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+
+		entry, err := readDevDepCacheEntry(filepath.Join(root, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		if f, ok := known[entry.Dir]; ok {
+			entry.AppID = f.ID
+			if f.Application != nil {
+				entry.Name = f.Application.Name
+			}
+		} else if _, ok := c.cacheKey.(IDCacheKey); ok {
+			entry.AppID = entry.Key
+		}
+
+		result = append(result, *entry)
+	}
+
+	sort.Sort(devDepCacheEntrySlice(result))
+	return result, nil
+}
+
+// ClearDevDepCache removes the cached dev dependency with the given key,
+// as returned by ListDevDepCache, so that it is built again by the next
+// Dev.
+func (c *Core) ClearDevDepCache(key string) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if key == "" || key == "." || key == ".." || filepath.Base(key) != key {
+		return fmt.Errorf("invalid dev dependency cache key: %q", key)
+	}
+
+	dir := filepath.Join(c.dataDir, "cache", key)
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no dev dependency is cached with key: %s", key)
+		}
+
+		return err
+	}
+
+	return removeAll(dir)
+}
+
+// readDevDepCacheEntry reads the entry of the cache directory dir, which
+// is nil if it holds no dev dependency.
+func readDevDepCacheEntry(dir string) (*DevDepCacheEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "dev-dep*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &DevDepCacheEntry{Key: filepath.Base(dir), Dir: dir}
+	var newest string
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if newest == "" || info.ModTime().After(entry.ModTime) {
+			newest = path
+			entry.ModTime = info.ModTime()
+		}
+	}
+	if newest == "" {
+		return nil, nil
+	}
+
+	dep, err := app.ReadDevDep(newest)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", newest, err)
+	}
+	entry.FileCount = len(dep.Files)
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			entry.Size += info.Size()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// devDepCacheEntrySlice is a list of entries sortable by key.
+type devDepCacheEntrySlice []DevDepCacheEntry
+
+func (s devDepCacheEntrySlice) Len() int           { return len(s) }
+func (s devDepCacheEntrySlice) Less(i, j int) bool { return s[i].Key < s[j].Key }
+func (s devDepCacheEntrySlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,83 @@
+package otto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreListDevDepCache(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := &testDevDepFiles{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	// Nothing is cached yet
+	entries, err := core.ListDevDepCache()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A dependency of another project that shares the cache
+	other := filepath.Join(coreConfig.DataDir, "cache", "other")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := app.WriteDevDep(filepath.Join(other, "dev-dep.json"), &app.DevDep{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	entries, err = core.ListDevDepCache()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("bad: %#v", entries)
+	}
+	var child *DevDepCacheEntry
+	for i, e := range entries {
+		if e.Key != "other" {
+			child = &entries[i]
+		}
+	}
+	if child == nil || child.Name != "child" || child.AppID != child.Key {
+		t.Fatalf("bad: %#v", entries)
+	}
+	if child.FileCount != 1 || child.Size <= 3 || child.ModTime.IsZero() {
+		t.Fatalf("bad: %#v", child)
+	}
+
+	// Clearing a single entry keeps the others
+	if err := core.ClearDevDepCache(child.Key); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	entries, err = core.ListDevDepCache()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "other" || entries[0].AppID != "other" {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	if err := core.ClearDevDepCache(child.Key); err == nil {
+		t.Fatal("should error")
+	}
+	if err := core.ClearDevDepCache("../cache"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,414 @@
+package otto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+// in a unified diff.
+const diffContext = 3
+
+// diffMaxBytes is the largest file that is read into memory to produce a
+// unified diff. Larger files are compared by streaming them from disk and
+// are only marked as modified. It is a variable so tests can lower it.
+var diffMaxBytes int64 = 1024 * 1024
+
+// diffChunkSize is the size of the blocks files are compared in.
+const diffChunkSize = 32 * 1024
+
+// diffMaxCells is the upper bound on the size of the table used to
+// compute a line diff. Files larger than this are marked as modified
+// but no unified diff is produced for them.
+const diffMaxCells = 4 * 1024 * 1024
+
+// Diff is the difference between the output of two compilations.
+type Diff struct {
+	// Added and Removed are the slash-separated paths of files that
+	// only exist in the new or old output, respectively.
+	Added   []string
+	Removed []string
+
+	// Modified are the files that exist in both outputs but whose
+	// contents differ.
+	Modified []*FileDiff
+}
+
+// Empty returns true if there are no differences.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// FileDiff is the difference of a single file between two compilations.
+type FileDiff struct {
+	// Path is the slash-separated path of the file relative to the
+	// compile directory.
+	Path string
+
+	// Binary is true if either version of the file is not text. No
+	// unified diff is produced for binary files.
+	Binary bool
+
+	// Unified is the unified diff of the file. This is empty for binary
+	// files or files too large to diff.
+	Unified string
+}
+
+// CompileDiff compares the compiled output in the directory oldDir
+// against the compiled output in the directory newDir. If newDir is
+// empty, the compile directory of this Core is used, so a copy of an
+// older compilation can be compared to the current one.
+func (c *Core) CompileDiff(oldDir, newDir string) (*Diff, error) {
+	if newDir == "" {
+		if err := c.materialize(); err != nil {
+			return nil, err
+		}
+
+		newDir = c.compileDir
+	}
+
+	oldFiles, err := diffFiles(oldDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading compiled output %s: %s", oldDir, err)
+	}
+	newFiles, err := diffFiles(newDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading compiled output %s: %s", newDir, err)
+	}
+
+	result := new(Diff)
+	for _, path := range newFiles {
+		if !containsString(oldFiles, path) {
+			result.Added = append(result.Added, path)
+		}
+	}
+	for _, path := range oldFiles {
+		if !containsString(newFiles, path) {
+			result.Removed = append(result.Removed, path)
+			continue
+		}
+
+		fd, err := diffFile(
+			path,
+			filepath.Join(oldDir, filepath.FromSlash(path)),
+			filepath.Join(newDir, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, err
+		}
+		if fd != nil {
+			result.Modified = append(result.Modified, fd)
+		}
+	}
+
+	return result, nil
+}
+
+// diffFiles returns the sorted, slash-separated paths of all the
+// compiled files within dir.
+func diffFiles(dir string) ([]string, error) {
+	var result []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Our own metadata isn't part of the output.
+		if rel == manifestFilename || rel == "metadata.json" || rel == compileTreeFilename {
+			return nil
+		}
+
+		result = append(result, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// diffFile compares the two versions of a single file. If they are
+// identical, nil is returned. Files are compared by streaming them, so
+// only files small enough to produce a unified diff for are read into
+// memory.
+func diffFile(path, oldPath, newPath string) (*FileDiff, error) {
+	oldF, err := os.Open(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	defer oldF.Close()
+	newF, err := os.Open(newPath)
+	if err != nil {
+		return nil, err
+	}
+	defer newF.Close()
+
+	same, err := sameContents(oldF, newF)
+	if err != nil || same {
+		return nil, err
+	}
+
+	// Check the start of each file to determine whether it is text
+	result := &FileDiff{Path: path}
+	for _, f := range []*os.File{oldF, newF} {
+		binary, err := isBinaryFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if binary {
+			result.Binary = true
+			return result, nil
+		}
+	}
+
+	oldData, ok, err := readSmallFile(oldF)
+	if err != nil || !ok {
+		return result, err
+	}
+	newData, ok, err := readSmallFile(newF)
+	if err != nil || !ok {
+		return result, err
+	}
+
+	result.Unified = unifiedDiff(
+		"a/"+path, "b/"+path, splitLines(oldData), splitLines(newData))
+	return result, nil
+}
+
+// sameContents returns true if the two files have the same contents,
+// comparing them a block at a time.
+func sameContents(a, b *os.File) (bool, error) {
+	aInfo, err := a.Stat()
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := b.Stat()
+	if err != nil {
+		return false, err
+	}
+	if aInfo.Size() != bInfo.Size() {
+		return false, nil
+	}
+
+	aBuf := make([]byte, diffChunkSize)
+	bBuf := make([]byte, diffChunkSize)
+	for {
+		an, aErr := io.ReadFull(a, aBuf)
+		bn, bErr := io.ReadFull(b, bBuf)
+		if !bytes.Equal(aBuf[:an], bBuf[:bn]) {
+			return false, nil
+		}
+
+		aDone := aErr == io.EOF || aErr == io.ErrUnexpectedEOF
+		bDone := bErr == io.EOF || bErr == io.ErrUnexpectedEOF
+		if aErr != nil && !aDone {
+			return false, aErr
+		}
+		if bErr != nil && !bDone {
+			return false, bErr
+		}
+		if aDone || bDone {
+			return aDone && bDone, nil
+		}
+	}
+}
+
+// isBinaryFile returns true if the start of the file has a NUL byte,
+// which text doesn't.
+func isBinaryFile(f *os.File) (bool, error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return false, err
+	}
+
+	buf := make([]byte, 8000)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+
+	return bytes.IndexByte(buf[:n], 0) != -1, nil
+}
+
+// readSmallFile reads the whole file if it is at most diffMaxBytes long.
+// For larger files, ok is false.
+func readSmallFile(f *os.File) (data []byte, ok bool, err error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Size() > diffMaxBytes {
+		return nil, false, nil
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, false, err
+	}
+
+	data, err = ioutil.ReadAll(f)
+	return data, err == nil, err
+}
+
+// splitLines splits data into lines, keeping the line endings.
+func splitLines(data []byte) []string {
+	var result []string
+	for len(data) > 0 {
+		idx := bytes.IndexByte(data, '\n')
+		if idx == -1 {
+			result = append(result, string(data))
+			break
+		}
+
+		result = append(result, string(data[:idx+1]))
+		data = data[idx+1:]
+	}
+
+	return result
+}
+
+// diffOp is a single line of an edit script.
+type diffOp struct {
+	Kind byte // ' ', '-', or '+'
+	Line string
+}
+
+// unifiedDiff returns the unified diff between the lines a and b. If the
+// inputs are too large to diff, an empty string is returned.
+func unifiedDiff(nameA, nameB string, a, b []string) string {
+	if (len(a)+1)*(len(b)+1) > diffMaxCells {
+		return ""
+	}
+
+	// Compute the longest common subsequence table, where lcs[i][j] is
+	// the length of the LCS of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table to build the edit script
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// Group the edit script into hunks with surrounding context
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].Kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk until there is more than twice the context
+		// of unchanged lines, since the next change then gets its own.
+		end := start
+		for end < len(ops) {
+			next := end
+			for next < len(ops) && ops[next].Kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				break
+			}
+
+			end = next + 1
+		}
+
+		lo := start - diffContext
+		if lo < 0 {
+			lo = 0
+		}
+		hi := end + diffContext
+		if hi > len(ops) {
+			hi = len(ops)
+		}
+
+		// Determine the line numbers at the start of the hunk
+		lineA, lineB := 1, 1
+		for _, op := range ops[:lo] {
+			if op.Kind != '+' {
+				lineA++
+			}
+			if op.Kind != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, op := range ops[lo:hi] {
+			if op.Kind != '+' {
+				countA++
+			}
+			if op.Kind != '-' {
+				countB++
+			}
+		}
+		if countA == 0 {
+			lineA--
+		}
+		if countB == 0 {
+			lineB--
+		}
+
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, op := range ops[lo:hi] {
+			buf.WriteByte(op.Kind)
+			buf.WriteString(op.Line)
+			if len(op.Line) == 0 || op.Line[len(op.Line)-1] != '\n' {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		start = hi
+	}
+
+	return buf.String()
+}
+
+// containsString returns true if the sorted slice s contains v.
+func containsString(s []string, v string) bool {
+	idx := sort.SearchStrings(s, v)
+	return idx < len(s) && s[idx] == v
+}
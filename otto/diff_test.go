@@ -0,0 +1,152 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCoreCompileDiff(t *testing.T) {
+	oldDir := testDiffDir(t, map[string]string{
+		"app/main.tf":      "a\nb\nc\n",
+		"app/removed.txt":  "gone\n",
+		"app/same.txt":     "same\n",
+		"infra-aws/bin":    "\x00\x01",
+		"metadata.json":    "{}",
+		"foundation-x/foo": "foo\n",
+	})
+	defer os.RemoveAll(oldDir)
+
+	newDir := testDiffDir(t, map[string]string{
+		"app/main.tf":      "a\nB\nc\n",
+		"app/added.txt":    "new\n",
+		"app/same.txt":     "same\n",
+		"infra-aws/bin":    "\x00\x02",
+		"metadata.json":    "{\"changed\": true}",
+		"foundation-x/foo": "foo\n",
+	})
+	defer os.RemoveAll(newDir)
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+	diff, err := core.CompileDiff(oldDir, newDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(diff.Added, []string{"app/added.txt"}) {
+		t.Fatalf("bad added: %#v", diff.Added)
+	}
+	if !reflect.DeepEqual(diff.Removed, []string{"app/removed.txt"}) {
+		t.Fatalf("bad removed: %#v", diff.Removed)
+	}
+
+	expected := []*FileDiff{
+		&FileDiff{
+			Path: "app/main.tf",
+			Unified: "--- a/app/main.tf\n+++ b/app/main.tf\n" +
+				"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		&FileDiff{
+			Path:   "infra-aws/bin",
+			Binary: true,
+		},
+	}
+	if !reflect.DeepEqual(diff.Modified, expected) {
+		t.Fatalf("bad modified: %#v", diff.Modified)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		A, B   string
+		Output string
+	}{
+		{
+			"a\n",
+			"a\nb",
+			"@@ -1,1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+
+		{
+			"",
+			"a\n",
+			"@@ -0,0 +1,1 @@\n+a\n",
+		},
+
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n" +
+				"@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+
+	for i, tc := range cases {
+		actual := unifiedDiff(
+			"a", "b", splitLines([]byte(tc.A)), splitLines([]byte(tc.B)))
+		expected := "--- a\n+++ b\n" + tc.Output
+		if actual != expected {
+			t.Fatalf("%d: bad:\n\n%s\n\nexpected:\n\n%s", i, actual, expected)
+		}
+	}
+}
+
+func testDiffDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for path, contents := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	return dir
+}
+
+func TestDiffFile_large(t *testing.T) {
+	// Lower the limit so the files don't have to be as large
+	defer func(old int64) { diffMaxBytes = old }(diffMaxBytes)
+	diffMaxBytes = 64 * 1024
+	size := 2 * 1024 * 1024
+
+	oldPath := testLargeFile(t, 'a', size)
+	defer os.Remove(oldPath)
+	newPath := testLargeFile(t, 'b', size)
+	defer os.Remove(newPath)
+	samePath := testLargeFile(t, 'a', size)
+	defer os.Remove(samePath)
+
+	var diff, same *FileDiff
+	allocated := testAllocated(t, func() {
+		var err error
+		diff, err = diffFile("large", oldPath, newPath)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		same, err = diffFile("large", oldPath, samePath)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
+	if allocated > uint64(size/8) {
+		t.Fatalf("allocated too much: %d", allocated)
+	}
+
+	if diff == nil || diff.Unified != "" {
+		t.Fatalf("bad: %#v", diff)
+	}
+	if same != nil {
+		t.Fatalf("bad: %#v", same)
+	}
+}
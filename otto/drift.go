@@ -0,0 +1,86 @@
+package otto
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/ui"
+)
+
+// compileHashKey returns the key of the blob in the directory that holds
+// the hash of the last compilation of the Appfile.
+func (c *Core) compileHashKey() string {
+	return fmt.Sprintf("compile-hash-%s", c.appfile.ID)
+}
+
+// putCompileHash stores the hash of the last compilation in the directory.
+func (c *Core) putCompileHash(hash string) error {
+	return c.dir.PutBlob(c.compileHashKey(), &directory.BlobData{
+		Data: bytes.NewReader([]byte(hash)),
+	})
+}
+
+// getCompileHash returns the hash of the last compilation stored in the
+// directory, or an empty string if there is none.
+func (c *Core) getCompileHash() (string, error) {
+	blob, err := c.dir.GetBlob(c.compileHashKey())
+	if err != nil || blob == nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	data, err := ioutil.ReadAll(blob.Data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// DetectDrift compiles the Appfile into a temporary directory and
+// returns true if the result differs from the last compilation. The
+// current compiled output and the stored hash are left untouched, so
+// this can be used to preview whether changes to the Appfile would
+// change the compiled output.
+//
+// The comparison uses the aggregate hash of the compilation manifest.
+// Output that embeds the path of the compile directory will always be
+// reported as drifted.
+func (c *Core) DetectDrift() (bool, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	expected, err := c.getCompileHash()
+	if err != nil {
+		return false, fmt.Errorf("Error reading compilation hash: %s", err)
+	}
+	if expected == "" {
+		return false, fmt.Errorf(
+			"No previous compilation was found to compare against.\n" +
+				"Please compile the Appfile first.")
+	}
+
+	td, err := c.tempDir("drift")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(td)
+
+	other := c.WithDirs("", filepath.Join(td, "local"), filepath.Join(td, "compile"))
+	other.scratch = true
+	other.ui = new(ui.Null)
+	if err := other.Compile(); err != nil {
+		return false, err
+	}
+
+	m, err := other.Manifest()
+	if err != nil {
+		return false, err
+	}
+
+	return m.HashWith(c.hasher) != expected, nil
+}
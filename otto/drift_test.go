@@ -0,0 +1,65 @@
+package otto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreDetectDrift(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Nothing to compare against yet
+	if _, err := core.DetectDrift(); err == nil {
+		t.Fatal("should error")
+	}
+
+	contents := "foo"
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		f, err := os.Create(filepath.Join(ctx.Dir, "out"))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		_, err = f.WriteString(contents)
+		return nil, err
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	drift, err := core.DetectDrift()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if drift {
+		t.Fatal("should not drift")
+	}
+
+	// Change the output and check again, twice to verify the stored
+	// hash isn't replaced.
+	contents = "bar"
+	for i := 0; i < 2; i++ {
+		drift, err = core.DetectDrift()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !drift {
+			t.Fatal("should drift")
+		}
+	}
+
+	// The current output is untouched
+	if _, err := os.Stat(filepath.Join(coreConfig.CompileDir, "app", "out")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"regexp"
 
 	"golang.org/x/crypto/scrypt"
 )
@@ -18,8 +20,20 @@ const (
 	cryptKeySaltLen = 32
 )
 
+// errCryptAuth is returned by cryptRead if the data couldn't be
+// decrypted. GCM can't tell a wrong password from modified data.
+var errCryptAuth = errors.New(
+	"decryption failed: the password is incorrect or the " +
+		"encrypted data was corrupted or tampered with")
+
+// cryptVersionRe matches the version header of encrypted data. Every
+// version of the format starts with "v<number>:" so that we can detect
+// data written by newer versions of Otto.
+var cryptVersionRe = regexp.MustCompile(`^v([0-9]+):`)
+
 // cryptWrite is a helper to encrypt data and then write it to a file.
-// Encryption is done by using bcrypt as a KDF followed by AES-GCM.
+// Encryption is done by using scrypt as a KDF followed by AES-GCM, so
+// the data is authenticated: tampering or corruption is detected on read.
 func cryptWrite(dst string, password string, plaintext []byte) error {
 	keySalt := make([]byte, cryptKeySaltLen)
 	if _, err := rand.Read(keySalt); err != nil {
@@ -49,8 +63,8 @@ func cryptWrite(dst string, password string, plaintext []byte) error {
 
 	// Encrypt and tag with GCM
 	out := gcm.Seal(nil, nonce, plaintext, nil)
-	ciphertext := make([]byte, 0, len("v0:")+len(nonce)+len(out))
-	ciphertext = append(ciphertext, []byte("v0:")...)
+	ciphertext := make([]byte, 0, len(cryptPrefixV0)+len(keySalt)+len(nonce)+len(out))
+	ciphertext = append(ciphertext, []byte(cryptPrefixV0)...)
 	ciphertext = append(ciphertext, keySalt...)
 	ciphertext = append(ciphertext, nonce...)
 	ciphertext = append(ciphertext, out...)
@@ -68,6 +82,7 @@ func cryptWrite(dst string, password string, plaintext []byte) error {
 	return err
 }
 
+// cryptRead reads and decrypts data written with cryptWrite.
 func cryptRead(path string, password string) ([]byte, error) {
 	// Read the contents of the path first
 	ciphertext, err := ioutil.ReadFile(path)
@@ -75,15 +90,30 @@ func cryptRead(path string, password string) ([]byte, error) {
 		return nil, err
 	}
 
-	// Verify that the data looks valid
+	// Verify that the data looks valid and that we know the version
+	match := cryptVersionRe.FindSubmatch(ciphertext)
+	if match == nil {
+		return nil, fmt.Errorf("corrupt encrypted data: unknown format")
+	}
 	if !bytes.HasPrefix(ciphertext, []byte(cryptPrefixV0)) {
-		return nil, fmt.Errorf("corrupt encrypted data")
+		return nil, fmt.Errorf(
+			"encrypted data is format version %s, which this version of Otto\n"+
+				"can't read. It was likely written by a newer version of Otto.\n"+
+				"Please upgrade Otto to read this data.", match[1])
 	}
 
+	return cryptReadV0(ciphertext[len(cryptPrefixV0):], password)
+}
+
+// cryptReadV0 decrypts data in the v0 format, with the version header
+// already removed: the key salt, the GCM nonce, then the sealed data.
+func cryptReadV0(ciphertext []byte, password string) ([]byte, error) {
 	// Read our key salt
-	ciphertext = ciphertext[len(cryptPrefixV0):]
-	keySalt := ciphertext[:32]
-	ciphertext = ciphertext[32:]
+	if len(ciphertext) < cryptKeySaltLen {
+		return nil, fmt.Errorf("corrupt encrypted data: too short")
+	}
+	keySalt := ciphertext[:cryptKeySaltLen]
+	ciphertext = ciphertext[cryptKeySaltLen:]
 
 	// Derive the key
 	key, err := scrypt.Key([]byte(password), keySalt, 16384, 8, 1, 32)
@@ -104,9 +134,18 @@ func cryptRead(path string, password string) ([]byte, error) {
 	}
 
 	// Get the nonce and ciphertext out
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("corrupt encrypted data: too short")
+	}
 	nonce := ciphertext[:gcm.NonceSize()]
 	ciphertext = ciphertext[gcm.NonceSize():]
 
-	// Decrypt
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	// Decrypt. GCM authenticates the data so any failure here is
+	// either the wrong password or data that was modified.
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errCryptAuth
+	}
+
+	return plaintext, nil
 }
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -43,3 +44,53 @@ func TestCrypt(t *testing.T) {
 		}
 	}
 }
+
+func TestCryptRead_invalid(t *testing.T) {
+	// Create a temporary file. We only need the path
+	f, err := ioutil.TempFile("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f.Close()
+	path := f.Name()
+	defer os.Remove(path)
+
+	if err := cryptWrite(path, "foo", []byte("bar")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Flip a bit in the sealed data
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-1] ^= 1
+
+	cases := []struct {
+		Name     string
+		Data     []byte
+		Password string
+		Contains string
+	}{
+		{"wrong password", data, "bar", "password is incorrect"},
+		{"tampered", tampered, "foo", "tampered"},
+		{"truncated", data[:10], "foo", "too short"},
+		{"newer version", []byte("v7:foo"), "foo", "upgrade"},
+		{"garbage", []byte("nope"), "foo", "unknown format"},
+	}
+
+	for _, tc := range cases {
+		if err := ioutil.WriteFile(path, tc.Data, 0600); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		_, err := cryptRead(path, tc.Password)
+		if err == nil {
+			t.Fatalf("%s: should error", tc.Name)
+		}
+		if !strings.Contains(err.Error(), tc.Contains) {
+			t.Fatalf("%s: bad: %s", tc.Name, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package otto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+// EnvVarPrefix is the prefix of the keys of the variables in the result
+// of EnvFor, which keeps them apart from the environment variables.
+const EnvVarPrefix = "var."
+
+// secretEnvWords are the words that make EnvFor consider a key secret.
+var secretEnvWords = []string{
+	"CREDENTIAL", "KEY", "PASS", "PRIVATE", "SECRET", "TOKEN",
+}
+
+// EnvFor returns the environment that the context of the application
+// with the given name in the dependency graph is given: the environment
+// variables from CoreConfig.Env with their names, and the variables from
+// CoreConfig.VarSources with their names prefixed with EnvVarPrefix.
+//
+// This is meant for debugging why a value doesn't make it to an app, so
+// values whose key looks like a secret, such as "AWS_SECRET_KEY" or
+// "db_password", are redacted. This has no side effects other than
+// reading the variable sources.
+func (c *Core) EnvFor(name string) (map[string]string, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	var names []string
+	found := false
+	for _, v := range c.appfileCompiled.Graph.Vertices() {
+		n := dag.VertexName(v)
+		if n == name {
+			found = true
+			break
+		}
+
+		names = append(names, n)
+	}
+	if !found {
+		sort.Strings(names)
+		return nil, fmt.Errorf(
+			"Application %q not found in the dependency graph. The\n"+
+				"applications are: %s",
+			name, strings.Join(names, ", "))
+	}
+
+	vars, err := c.vars()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(c.env)+len(vars))
+	for k, v := range c.env {
+		result[k] = redactEnv(k, v)
+	}
+	for k, v := range vars {
+		result[EnvVarPrefix+k] = redactEnv(k, v)
+	}
+
+	return result, nil
+}
+
+// redactEnv returns the value to show for the key, which is redacted if
+// the key looks like a secret.
+func redactEnv(k, v string) string {
+	upper := strings.ToUpper(k)
+	for _, word := range secretEnvWords {
+		if strings.Contains(upper, word) {
+			return credsRedacted
+		}
+	}
+
+	return v
+}
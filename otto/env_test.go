@@ -0,0 +1,43 @@
+package otto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoreEnvFor(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	coreConfig.Env = map[string]string{
+		"REGION":         "us-east-1",
+		"AWS_SECRET_KEY": "hunter2",
+	}
+	coreConfig.VarSources = []VarSource{
+		VarsFunc(func() (map[string]string, error) {
+			return map[string]string{
+				"size":        "small",
+				"db_password": "hunter2",
+			}, nil
+		}),
+	}
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	env, err := core.EnvFor("child")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]string{
+		"REGION":          "us-east-1",
+		"AWS_SECRET_KEY":  "<redacted>",
+		"var.size":        "small",
+		"var.db_password": "<redacted>",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("bad: %#v", env)
+	}
+
+	if _, err := core.EnvFor("nope"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -1,5 +1,10 @@
 package otto
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Error is the interface implemented by many errors within Otto. You
 // can use it to check what the type of an error is via the list of
 // error codes below.
@@ -20,3 +25,55 @@ func (e *codedError) Code() string         { return e.code }
 
 // errwrap.Wrapper impl.
 func (e *codedError) WrappedErrors() []error { return []error{e.OriginalError()} }
+
+// wrappedError is an error that adds context to an underlying error,
+// which it keeps so that ExitCode can still find the exit code of a
+// failed subprocess.
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string { return e.msg }
+
+// errwrap.Wrapper impl.
+func (e *wrappedError) WrappedErrors() []error { return []error{e.err} }
+
+// wrapErrorf is like fmt.Errorf with err as the last argument, but the
+// result wraps err.
+func wrapErrorf(err error, format string, args ...interface{}) error {
+	return &wrappedError{
+		msg: fmt.Sprintf(format, append(args, err)...),
+		err: err,
+	}
+}
+
+// WalkError is the error type returned when an operation fails while
+// walking the dependency graph. It records the path of dependencies
+// from the root application to the vertex that failed, which helps
+// explain why a dependency is part of the operation at all.
+type WalkError struct {
+	// Name is the name of the vertex that failed.
+	Name string
+
+	// Path is the list of vertex names from the root application to
+	// the failing vertex, inclusive on both ends.
+	Path []string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *WalkError) Error() string {
+	if len(e.Path) <= 1 {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf(
+		"%s\n\nDependency path: %s", e.Err, strings.Join(e.Path, " -> "))
+}
+
+func (e *WalkError) OriginalError() error { return e.Err }
+
+// errwrap.Wrapper impl.
+func (e *WalkError) WrappedErrors() []error { return []error{e.Err} }
@@ -0,0 +1,194 @@
+package otto
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/ui"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// timingsFilename is the name of the file in the DataDir where the
+// historical durations of processing each app tuple are stored.
+const timingsFilename = "timings.json"
+
+// timingsMax is the number of durations kept per tuple.
+const timingsMax = 10
+
+// walkProgress tracks the progress of a walk of the dependency graph,
+// estimating the remaining time from the historical durations of each
+// app tuple. It is safe for concurrent use.
+type walkProgress struct {
+	sync.Mutex
+
+	ui      ui.Ui
+	path    string
+	total   int
+	done    int
+	pending map[dag.Vertex]app.Tuple
+	history map[string][]time.Duration
+}
+
+// newWalkProgress creates a walkProgress for the given vertices and their
+// tuples, loading the historical durations from dataDir.
+func newWalkProgress(
+	u ui.Ui, dataDir string, vertices map[dag.Vertex]app.Tuple) *walkProgress {
+	result := &walkProgress{
+		ui:      u,
+		path:    filepath.Join(dataDir, timingsFilename),
+		total:   len(vertices),
+		pending: vertices,
+		history: make(map[string][]time.Duration),
+	}
+
+	data, err := ioutil.ReadFile(result.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[WARN] error reading timings: %s", err)
+		}
+
+		return result
+	}
+
+	var raw map[string][]int64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		log.Printf("[WARN] error reading timings: %s", err)
+		return result
+	}
+	for k, vs := range raw {
+		for _, v := range vs {
+			result.history[k] = append(result.history[k], time.Duration(v))
+		}
+	}
+
+	return result
+}
+
+// Done records that the vertex finished in the given duration and
+// reports the progress to the UI.
+func (p *walkProgress) Done(v dag.Vertex, d time.Duration, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	tuple, ok := p.pending[v]
+	if !ok {
+		return
+	}
+	delete(p.pending, v)
+	p.done++
+
+	// Only successful runs are representative of how long they take
+	if err == nil {
+		key := tuple.String()
+		durations := append(p.history[key], d)
+		if len(durations) > timingsMax {
+			durations = durations[len(durations)-timingsMax:]
+		}
+		p.history[key] = durations
+	}
+
+	// Nothing interesting to report for a single application or once
+	// everything is done.
+	if p.total <= 1 || len(p.pending) == 0 || err != nil {
+		return
+	}
+
+	msg := fmt.Sprintf(
+		"[reset]Progress: %d of %d applications done", p.done, p.total)
+	if eta, ok := p.eta(); ok {
+		msg += fmt.Sprintf(", about %s remaining", eta)
+	}
+	p.ui.Message(msg)
+}
+
+// Save stores the historical durations in the DataDir.
+func (p *walkProgress) Save() error {
+	p.Lock()
+	defer p.Unlock()
+
+	raw := make(map[string][]int64)
+	for k, vs := range p.history {
+		for _, v := range vs {
+			raw[k] = append(raw[k], int64(v))
+		}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(p.path, data, 0644)
+}
+
+// eta returns the estimated remaining time. This is false if there is no
+// history for any of the pending tuples. Must be called with the lock held.
+func (p *walkProgress) eta() (time.Duration, bool) {
+	var result time.Duration
+	var ok bool
+	for _, tuple := range p.pending {
+		if d, found := medianDuration(p.history[tuple.String()]); found {
+			result += d
+			ok = true
+		}
+	}
+
+	return (result / time.Second) * time.Second, ok
+}
+
+// medianDuration returns the median of the durations.
+func medianDuration(ds []time.Duration) (time.Duration, bool) {
+	if len(ds) == 0 {
+		return 0, false
+	}
+
+	sorted := make([]time.Duration, len(ds))
+	copy(sorted, ds)
+	sort.Sort(durationSlice(sorted))
+	if len(sorted)%2 == 1 {
+		return sorted[len(sorted)/2], true
+	}
+
+	mid := len(sorted) / 2
+	return (sorted[mid-1] + sorted[mid]) / 2, true
+}
+
+// durationSlice implements sort.Interface for durations.
+type durationSlice []time.Duration
+
+func (s durationSlice) Len() int           { return len(s) }
+func (s durationSlice) Less(i, j int) bool { return s[i] < s[j] }
+func (s durationSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// walkTuples returns the tuples of all the vertices in the graph that are
+// in the given scope. Vertices whose tuple can't be determined are skipped.
+func (c *Core) walkTuples(g *dag.AcyclicGraph, scope string) map[dag.Vertex]app.Tuple {
+	result := make(map[dag.Vertex]app.Tuple)
+	for _, raw := range g.Vertices() {
+		v, ok := raw.(*appfile.CompiledGraphVertex)
+		if !ok || !v.InScope(scope) {
+			continue
+		}
+
+		tuple, err := c.appTuple(v.File)
+		if err != nil {
+			continue
+		}
+
+		result[raw] = tuple
+	}
+
+	return result
+}
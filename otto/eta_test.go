@@ -0,0 +1,73 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/ui"
+	"github.com/hashicorp/terraform/dag"
+)
+
+func TestWalkProgress(t *testing.T) {
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	vertices := func() map[dag.Vertex]app.Tuple {
+		return map[dag.Vertex]app.Tuple{
+			"a": app.Tuple{App: "a", Infra: "aws", InfraFlavor: "simple"},
+			"b": app.Tuple{App: "b", Infra: "aws", InfraFlavor: "simple"},
+			"c": app.Tuple{App: "c", Infra: "aws", InfraFlavor: "simple"},
+		}
+	}
+
+	// The first run has no history, so only the counts are reported
+	uiMock := new(ui.Mock)
+	p := newWalkProgress(uiMock, td, vertices())
+	p.Done("a", 10*time.Second, nil)
+	p.Done("b", 20*time.Second, nil)
+	p.Done("c", 30*time.Second, nil)
+	if len(uiMock.MessageBuf) != 2 {
+		t.Fatalf("bad: %#v", uiMock.MessageBuf)
+	}
+	if msg := uiMock.MessageBuf[0]; !strings.Contains(msg, "1 of 3") || strings.Contains(msg, "remaining") {
+		t.Fatalf("bad: %s", msg)
+	}
+	if err := p.Save(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The next run estimates from the median of the history
+	uiMock = new(ui.Mock)
+	p = newWalkProgress(uiMock, td, vertices())
+	p.Done("a", 10*time.Second, nil)
+	expected := "1 of 3 applications done, about 50s remaining"
+	if msg := uiMock.MessageBuf[0]; !strings.Contains(msg, expected) {
+		t.Fatalf("bad: %s", msg)
+	}
+}
+
+func TestMedianDuration(t *testing.T) {
+	cases := []struct {
+		Input  []time.Duration
+		Output time.Duration
+		Ok     bool
+	}{
+		{nil, 0, false},
+		{[]time.Duration{3, 1, 2}, 2, true},
+		{[]time.Duration{4, 1, 3, 2}, 2, true},
+	}
+
+	for i, tc := range cases {
+		actual, ok := medianDuration(tc.Input)
+		if actual != tc.Output || ok != tc.Ok {
+			t.Fatalf("%d: bad: %s %v", i, actual, ok)
+		}
+	}
+}
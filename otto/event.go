@@ -0,0 +1,180 @@
+package otto
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+)
+
+// DefaultEventHistorySize is the number of events kept for EventHistory
+// if CoreConfig.EventHistorySize isn't set.
+const DefaultEventHistorySize = 100
+
+// eventChanSize is the buffer size of the channels returned by Subscribe.
+// Events are dropped for subscribers that fall this far behind.
+const eventChanSize = 64
+
+// CoreEventType is the type of a CoreEvent.
+type CoreEventType string
+
+const (
+	// CoreEventVertexStart and CoreEventVertexDone are sent as each
+	// application in the dependency graph is processed by Compile
+	// and Dev, just like the calls to WalkHook.
+	CoreEventVertexStart CoreEventType = "vertex-start"
+	CoreEventVertexDone  CoreEventType = "vertex-done"
+
+	// CoreEventBuildStart and CoreEventBuildDone are sent around the
+	// build of the main application by Build and BuildAsync.
+	CoreEventBuildStart CoreEventType = "build-start"
+	CoreEventBuildDone  CoreEventType = "build-done"
+)
+
+// CoreEvent is an event that happened during an operation on a Core.
+type CoreEvent struct {
+	Type CoreEventType
+	Time time.Time
+
+	// Tuple and Name identify the application the event is about.
+	Tuple app.Tuple
+	Name  string
+
+	// Error is the error message for a failed vertex or build. It is a string
+	// rather than an error so events can be easily serialized.
+	Error string
+}
+
+// EventHistory returns the most recent events, oldest first. The number
+// of events kept is set by CoreConfig.EventHistorySize.
+func (c *Core) EventHistory() []CoreEvent {
+	c.events.Lock()
+	defer c.events.Unlock()
+
+	return c.events.history()
+}
+
+// Subscribe returns the most recent events like EventHistory along with
+// a channel that receives every event after them, so a subscriber never
+// misses or repeats an event. The returned function must be called to
+// unsubscribe, which closes the channel.
+//
+// Events are never blocked on a slow subscriber. If the channel fills
+// up, new events are dropped for that subscriber.
+func (c *Core) Subscribe() ([]CoreEvent, <-chan CoreEvent, func()) {
+	return c.events.subscribe()
+}
+
+// event records the event and sends it to the subscribers.
+func (c *Core) event(e CoreEvent) {
+	if e.Time.IsZero() {
+		e.Time = c.clock.Now()
+	}
+
+	c.events.Lock()
+	defer c.events.Unlock()
+
+	c.events.send(e, c.eventHistoryLimit())
+}
+
+// eventHistoryLimit returns the number of events to keep in a history.
+func (c *Core) eventHistoryLimit() int {
+	if c.eventHistorySize == 0 {
+		return DefaultEventHistorySize
+	}
+
+	return c.eventHistorySize
+}
+
+// eventLog is a ring buffer of the recent events along with the channels
+// subscribed to new ones. The zero value is ready to use.
+type eventLog struct {
+	sync.Mutex
+
+	buf    []CoreEvent
+	next   int
+	subs   map[chan CoreEvent]struct{}
+	closed bool
+}
+
+// subscribe returns the stored events and a channel for the events
+// sent after them, along with the function that unsubscribes. If the
+// log is closed, the channel is already closed.
+func (l *eventLog) subscribe() ([]CoreEvent, <-chan CoreEvent, func()) {
+	l.Lock()
+	defer l.Unlock()
+
+	ch := make(chan CoreEvent, eventChanSize)
+	if l.closed {
+		close(ch)
+		return l.history(), ch, func() {}
+	}
+	if l.subs == nil {
+		l.subs = make(map[chan CoreEvent]struct{})
+	}
+	l.subs[ch] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			l.Lock()
+			defer l.Unlock()
+
+			if _, ok := l.subs[ch]; ok {
+				delete(l.subs, ch)
+				close(ch)
+			}
+		})
+	}
+
+	return l.history(), ch, cancel
+}
+
+// send stores the event and sends it to the subscribers. The lock must
+// be held.
+func (l *eventLog) send(e CoreEvent, size int) {
+	l.add(e, size)
+	for ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+			log.Printf("[WARN] core: subscriber is full, dropping event: %s", e.Type)
+		}
+	}
+}
+
+// close closes the channels of the subscribers, marking the end of the
+// events. The lock must be held.
+func (l *eventLog) close() {
+	for ch := range l.subs {
+		close(ch)
+	}
+
+	l.subs = nil
+	l.closed = true
+}
+
+// add adds an event, overwriting the oldest one once size events are
+// stored. A negative size disables the history.
+func (l *eventLog) add(e CoreEvent, size int) {
+	if size < 0 {
+		return
+	}
+
+	if len(l.buf) < size {
+		l.buf = append(l.buf, e)
+		return
+	}
+
+	l.buf[l.next] = e
+	l.next = (l.next + 1) % len(l.buf)
+}
+
+// history returns a copy of the stored events, oldest first.
+func (l *eventLog) history() []CoreEvent {
+	result := make([]CoreEvent, 0, len(l.buf))
+	result = append(result, l.buf[l.next:]...)
+	result = append(result, l.buf[:l.next]...)
+	return result
+}
@@ -0,0 +1,65 @@
+package otto
+
+import (
+	"testing"
+)
+
+func TestCoreEventHistory(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	coreConfig.EventHistorySize = 3
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	history, ch, cancel := core.Subscribe()
+	defer cancel()
+	if len(history) != 0 {
+		t.Fatalf("bad: %#v", history)
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Only the most recent events are kept, the root is done last
+	history = core.EventHistory()
+	if len(history) != 3 {
+		t.Fatalf("bad: %#v", history)
+	}
+	last := history[len(history)-1]
+	if last.Type != CoreEventVertexDone || last.Name != "root" {
+		t.Fatalf("bad: %#v", last)
+	}
+
+	// The subscriber got every event
+	for i := 0; i < 4; i++ {
+		select {
+		case e := <-ch:
+			if i == 0 && (e.Type != CoreEventVertexStart || e.Name != "child") {
+				t.Fatalf("bad: %#v", e)
+			}
+		default:
+			t.Fatalf("missing event %d", i)
+		}
+	}
+
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel should be closed")
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	var l eventLog
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		l.add(CoreEvent{Name: name}, 3)
+	}
+
+	var actual []string
+	for _, e := range l.history() {
+		actual = append(actual, e.Name)
+	}
+	if len(actual) != 3 || actual[0] != "c" || actual[2] != "e" {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
@@ -1,10 +1,14 @@
 package otto
 
+import (
+	"fmt"
+)
+
 // ExecuteTask is an enum of available tasks to execute.
 type ExecuteTask uint
 
 const (
-	ExecuteTaskInvalid ExecuteTask = 0
+	ExecuteTaskInvalid ExecuteTask = iota
 	ExecuteTaskDev
 )
 
@@ -24,3 +28,23 @@ type ExecuteOpts struct {
 	// Args are additional arguments to the task
 	Args []string
 }
+
+// Validate checks that the options are valid for execution.
+func (opts *ExecuteOpts) Validate() error {
+	if opts == nil {
+		return fmt.Errorf("options for execute are required")
+	}
+
+	switch opts.Task {
+	case ExecuteTaskDev:
+		// The dev environment is created with Core.Dev, so executing
+		// against it always requires an action.
+		if opts.Action == "" {
+			return fmt.Errorf("an action is required for task: %s", opts.Task)
+		}
+	default:
+		return fmt.Errorf("unknown task: %s", opts.Task)
+	}
+
+	return nil
+}
@@ -4,9 +4,9 @@ package otto
 
 import "fmt"
 
-const _ExecuteTask_name = "ExecuteTaskInvalid"
+const _ExecuteTask_name = "ExecuteTaskInvalidExecuteTaskDev"
 
-var _ExecuteTask_index = [...]uint8{0, 18}
+var _ExecuteTask_index = [...]uint8{0, 18, 32}
 
 func (i ExecuteTask) String() string {
 	if i >= ExecuteTask(len(_ExecuteTask_index)-1) {
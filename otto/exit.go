@@ -0,0 +1,59 @@
+package otto
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// ExitCoder is implemented by errors that carry the exit code of a
+// subprocess that failed, such as context.ExitError, which app and
+// infrastructure implementations can return.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// ExitCode returns the exit code that a command that got err should
+// exit with. This is zero if err is nil and the exit code of the failed
+// subprocess if err, or any error it wraps, carries one. Otherwise, it
+// is 1.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if code := exitCode(err); code > 0 {
+		return code
+	}
+
+	return 1
+}
+
+// exitCode searches err and the errors it wraps for an exit code. Zero
+// is returned if there isn't one.
+func exitCode(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case ExitCoder:
+		if code := e.ExitCode(); code > 0 {
+			return code
+		}
+	case *exec.ExitError:
+		if status, ok := e.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+
+	// Look through the wrapped errors, such as a WalkError
+	if w, ok := err.(interface {
+		WrappedErrors() []error
+	}); ok {
+		for _, wrapped := range w.WrappedErrors() {
+			if code := exitCode(wrapped); code > 0 {
+				return code
+			}
+		}
+	}
+
+	return 0
+}
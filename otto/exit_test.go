@@ -0,0 +1,61 @@
+package otto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/otto/context"
+)
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		Err      error
+		Expected int
+	}{
+		{nil, 0},
+		{errors.New("foo"), 1},
+		{&context.ExitError{Code: 3}, 3},
+		{&context.ExitError{Code: 0}, 1},
+		{
+			&WalkError{
+				Name: "child",
+				Err:  &context.ExitError{Code: 42, Err: errors.New("foo")},
+			},
+			42,
+		},
+		{
+			&codedError{err: &context.ExitError{Code: 7}},
+			7,
+		},
+		{
+			wrapErrorf(&context.ExitError{Code: 5}, "Error: %s"),
+			5,
+		},
+	}
+
+	for _, tc := range cases {
+		if actual := ExitCode(tc.Err); actual != tc.Expected {
+			t.Fatalf("bad: %#v: %d", tc.Err, actual)
+		}
+	}
+}
+
+func TestCoreDev_devDepExitCode(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The exit code of a failed dev dependency build is kept
+	appMock.DevDepErr = &context.ExitError{Code: 3, Err: errors.New("build failed")}
+	err := core.Dev()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if code := ExitCode(err); code != 3 {
+		t.Fatalf("bad: %d: %s", code, err)
+	}
+}
@@ -0,0 +1,149 @@
+package otto
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileHashCacheVersion is the version of the format of the file hash
+// cache. Caches of another version are discarded.
+const fileHashCacheVersion = 1
+
+// fileHashRacyWindow is how recently a file can have been modified
+// before it was hashed for its cached hash to be trusted. File systems
+// record modification times with limited precision, so a file changed
+// again within this window of being hashed could keep the same time and
+// size.
+const fileHashRacyWindow = 2 * time.Second
+
+// fileHashCacheLock serializes the updates of the file hash caches of
+// this process, since cache keys are computed in parallel.
+var fileHashCacheLock sync.Mutex
+
+// fileHashCache caches the hashes of files by their modification time
+// and size, so that unchanged files don't have to be read to be hashed
+// again. It is stored as JSON at its path.
+type fileHashCache struct {
+	Version int                       `json:"version"`
+	Hasher  string                    `json:"hasher"`
+	Files   map[string]*fileHashEntry `json:"files"`
+
+	path   string
+	hasher Hasher
+}
+
+type fileHashEntry struct {
+	ModTime  int64  `json:"mtime"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+	Recorded int64  `json:"recorded"`
+}
+
+// loadFileHashCache reads the cache at path. If it is missing, corrupt,
+// of another version, or for another hash algorithm, an empty cache is
+// returned so that every file is hashed again.
+func loadFileHashCache(path string, h Hasher) *fileHashCache {
+	result := &fileHashCache{
+		Version: fileHashCacheVersion,
+		Hasher:  h.Name(),
+		Files:   make(map[string]*fileHashEntry),
+		path:    path,
+		hasher:  h,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[WARN] error reading file hash cache %s: %s", path, err)
+		}
+
+		return result
+	}
+
+	var stored fileHashCache
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Printf("[WARN] file hash cache %s is corrupt, ignoring it: %s", path, err)
+		return result
+	}
+	if stored.Version != fileHashCacheVersion || stored.Hasher != h.Name() || stored.Files == nil {
+		log.Printf("[DEBUG] file hash cache %s is outdated, ignoring it", path)
+		return result
+	}
+
+	result.Files = stored.Files
+	return result
+}
+
+// Hash returns the hash of the file at path, from the cache if the file
+// has the same modification time and size as when it was hashed and
+// wasn't modified within fileHashRacyWindow of being hashed.
+func (c *fileHashCache) Hash(path string, info os.FileInfo) (string, error) {
+	mtime := info.ModTime().UnixNano()
+	if e, ok := c.Files[path]; ok &&
+		e.ModTime == mtime &&
+		e.Size == info.Size() &&
+		mtime+int64(fileHashRacyWindow) <= e.Recorded {
+		return e.Hash, nil
+	}
+
+	hash, err := hashFile(c.hasher, path)
+	if err != nil {
+		return "", err
+	}
+
+	c.Files[path] = &fileHashEntry{
+		ModTime:  mtime,
+		Size:     info.Size(),
+		Hash:     hash,
+		Recorded: time.Now().UnixNano(),
+	}
+	return hash, nil
+}
+
+// Prune removes the entries of the files in dir that aren't in seen, so
+// that the cache doesn't grow with files that were deleted.
+func (c *fileHashCache) Prune(dir string, seen map[string]struct{}) {
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+	for path := range c.Files {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if _, ok := seen[path]; !ok {
+			delete(c.Files, path)
+		}
+	}
+}
+
+// Save writes the cache to its path. It is written to a temporary file
+// that replaces the cache so that a concurrent reader never sees a
+// partial cache.
+func (c *fileHashCache) Save() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), c.path)
+}
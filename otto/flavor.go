@@ -0,0 +1,95 @@
+package otto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/otto/appfile"
+)
+
+// validateFlavor verifies that the infrastructure implementation supports
+// the flavor of config, so that an invalid flavor is reported as such
+// rather than as a missing app implementation for the tuple.
+func (c *Core) validateFlavor(config *appfile.Infrastructure) error {
+	flavors, err := c.infraFlavors(config.Type)
+	if err != nil {
+		return err
+	}
+
+	return checkFlavor(config, flavors)
+}
+
+// infraFlavors returns the flavors that the infrastructure type supports.
+// The implementation is only started the first time, since contexts are
+// built for every app in the graph. Types that aren't registered have no
+// flavors; using them fails elsewhere with a clearer error.
+func (c *Core) infraFlavors(t string) ([]string, error) {
+	if flavors, ok := c.flavors.get(t); ok {
+		return flavors, nil
+	}
+
+	f, ok := c.infras[t]
+	if !ok {
+		return nil, nil
+	}
+
+	infra, err := f()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error loading infrastructure %s: %s", t, err)
+	}
+	if infra == nil {
+		return nil, fmt.Errorf(
+			"infrastructure factory for type %s returned nil", t)
+	}
+	defer maybeClose(infra)
+
+	return c.flavors.put(t, infra.Flavors()), nil
+}
+
+// checkFlavor returns an error if the flavor of config isn't one of
+// flavors. Infrastructures that declare no flavors, and an Appfile that
+// doesn't give one, aren't checked.
+func checkFlavor(config *appfile.Infrastructure, flavors []string) error {
+	if len(flavors) == 0 || config.Flavor == "" {
+		return nil
+	}
+
+	for _, f := range flavors {
+		if f == config.Flavor {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"infrastructure %s does not support flavor %q; valid flavors are: %s",
+		config.Type, config.Flavor, strings.Join(flavors, ", "))
+}
+
+// infraFlavors caches the flavors of the infrastructure implementations
+// by type. It is safe for concurrent use.
+type infraFlavors struct {
+	sync.Mutex
+	flavors map[string][]string
+}
+
+func (f *infraFlavors) get(t string) ([]string, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	result, ok := f.flavors[t]
+	return result, ok
+}
+
+// put caches the flavors of the type and returns them.
+func (f *infraFlavors) put(t string, flavors []string) []string {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.flavors == nil {
+		f.flavors = make(map[string][]string)
+	}
+	f.flavors[t] = flavors
+	return flavors
+}
@@ -0,0 +1,37 @@
+package otto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoreApp_invalidFlavor(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	infra := TestInfra(t, "test", coreConfig)
+	infra.FlavorsResult = []string{"simple", "vpc"}
+	core := testCore(t, coreConfig)
+
+	_, _, err := core.App()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), `does not support flavor "test"`) ||
+		!strings.Contains(err.Error(), "simple, vpc") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if _, _, err := core.infra(); err == nil {
+		t.Fatal("should error")
+	}
+
+	// A supported flavor is fine
+	infra.FlavorsResult = append(infra.FlavorsResult, "test")
+	core = testCore(t, coreConfig)
+	if _, _, err := core.App(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, _, err := core.infra(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
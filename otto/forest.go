@@ -0,0 +1,171 @@
+package otto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// forest is the dependency graph of the main application merged with the
+// graphs of CoreConfig.ExtraRoots. Vertices are merged by Otto ID, so a
+// dependency shared by several roots is a single vertex that is compiled
+// once.
+type forest struct {
+	Graph *dag.AcyclicGraph
+
+	// Roots are the root vertices, the main application first.
+	Roots []dag.Vertex
+
+	// feeds are the IDs of the roots that depend on each vertex by the
+	// ID of the vertex, directly or not. The roots feed themselves. If
+	// this is nil, there is a single root that everything feeds.
+	feeds map[string]map[string]struct{}
+}
+
+// newForest merges the graphs of the main application and the extra
+// roots.
+func newForest(main *appfile.Compiled, extra []*appfile.Compiled) (*forest, error) {
+	var g dag.AcyclicGraph
+	byID := make(map[string]dag.Vertex)
+	vertex := func(raw dag.Vertex) dag.Vertex {
+		v := raw.(*appfile.CompiledGraphVertex)
+		if existing, ok := byID[v.File.ID]; ok {
+			return existing
+		}
+
+		byID[v.File.ID] = v
+		g.Add(v)
+		return v
+	}
+
+	result := &forest{Graph: &g}
+	for i, c := range append([]*appfile.Compiled{main}, extra...) {
+		if c == nil || c.Graph == nil {
+			return nil, fmt.Errorf("root %d has no compiled Appfile", i)
+		}
+
+		root, err := c.Graph.Root()
+		if err != nil {
+			return nil, fmt.Errorf("Error loading root %d: %s", i, err)
+		}
+
+		rootV := vertex(root)
+		for _, existing := range result.Roots {
+			if existing == rootV {
+				return nil, fmt.Errorf(
+					"application '%s' is given as a root more than once",
+					dag.VertexName(rootV))
+			}
+		}
+		result.Roots = append(result.Roots, rootV)
+
+		for _, raw := range c.Graph.Vertices() {
+			vertex(raw)
+		}
+		for _, e := range c.Graph.Edges() {
+			g.Connect(dag.BasicEdge(vertex(e.Source()), vertex(e.Target())))
+		}
+	}
+
+	// Validate requires a single root, which a forest with more than one
+	// doesn't have, so only look for cycles. Merging the graphs can
+	// introduce them if the roots depend on each other's dependencies in
+	// different directions.
+	for _, cycle := range g.Cycles() {
+		names := make([]string, len(cycle))
+		for i, v := range cycle {
+			names[i] = dag.VertexName(v)
+		}
+
+		return nil, fmt.Errorf(
+			"the dependencies of the roots have a cycle: %s",
+			strings.Join(names, ", "))
+	}
+
+	// Record which roots each vertex feeds
+	result.feeds = make(map[string]map[string]struct{})
+	for _, root := range result.Roots {
+		id := root.(*appfile.CompiledGraphVertex).File.ID
+		queue := []dag.Vertex{root}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+
+			currentID := current.(*appfile.CompiledGraphVertex).File.ID
+			set, ok := result.feeds[currentID]
+			if !ok {
+				set = make(map[string]struct{})
+				result.feeds[currentID] = set
+			}
+			if _, ok := set[id]; ok {
+				continue
+			}
+			set[id] = struct{}{}
+
+			queue = append(queue, dag.AsVertexList(g.DownEdges(current))...)
+		}
+	}
+
+	return result, nil
+}
+
+// IsRoot returns true if v is one of the roots.
+func (f *forest) IsRoot(v dag.Vertex) bool {
+	for _, root := range f.Roots {
+		if root == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Feeds returns true if the root with the ID rootID depends on the app
+// with the given ID, or is that app.
+func (f *forest) Feeds(id, rootID string) bool {
+	if f.feeds == nil {
+		return true
+	}
+
+	_, ok := f.feeds[id][rootID]
+	return ok
+}
+
+// Path returns the path of dependencies to the target from the first
+// root that depends on it.
+func (f *forest) Path(target dag.Vertex) []string {
+	for _, root := range f.Roots {
+		if path := graphPath(f.Graph, root, target); path != nil {
+			return path
+		}
+	}
+
+	return nil
+}
+
+// mainForest returns the forest with only the graph of the main
+// application.
+func (c *Core) mainForest() (*forest, error) {
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error loading app: %s", err)
+	}
+
+	return &forest{
+		Graph: c.appfileCompiled.Graph,
+		Roots: []dag.Vertex{root},
+	}, nil
+}
+
+// compileForest returns the forest that Compile walks, which has the
+// extra roots if there are any.
+func (c *Core) compileForest() (*forest, error) {
+	if c.forest != nil {
+		return c.forest, nil
+	}
+
+	return c.mainForest()
+}
@@ -0,0 +1,82 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreCompile_extraRoots(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("forest", "one", "Appfile"))
+	coreConfig.ExtraRoots = append(coreConfig.ExtraRoots,
+		TestAppfile(t, testPath("forest", "two", "Appfile")))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	var lock sync.Mutex
+	compiled := make(map[string]int)
+	fragments := make(map[string][]string)
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		name := ctx.Appfile.Application.Name
+		compiled[name]++
+		fragments[name] = ctx.DevDepFragments
+		if name != "shared" {
+			return &app.CompileResult{}, nil
+		}
+
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(ctx.Dir, "fragment")
+		if err := ioutil.WriteFile(path, []byte("shared"), 0644); err != nil {
+			return nil, err
+		}
+
+		return &app.CompileResult{DevDepFragmentPath: path}, nil
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The shared dependency is only compiled once
+	for _, name := range []string{"one", "two", "shared"} {
+		if compiled[name] != 1 {
+			t.Fatalf("bad: %#v", compiled)
+		}
+	}
+
+	// Both roots use the dependency
+	for _, name := range []string{"one", "two"} {
+		if len(fragments[name]) != 1 {
+			t.Fatalf("bad: %s %#v", name, fragments[name])
+		}
+	}
+
+	// The extra root is stored like a dependency
+	two := coreConfig.ExtraRoots[0].File.ID
+	md, err := core.compileMetadata()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := md.AppDeps[two]; !ok {
+		t.Fatalf("bad: %#v", md.AppDeps)
+	}
+}
+
+func TestNewCore_extraRootsDuplicate(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("forest", "one", "Appfile"))
+	coreConfig.ExtraRoots = append(coreConfig.ExtraRoots,
+		TestAppfile(t, testPath("forest", "one", "Appfile")))
+	if _, err := NewCore(coreConfig); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,225 @@
+package otto
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// compiledWithRoot returns a copy of the compiled Appfile whose graph is
+// only the subgraph of the given root and its dependencies. The root is
+// matched against the Otto ID of each Appfile first, then its
+// application name.
+func compiledWithRoot(c *appfile.Compiled, root string) (*appfile.Compiled, error) {
+	rootV, err := findVertex(c, root)
+	if err != nil {
+		return nil, fmt.Errorf("root %s", err)
+	}
+
+	// Add the root and everything it depends on to the new graph
+	var g dag.AcyclicGraph
+	g.Add(rootV)
+	seen := map[dag.Vertex]struct{}{rootV: struct{}{}}
+	queue := []dag.Vertex{rootV}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dep := range dag.AsVertexList(c.Graph.DownEdges(current)) {
+			if _, ok := seen[dep]; !ok {
+				seen[dep] = struct{}{}
+				g.Add(dep)
+				queue = append(queue, dep)
+			}
+
+			g.Connect(dag.BasicEdge(current, dep))
+		}
+	}
+
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf(
+			"dependencies of root application '%s' are invalid: %s", root, err)
+	}
+
+	return &appfile.Compiled{
+		File:  rootV.File,
+		Graph: &g,
+	}, nil
+}
+
+// findVertex finds the vertex in the dependency graph with the given Otto
+// ID or, failing that, the given application name. IDs are unique, names
+// might not be.
+func findVertex(c *appfile.Compiled, name string) (*appfile.CompiledGraphVertex, error) {
+	var matches []*appfile.CompiledGraphVertex
+	for _, raw := range c.Graph.Vertices() {
+		v := raw.(*appfile.CompiledGraphVertex)
+		if v.File.ID == name {
+			return v, nil
+		}
+
+		if v.File.Application != nil && v.File.Application.Name == name {
+			matches = append(matches, v)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf(
+			"application '%s' not found in the dependency graph", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf(
+			"application name '%s' is ambiguous, %d applications have\n"+
+				"that name. Please specify the Otto ID of the application instead.",
+			name, len(matches))
+	}
+}
+
+// graphPath returns the names of the vertices along the shortest path
+// of dependencies from the root to the target. If there are multiple
+// shortest paths, the one that sorts first by name is chosen so that
+// the result is stable. The result is nil if target isn't reachable.
+func graphPath(g *dag.AcyclicGraph, root, target dag.Vertex) []string {
+	// Breadth-first search from the root following dependency edges,
+	// recording the parent of each vertex that we see.
+	parents := map[dag.Vertex]dag.Vertex{root: nil}
+	queue := []dag.Vertex{root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == target {
+			break
+		}
+
+		deps := dag.AsVertexList(g.DownEdges(current))
+		sort.Sort(vertexByName(deps))
+		for _, dep := range deps {
+			if _, ok := parents[dep]; ok {
+				continue
+			}
+
+			parents[dep] = current
+			queue = append(queue, dep)
+		}
+	}
+
+	if _, ok := parents[target]; !ok {
+		return nil
+	}
+
+	// Walk back up from the target to build the path
+	var result []string
+	for v := target; v != nil; v = parents[v] {
+		result = append(result, dag.VertexName(v))
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result
+}
+
+// vertexByName implements sort.Interface to sort vertices by name.
+type vertexByName []dag.Vertex
+
+func (v vertexByName) Len() int           { return len(v) }
+func (v vertexByName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v vertexByName) Less(i, j int) bool { return dag.VertexName(v[i]) < dag.VertexName(v[j]) }
+
+// DepGraph is the dependency graph of the Appfile as plain structures so
+// that it can be analyzed without the internal graph types.
+type DepGraph struct {
+	// Root is the ID of the main application.
+	Root string
+
+	// Nodes are the applications in the graph, sorted by name and ID.
+	Nodes []*DepNode
+
+	// Edges are the dependencies between them, sorted by From then To.
+	Edges []*DepEdge
+}
+
+// DepNode is a single application in a DepGraph.
+type DepNode struct {
+	// ID is the Otto ID of the Appfile, which is unique in the graph.
+	ID    string
+	Name  string
+	Tuple app.Tuple
+}
+
+// DepEdge is a dependency in a DepGraph: the application with the ID
+// From depends on the application with the ID To.
+type DepEdge struct {
+	From string
+	To   string
+}
+
+// DependencyGraph returns the dependency graph of the Appfile. This has
+// no side effects.
+func (c *Core) DependencyGraph() (*DepGraph, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	g := c.appfileCompiled.Graph
+	root, err := g.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DepGraph{
+		Root: root.(*appfile.CompiledGraphVertex).File.ID,
+	}
+	for _, raw := range g.Vertices() {
+		f := raw.(*appfile.CompiledGraphVertex).File
+		tuple, err := c.appTuple(f)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Nodes = append(result.Nodes, &DepNode{
+			ID:    f.ID,
+			Name:  dag.VertexName(raw),
+			Tuple: tuple,
+		})
+	}
+	for _, e := range g.Edges() {
+		result.Edges = append(result.Edges, &DepEdge{
+			From: e.Source().(*appfile.CompiledGraphVertex).File.ID,
+			To:   e.Target().(*appfile.CompiledGraphVertex).File.ID,
+		})
+	}
+
+	sort.Sort(depNodeSlice(result.Nodes))
+	sort.Sort(depEdgeSlice(result.Edges))
+	return result, nil
+}
+
+// depNodeSlice implements sort.Interface to sort nodes by name and ID.
+type depNodeSlice []*DepNode
+
+func (s depNodeSlice) Len() int      { return len(s) }
+func (s depNodeSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s depNodeSlice) Less(i, j int) bool {
+	if s[i].Name != s[j].Name {
+		return s[i].Name < s[j].Name
+	}
+
+	return s[i].ID < s[j].ID
+}
+
+// depEdgeSlice implements sort.Interface to sort edges by From then To.
+type depEdgeSlice []*DepEdge
+
+func (s depEdgeSlice) Len() int      { return len(s) }
+func (s depEdgeSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s depEdgeSlice) Less(i, j int) bool {
+	if s[i].From != s[j].From {
+		return s[i].From < s[j].From
+	}
+
+	return s[i].To < s[j].To
+}
@@ -0,0 +1,66 @@
+package otto
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/dag"
+)
+
+func TestGraphPath(t *testing.T) {
+	// Diamond: root -> a -> c, root -> b -> c, c -> d
+	var g dag.AcyclicGraph
+	g.Add("root")
+	g.Add("a")
+	g.Add("b")
+	g.Add("c")
+	g.Add("d")
+	g.Connect(dag.BasicEdge("root", "b"))
+	g.Connect(dag.BasicEdge("root", "a"))
+	g.Connect(dag.BasicEdge("a", "c"))
+	g.Connect(dag.BasicEdge("b", "c"))
+	g.Connect(dag.BasicEdge("c", "d"))
+
+	cases := []struct {
+		Target   string
+		Expected []string
+	}{
+		{"root", []string{"root"}},
+		{"b", []string{"root", "b"}},
+		{"d", []string{"root", "a", "c", "d"}},
+	}
+
+	for _, tc := range cases {
+		actual := graphPath(&g, "root", tc.Target)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%s: bad: %#v", tc.Target, actual)
+		}
+	}
+}
+
+func TestCoreDependencyGraph(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	g, err := core.DependencyGraph()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(g.Nodes) != 2 {
+		t.Fatalf("bad: %#v", g.Nodes)
+	}
+	child, root := g.Nodes[0], g.Nodes[1]
+	if child.Name != "child" || root.Name != "root" || g.Root != root.ID {
+		t.Fatalf("bad: %#v", g)
+	}
+	if root.Tuple != TestAppTuple {
+		t.Fatalf("bad: %#v", root.Tuple)
+	}
+
+	expected := []*DepEdge{&DepEdge{From: root.ID, To: child.ID}}
+	if !reflect.DeepEqual(g.Edges, expected) {
+		t.Fatalf("bad: %#v", g.Edges)
+	}
+}
@@ -0,0 +1,78 @@
+package otto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hash/fnv"
+	"io"
+	"os"
+)
+
+// Hasher is the hash algorithm Otto uses to fingerprint data: the files
+// in the compilation manifest and its aggregate hash used to detect
+// drift, the keys of cached dev dependencies, and ContentCacheKey.
+//
+// These hashes are only compared against each other to find out whether
+// something changed, so the algorithm doesn't need to be cryptographic:
+// a faster non-cryptographic hash such as FNVHasher works as long as
+// accidental collisions are unlikely. However, anyone who can write to
+// the project or the compile directory can then craft files that
+// collide on purpose, which makes Otto reuse a stale cache or miss
+// drift. Use a cryptographic hash such as the default SHA256Hasher if
+// the inputs aren't trusted. Build signatures (see Signer) are never
+// affected by this setting.
+type Hasher interface {
+	// Name identifies the algorithm, such as "sha256". It is recorded
+	// with stored hashes so they aren't compared across algorithms.
+	Name() string
+
+	// New returns a new hash.
+	New() hash.Hash
+}
+
+// SHA256Hasher is the Hasher used if none is configured.
+type SHA256Hasher struct{}
+
+func (SHA256Hasher) Name() string   { return "sha256" }
+func (SHA256Hasher) New() hash.Hash { return sha256.New() }
+
+// FNVHasher is a Hasher using the non-cryptographic 64-bit FNV-1a hash.
+// It is faster than SHA256Hasher but doesn't resist deliberate
+// collisions; see Hasher.
+type FNVHasher struct{}
+
+func (FNVHasher) Name() string   { return "fnv64a" }
+func (FNVHasher) New() hash.Hash { return fnv.New64a() }
+
+// hashBytes returns the hex-encoded hash of data.
+func hashBytes(h Hasher, data []byte) string {
+	w := h.New()
+	w.Write(data)
+	return hex.EncodeToString(w.Sum(nil))
+}
+
+// hashFile returns the hex-encoded hash of the file at path.
+func hashFile(h Hasher, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w := h.New()
+	if _, err := io.Copy(w, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(w.Sum(nil)), nil
+}
+
+// truncateHash shortens a hex-encoded hash to at most n characters.
+func truncateHash(hash string, n int) string {
+	if len(hash) > n {
+		return hash[:n]
+	}
+
+	return hash
+}
@@ -0,0 +1,81 @@
+package otto
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreCompile_hasher(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Hasher = FNVHasher{}
+	coreConfig.CacheKey = ContentCacheKey{}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		f, err := os.Create(filepath.Join(ctx.Dir, "out"))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		_, err = f.WriteString("foo")
+		return nil, err
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The manifest records the algorithm and uses it for the files
+	m, err := core.Manifest()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if m.HashAlgorithm != "fnv64a" || len(m.App.Files) != 1 {
+		t.Fatalf("bad: %#v", m)
+	}
+	h := fnv.New64a()
+	h.Write([]byte("foo"))
+	if expected := hex.EncodeToString(h.Sum(nil)); m.App.Files[0].SHA256 != expected {
+		t.Fatalf("bad: %s", m.App.Files[0].SHA256)
+	}
+
+	// The compilation fingerprint uses the same algorithm
+	drift, err := core.DetectDrift()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if drift {
+		t.Fatal("should not drift")
+	}
+	if expected, _ := core.getCompileHash(); expected == m.Hash() {
+		t.Fatal("fingerprint should not be SHA-256")
+	}
+
+	// The content cache key inherits the configured algorithm
+	if k, ok := core.cacheKey.(ContentCacheKey); !ok || k.Hasher != (FNVHasher{}) {
+		t.Fatalf("bad: %#v", core.cacheKey)
+	}
+}
+
+func TestManifestHash_default(t *testing.T) {
+	m := &Manifest{App: &ManifestSection{Dir: "app"}}
+	if m.Hash() != m.HashWith(SHA256Hasher{}) {
+		t.Fatal("default should be SHA-256")
+	}
+	if m.Hash() == m.HashWith(FNVHasher{}) {
+		t.Fatal("algorithms should differ")
+	}
+	if manifestHashAlgorithm(SHA256Hasher{}) != "" {
+		t.Fatal("SHA-256 should be blank for older manifests")
+	}
+}
@@ -0,0 +1,66 @@
+package otto
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+)
+
+const (
+	// DefaultDevHealthTimeout is how long Dev waits for the development
+	// environment to become healthy if no timeout is configured.
+	DefaultDevHealthTimeout = 5 * time.Minute
+
+	// devHealthInterval is the time between health checks.
+	devHealthInterval = 2 * time.Second
+)
+
+// devHealthCheck waits for the development environment of the root app
+// to be ready if the app implements app.HealthChecker.
+func (c *Core) devHealthCheck(impl app.App, ctx *app.Context) error {
+	checker, ok := impl.(app.HealthChecker)
+	if !ok {
+		return nil
+	}
+
+	timeout := c.devHealthTimeout
+	if timeout == 0 {
+		timeout = DefaultDevHealthTimeout
+	}
+
+	c.ui.Header("Waiting for the development environment to be ready...")
+	err := waitHealthy(func() error {
+		return checker.HealthCheck(ctx)
+	}, timeout, devHealthInterval)
+	if err != nil {
+		return fmt.Errorf(
+			"The development environment didn't become ready within %s.\n"+
+				"It is still running, so you can inspect it or check again later.\n\n"+
+				"The last health check failed with: %s",
+			timeout, err)
+	}
+
+	c.ui.Message("[green]The development environment is ready!")
+	return nil
+}
+
+// waitHealthy calls check every interval until it returns nil. If it
+// still fails once timeout has passed, the last error is returned.
+func waitHealthy(check func() error, timeout, interval time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		log.Printf("[DEBUG] health check failed, retrying: %s", err)
+
+		select {
+		case <-deadline:
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
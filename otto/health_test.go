@@ -0,0 +1,72 @@
+package otto
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreDev_healthCheck(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.DevHealthTimeout = 10 * time.Millisecond
+	appMock := &testHealthChecker{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.Calls != 1 {
+		t.Fatalf("bad: %d", appMock.Calls)
+	}
+
+	// An environment that never becomes healthy times out
+	appMock.Err = errors.New("not ready")
+	if err := core.Dev(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestWaitHealthy(t *testing.T) {
+	calls := 0
+	check := func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("not ready")
+		}
+
+		return nil
+	}
+
+	if err := waitHealthy(check, time.Second, time.Millisecond); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if calls != 3 {
+		t.Fatalf("bad: %d", calls)
+	}
+
+	calls = -1000
+	if err := waitHealthy(check, 10*time.Millisecond, time.Millisecond); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+type testHealthChecker struct {
+	*app.Mock
+
+	Calls int
+	Err   error
+}
+
+func (c *testHealthChecker) HealthCheck(*app.Context) error {
+	c.Calls++
+	return c.Err
+}
@@ -0,0 +1,22 @@
+package otto
+
+import (
+	"github.com/hashicorp/otto/app"
+)
+
+// WalkHook is the interface that can be implemented to be notified as
+// each application in the dependency graph is processed by Compile
+// and Dev. This is a lightweight way to drive progress UIs.
+//
+// The graph is walked in parallel, so these methods may be called
+// concurrently from multiple goroutines. Implementations must do their
+// own locking if they have shared state.
+type WalkHook interface {
+	// OnVertexStart is called before the application with the given
+	// tuple and name is processed.
+	OnVertexStart(tuple app.Tuple, name string)
+
+	// OnVertexDone is called after the application is processed. err
+	// is the error from processing, if any.
+	OnVertexDone(tuple app.Tuple, name string, err error)
+}
@@ -0,0 +1,136 @@
+package otto
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+// ImportOpts are the options for Core.Import.
+type ImportOpts struct {
+	// InfraIDs are the identifiers of existing infrastructure resources
+	// to adopt. The keys and values depend on the infrastructure type.
+	InfraIDs map[string]string
+
+	// AppIDs are the identifiers of an existing deploy of the main
+	// application to adopt. The keys and values depend on the app type.
+	AppIDs map[string]string
+}
+
+// Import adopts pre-existing infrastructure and application resources
+// by recording them in the directory so that future Otto operations
+// treat them as managed.
+//
+// The infrastructure and app implementations must implement
+// infrastructure.Importer and app.Importer, respectively, for the
+// resources being imported.
+func (c *Core) Import(opts *ImportOpts) error {
+	if opts == nil || (len(opts.InfraIDs) == 0 && len(opts.AppIDs) == 0) {
+		return fmt.Errorf("no resources were given to import")
+	}
+
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
+	// Get the infra implementation for this
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return err
+	}
+	defer maybeClose(infra)
+
+	// Importing generally requires talking to the infrastructure
+	// provider to look up the resources, so we need creds.
+	if err := c.creds(infra, infraCtx); err != nil {
+		return err
+	}
+
+	if len(opts.InfraIDs) > 0 {
+		importer, ok := infra.(infrastructure.Importer)
+		if !ok {
+			return fmt.Errorf(
+				"infrastructure type '%s' doesn't support importing",
+				infraCtx.Infra.Type)
+		}
+
+		infraCtx.Ui.Header(fmt.Sprintf(
+			"Importing infrastructure: %s", infraCtx.Infra.Name))
+		record, err := importer.Import(infraCtx, opts.InfraIDs)
+		if err != nil {
+			return fmt.Errorf("Error importing infrastructure: %s", err)
+		}
+		if record == nil {
+			return fmt.Errorf(
+				"infrastructure type '%s' returned no record to import",
+				infraCtx.Infra.Type)
+		}
+
+		record.Lookup = directory.Lookup{Infra: infraCtx.Infra.Name}
+		if err := c.dir.PutInfra(record); err != nil {
+			return fmt.Errorf("Error storing imported infrastructure: %s", err)
+		}
+	}
+
+	if len(opts.AppIDs) > 0 {
+		root, err := c.appfileCompiled.Graph.Root()
+		if err != nil {
+			return err
+		}
+		rootCtx, err := c.appContext(root.(*appfile.CompiledGraphVertex).File)
+		if err != nil {
+			return fmt.Errorf(
+				"Error loading App: %s", err)
+		}
+		rootApp, err := c.app(rootCtx)
+		if err != nil {
+			return fmt.Errorf(
+				"Error loading App: %s", err)
+		}
+		defer maybeClose(rootApp)
+
+		// Update our shared data so we get the creds
+		rootCtx.Shared.InfraCreds = infraCtx.Shared.InfraCreds
+
+		importer, ok := rootApp.(app.Importer)
+		if !ok {
+			return fmt.Errorf(
+				"app implementation for tuple '%s' doesn't support importing",
+				rootCtx.Tuple)
+		}
+
+		rootCtx.Ui.Header(fmt.Sprintf(
+			"Importing deploy for '%s'", rootCtx.Application.Name))
+		record, err := importer.Import(rootCtx, opts.AppIDs)
+		if err != nil {
+			return fmt.Errorf("Error importing deploy: %s", err)
+		}
+		if record == nil {
+			return fmt.Errorf(
+				"app implementation for tuple '%s' returned no record to import",
+				rootCtx.Tuple)
+		}
+
+		record.Lookup = directory.Lookup{
+			AppID:       rootCtx.Appfile.ID,
+			Infra:       rootCtx.Tuple.Infra,
+			InfraFlavor: rootCtx.Tuple.InfraFlavor,
+		}
+		if err := c.dir.PutDeploy(record); err != nil {
+			return fmt.Errorf("Error storing imported deploy: %s", err)
+		}
+	}
+
+	return nil
+}
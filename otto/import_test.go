@@ -0,0 +1,164 @@
+package otto
+
+import (
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreImport(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	infraMock := &testInfraImporter{
+		Mock: new(infrastructure.Mock),
+		Record: &directory.Infra{
+			State:   directory.InfraStateReady,
+			Outputs: map[string]string{"vpc_id": "vpc-1"},
+		},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infraMock, nil
+	}
+	appMock := &testAppImporter{
+		Mock: TestApp(t, TestAppTuple, coreConfig),
+		Record: &directory.Deploy{
+			State:  directory.DeployStateSuccess,
+			Deploy: map[string]string{"instance": "i-1"},
+		},
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := core.Import(&ImportOpts{
+		InfraIDs: map[string]string{"vpc": "vpc-1"},
+		AppIDs:   map[string]string{"instance": "i-1"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infraMock.IDs["vpc"] != "vpc-1" {
+		t.Fatalf("bad: %#v", infraMock.IDs)
+	}
+	if appMock.IDs["instance"] != "i-1" {
+		t.Fatalf("bad: %#v", appMock.IDs)
+	}
+
+	// The records are stored with their lookups filled in
+	infraName := core.appfile.Project.Infrastructure
+	infra, err := coreConfig.Directory.GetInfra(&directory.Infra{
+		Lookup: directory.Lookup{Infra: infraName},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infra == nil || infra.Outputs["vpc_id"] != "vpc-1" {
+		t.Fatalf("bad: %#v", infra)
+	}
+	deploy, err := coreConfig.Directory.GetDeploy(&directory.Deploy{
+		Lookup: directory.Lookup{
+			AppID:       core.appfile.ID,
+			Infra:       TestAppTuple.Infra,
+			InfraFlavor: TestAppTuple.InfraFlavor,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if deploy == nil || deploy.Deploy["instance"] != "i-1" {
+		t.Fatalf("bad: %#v", deploy)
+	}
+}
+
+func TestCoreImport_notSupported(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	TestInfra(t, "test", coreConfig)
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := core.Import(&ImportOpts{InfraIDs: map[string]string{"vpc": "vpc-1"}})
+	if err == nil {
+		t.Fatal("should error")
+	}
+	err = core.Import(&ImportOpts{AppIDs: map[string]string{"instance": "i-1"}})
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreImport_args(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := &testAppImporter{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	// Something must be given to import
+	if err := core.Import(nil); err == nil {
+		t.Fatal("should error")
+	}
+	if err := core.Import(new(ImportOpts)); err == nil {
+		t.Fatal("should error")
+	}
+	if appMock.IDs != nil {
+		t.Fatalf("bad: %#v", appMock.IDs)
+	}
+
+	// An app that returns no record is an error
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	core = testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := core.Import(&ImportOpts{AppIDs: map[string]string{"instance": "i-1"}})
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if appMock.IDs == nil {
+		t.Fatal("Import should be called")
+	}
+}
+
+// testInfraImporter is an infrastructure that imports the record it is
+// given.
+type testInfraImporter struct {
+	*infrastructure.Mock
+
+	IDs    map[string]string
+	Record *directory.Infra
+}
+
+func (i *testInfraImporter) Import(
+	ctx *infrastructure.Context, ids map[string]string) (*directory.Infra, error) {
+	i.IDs = ids
+	return i.Record, nil
+}
+
+// testAppImporter is an app that imports the record it is given.
+type testAppImporter struct {
+	*app.Mock
+
+	IDs    map[string]string
+	Record *directory.Deploy
+}
+
+func (a *testAppImporter) Import(
+	ctx *app.Context, ids map[string]string) (*directory.Deploy, error) {
+	a.IDs = ids
+	return a.Record, nil
+}
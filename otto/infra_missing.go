@@ -0,0 +1,93 @@
+package otto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/otto/appfile"
+)
+
+// errInfraNotFound returns the error for an infrastructure name that
+// the Appfile f doesn't declare. It lists the infrastructures that are
+// declared and suggests the closest one, since this is usually a typo
+// in the project's infrastructure setting.
+func errInfraNotFound(f *appfile.File, name string) error {
+	names := make([]string, 0, len(f.Infrastructure))
+	for _, i := range f.Infrastructure {
+		names = append(names, i.Name)
+	}
+	sort.Strings(names)
+
+	msg := fmt.Sprintf("infrastructure not found in appfile: %s\n\n", name)
+	if len(names) == 0 {
+		return fmt.Errorf(
+			"%sThe Appfile doesn't declare any infrastructure. Add an\n"+
+				"infrastructure block named %q.", msg, name)
+	}
+
+	if name == f.Project.Infrastructure {
+		msg += fmt.Sprintf(
+			"The project is set to use the infrastructure %q, but ", name)
+	} else {
+		msg += fmt.Sprintf("There is no infrastructure %q; ", name)
+	}
+	msg += fmt.Sprintf(
+		"the Appfile only declares: %s", strings.Join(names, ", "))
+	if s := suggestName(name, names); s != "" {
+		msg += fmt.Sprintf("\n\nDid you mean %q?", s)
+	}
+
+	return fmt.Errorf("%s", msg)
+}
+
+// suggestName returns the name within names that is closest to name, if
+// it is close enough to likely be what was meant, or "" otherwise.
+func suggestName(name string, names []string) string {
+	var result string
+	best := len(name)/3 + 1
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return n
+		}
+
+		if d := editDistance(strings.ToLower(name), strings.ToLower(n)); d < best {
+			best = d
+			result = n
+		}
+	}
+
+	return result
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,59 @@
+package otto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/appfile"
+)
+
+func TestCoreApp_infraNotFound(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	f := coreConfig.Appfile.File
+	name := f.Infrastructure[0].Name
+	f.Infrastructure = append(f.Infrastructure, &appfile.Infrastructure{
+		Name:   "production",
+		Type:   "test",
+		Flavor: "test",
+	})
+	f.Project.Infrastructure = name + "x"
+	core := testCore(t, coreConfig)
+
+	_, _, err := core.App()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	msg := err.Error()
+	for _, expected := range []string{
+		`infrastructure "` + name + `x"`,
+		"only declares: " + name + ", production",
+		`Did you mean "` + name + `"?`,
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Fatalf("%q not in: %s", expected, msg)
+		}
+	}
+
+	if _, _, err := core.infra(); err == nil || !strings.Contains(err.Error(), "Did you mean") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestSuggestName(t *testing.T) {
+	names := []string{"aws", "local", "production"}
+	cases := map[string]string{
+		"aws":       "aws",
+		"AWS":       "aws",
+		"awss":      "aws",
+		"prodction": "production",
+		"gcp":       "",
+		"staging":   "",
+	}
+
+	for name, expected := range cases {
+		if actual := suggestName(name, names); actual != expected {
+			t.Fatalf("%s: bad: %q", name, actual)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package otto
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/directory"
+)
+
+// requiredInfraOutputs returns the infrastructure outputs that the app
+// implementation requires, if it implements app.InfraOutputRequirer.
+func requiredInfraOutputs(impl app.App) []string {
+	requirer, ok := impl.(app.InfraOutputRequirer)
+	if !ok {
+		return nil
+	}
+
+	return requirer.RequiredInfraOutputs()
+}
+
+// infraOutputs are the names of the outputs that the infrastructure
+// provides during a compilation: those its compilation declares and
+// those recorded in the directory when it was created. They are looked
+// up once, after the infrastructure is compiled. It is safe for
+// concurrent use.
+type infraOutputs struct {
+	once  sync.Once
+	names map[string]struct{}
+
+	// pending are the apps whose requirements are verified once the
+	// infrastructure is compiled, if it wasn't before they were.
+	lock    sync.Mutex
+	pending []pendingInfraOutputs
+}
+
+type pendingInfraOutputs struct {
+	App      string
+	Required []string
+}
+
+type pendingInfraOutputsSlice []pendingInfraOutputs
+
+func (s pendingInfraOutputsSlice) Len() int           { return len(s) }
+func (s pendingInfraOutputsSlice) Less(i, j int) bool { return s[i].App < s[j].App }
+func (s pendingInfraOutputsSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// get returns the names of the outputs.
+func (o *infraOutputs) get(c *Core, md *CompileMetadata, infraName string) map[string]struct{} {
+	o.once.Do(func() {
+		o.names = make(map[string]struct{})
+		if md.Infra != nil {
+			for _, name := range md.Infra.Outputs {
+				o.names[name] = struct{}{}
+			}
+		}
+
+		// Compilation doesn't require the directory, so the recorded
+		// outputs are only used if it works.
+		record, err := c.dir.GetInfra(&directory.Infra{
+			Lookup: directory.Lookup{Infra: infraName}})
+		if err != nil {
+			log.Printf("[WARN] error reading infrastructure outputs: %s", err)
+			return
+		}
+		if record != nil {
+			for name := range record.Outputs {
+				o.names[name] = struct{}{}
+			}
+		}
+	})
+
+	return o.names
+}
+
+// later records the requirements of an app to verify them later.
+func (o *infraOutputs) later(name string, required []string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.pending = append(o.pending, pendingInfraOutputs{App: name, Required: required})
+}
+
+// checkPending verifies the requirements recorded with later, in the
+// order of the app names so that the error is stable.
+func (o *infraOutputs) checkPending(c *Core, md *CompileMetadata, infraName string) error {
+	o.lock.Lock()
+	pending := o.pending
+	o.pending = nil
+	o.lock.Unlock()
+
+	sort.Sort(pendingInfraOutputsSlice(pending))
+	for _, p := range pending {
+		if err := checkInfraOutputs(
+			p.App, infraName, p.Required, o.get(c, md, infraName)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkInfraOutputs returns an error naming the required outputs that
+// aren't available.
+func checkInfraOutputs(
+	appName, infraName string, required []string, available map[string]struct{}) error {
+	var missing []string
+	for _, name := range required {
+		if _, ok := available[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return fmt.Errorf(
+		"Application '%s' requires outputs that the infrastructure '%s'\n"+
+			"doesn't provide: %s\n\n"+
+			"The app and infrastructure types don't work together, or the\n"+
+			"infrastructure must be updated first.",
+		appName, infraName, strings.Join(missing, ", "))
+}
@@ -0,0 +1,96 @@
+package otto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+func TestCoreCompile_infraOutputs(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := &testInfraOutputRequirer{
+		Mock:     TestApp(t, TestAppTuple, coreConfig),
+		Required: []string{"address", "vpc"},
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	infraMock := TestInfra(t, "test", coreConfig)
+	infraMock.CompileResult = &infrastructure.CompileResult{
+		Outputs: []string{"address"},
+	}
+	core := testCore(t, coreConfig)
+
+	// The app isn't compiled if an output is missing
+	err := core.Compile()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "doesn't provide: vpc") {
+		t.Fatalf("bad: %s", err)
+	}
+	if appMock.CompileCalled {
+		t.Fatal("compile shouldn't be called")
+	}
+
+	// Outputs recorded in the directory are available too
+	infra, err := core.ActiveInfra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = coreConfig.Directory.PutInfra(&directory.Infra{
+		Lookup:  directory.Lookup{Infra: infra.Name},
+		State:   directory.InfraStateReady,
+		Outputs: map[string]string{"vpc": "vpc-1"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.CompileCalled {
+		t.Fatal("compile should be called")
+	}
+}
+
+func TestCoreCompile_infraOutputsConcurrent(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.ConcurrentInfraCompile = true
+	appMock := &testInfraOutputRequirer{
+		Mock:     TestApp(t, TestAppTuple, coreConfig),
+		Required: []string{"address"},
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	infraMock := TestInfra(t, "test", coreConfig)
+	core := testCore(t, coreConfig)
+
+	// The apps are verified once the infrastructure is compiled
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+
+	infraMock.CompileResult = &infrastructure.CompileResult{
+		Outputs: []string{"address"},
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+type testInfraOutputRequirer struct {
+	*app.Mock
+
+	Required []string
+}
+
+func (r *testInfraOutputRequirer) RequiredInfraOutputs() []string {
+	return r.Required
+}
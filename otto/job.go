@@ -0,0 +1,192 @@
+package otto
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/helper/uuid"
+)
+
+// ErrCanceled is returned by operations that were canceled, such as a
+// build started with BuildAsync after Job.Cancel is called.
+var ErrCanceled = errors.New("The operation was canceled.")
+
+// BuildOpts are the options used for building with Core.BuildAsync.
+type BuildOpts struct {
+	// Timeout, if non-zero, cancels the build if it hasn't finished
+	// after this long.
+	Timeout time.Duration
+}
+
+// JobStatus is the status of a Job.
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Job is the handle of an operation running in the background, such as a
+// build started with Core.BuildAsync. All of its methods are safe to
+// call concurrently.
+type Job struct {
+	id         string
+	cancelCh   chan struct{}
+	cancelOnce sync.Once
+	doneCh     chan struct{}
+
+	// The fields below are set once the job is done, which is when
+	// doneCh is closed.
+	build *directory.Build
+	err   error
+
+	events eventLog
+	size   int
+}
+
+// BuildAsync starts Build in the background and returns immediately
+// with a handle to follow the build. Like Build, it returns ErrBusy if
+// another operation is running, and the Core stays busy until the job
+// is done.
+func (c *Core) BuildAsync(opts *BuildOpts) (*Job, error) {
+	if opts == nil {
+		opts = new(BuildOpts)
+	}
+
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		id:       uuid.GenerateUUID(),
+		cancelCh: make(chan struct{}),
+		doneCh:   make(chan struct{}),
+		size:     c.eventHistoryLimit(),
+	}
+	c.jobs.add(job)
+
+	// Forward the events of the Core while the job runs. The channel
+	// is closed once we unsubscribe below, after the build.
+	_, eventCh, unsubscribe := c.Subscribe()
+	forwardDone := make(chan struct{})
+	go func() {
+		defer close(forwardDone)
+		for e := range eventCh {
+			job.events.Lock()
+			job.events.send(e, job.size)
+			job.events.Unlock()
+		}
+	}()
+
+	var timer *time.Timer
+	if opts.Timeout > 0 {
+		timer = time.AfterFunc(opts.Timeout, job.Cancel)
+	}
+
+	go func() {
+		err := c.build(job.cancelCh)
+		if timer != nil {
+			timer.Stop()
+		}
+		c.audit("build", "", err)
+		var build *directory.Build
+		if err == nil {
+			build, err = c.builtRecord()
+		}
+		c.unlock()
+
+		unsubscribe()
+		<-forwardDone
+
+		job.events.Lock()
+		job.build = build
+		job.err = err
+		job.events.close()
+		job.events.Unlock()
+		close(job.doneCh)
+		c.jobs.remove(job)
+	}()
+
+	return job, nil
+}
+
+// builtRecord returns the record of the build of the main application.
+func (c *Core) builtRecord() (*directory.Build, error) {
+	infra := c.appfile.ActiveInfrastructure()
+	if infra == nil {
+		return nil, errInfraNotFound(c.appfile, c.appfile.Project.Infrastructure)
+	}
+
+	return c.dir.GetBuild(&directory.Build{Lookup: directory.Lookup{
+		AppID:       c.appfile.ID,
+		Infra:       infra.Type,
+		InfraFlavor: infra.Flavor,
+	}})
+}
+
+// ID returns the unique ID of the job.
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Status returns the current status of the job.
+func (j *Job) Status() JobStatus {
+	select {
+	case <-j.doneCh:
+	default:
+		return JobRunning
+	}
+
+	switch j.err {
+	case nil:
+		return JobSucceeded
+	case ErrCanceled:
+		return JobCanceled
+	default:
+		return JobFailed
+	}
+}
+
+// Subscribe returns the events of the job so far along with a channel
+// that receives the ones after them, like Core.Subscribe. The channel
+// is closed when the job is done, or when the returned function is
+// called to unsubscribe.
+func (j *Job) Subscribe() ([]CoreEvent, <-chan CoreEvent, func()) {
+	return j.events.subscribe()
+}
+
+// Cancel asks the job to stop. The job stops at the next opportunity,
+// so it may still finish normally; use Wait or Status to find out.
+func (j *Job) Cancel() {
+	j.cancelOnce.Do(func() {
+		close(j.cancelCh)
+	})
+}
+
+// Done returns a channel that is closed when the job is done.
+func (j *Job) Done() <-chan struct{} {
+	return j.doneCh
+}
+
+// Wait waits for the job to be done and returns its result: the record
+// of the build, or the error that the build failed with. ErrCanceled is
+// returned if the job was canceled.
+func (j *Job) Wait() (*directory.Build, error) {
+	<-j.doneCh
+	return j.build, j.err
+}
+
+// isCanceled returns true if cancelCh is closed. A nil channel is never
+// closed.
+func isCanceled(cancelCh <-chan struct{}) bool {
+	select {
+	case <-cancelCh:
+		return true
+	default:
+		return false
+	}
+}
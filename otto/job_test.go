@@ -0,0 +1,272 @@
+package otto
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreBuildAsync(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	appMock := &testBlockingBuild{
+		Mock:    TestApp(t, TestAppTuple, coreConfig),
+		Release: make(chan struct{}),
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	// The app records the build in the directory
+	tuple, err := core.RootTuple()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	appMock.Record = &directory.Build{
+		Lookup: directory.Lookup{
+			AppID:       core.appfile.ID,
+			Infra:       tuple.Infra,
+			InfraFlavor: tuple.InfraFlavor,
+		},
+		Artifact: map[string]string{"ami": "ami-123"},
+	}
+
+	job, err := core.BuildAsync(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	_, eventCh, _ := job.Subscribe()
+
+	// The Core is busy until the build is done
+	if e := <-eventCh; e.Type != CoreEventBuildStart {
+		t.Fatalf("bad: %#v", e)
+	}
+	if err := core.Build(); err != ErrBusy {
+		t.Fatalf("bad: %#v", err)
+	}
+	if s := job.Status(); s != JobRunning {
+		t.Fatalf("bad: %s", s)
+	}
+
+	close(appMock.Release)
+	build, err := job.Wait()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if build == nil || build.Artifact["ami"] != "ami-123" {
+		t.Fatalf("bad: %#v", build)
+	}
+	if s := job.Status(); s != JobSucceeded {
+		t.Fatalf("bad: %s", s)
+	}
+
+	// The events end with the build
+	var types []CoreEventType
+	for e := range eventCh {
+		types = append(types, e.Type)
+	}
+	if len(types) != 1 || types[0] != CoreEventBuildDone {
+		t.Fatalf("bad: %#v", types)
+	}
+	if history, _, _ := job.Subscribe(); len(history) != 2 {
+		t.Fatalf("bad: %#v", history)
+	}
+
+	// The Core can be used again
+	appMock.Release = nil
+	if err := core.Build(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCoreBuildAsync_cancel(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	appMock := &testBlockingBuild{
+		Mock:    TestApp(t, TestAppTuple, coreConfig),
+		Release: make(chan struct{}),
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	job, err := core.BuildAsync(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	job.Cancel()
+	job.Cancel()
+
+	<-job.Done()
+	if _, err := job.Wait(); err != ErrCanceled {
+		t.Fatalf("bad: %#v", err)
+	}
+	if s := job.Status(); s != JobCanceled {
+		t.Fatalf("bad: %s", s)
+	}
+}
+
+func TestCoreCancelAll(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	appMock := &testBlockingBuild{
+		Mock:    TestApp(t, TestAppTuple, coreConfig),
+		Release: make(chan struct{}),
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	// Nothing is running
+	summary, err := core.CancelAll(time.Second)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(summary.Canceled)+len(summary.Completed)+len(summary.Running) != 0 {
+		t.Fatalf("bad: %#v", summary)
+	}
+
+	job, err := core.BuildAsync(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	summary, err = core.CancelAll(time.Second)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(summary.Canceled, []string{job.ID()}) {
+		t.Fatalf("bad: %#v", summary)
+	}
+
+	// A job that doesn't stop in time is still running
+	appMock.IgnoreCancel = true
+	job, err = core.BuildAsync(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	summary, err = core.CancelAll(10 * time.Millisecond)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !reflect.DeepEqual(summary.Running, []string{job.ID()}) {
+		t.Fatalf("bad: %#v", summary)
+	}
+
+	close(appMock.Release)
+	if _, err := job.Wait(); err != ErrCanceled {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestCoreCancelAll_multipleStuck(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	appMock := &testBlockingBuild{
+		Mock:         TestApp(t, TestAppTuple, coreConfig),
+		Release:      make(chan struct{}),
+		IgnoreCancel: true,
+		Started:      make(chan struct{}),
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	defer close(appMock.Release)
+
+	// A copy with its own local directory can build at the same time
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	other := core.WithDirs("", filepath.Join(td, "local"), filepath.Join(td, "compile"))
+
+	var ids []string
+	for _, c := range []*Core{core, other} {
+		job, err := c.BuildAsync(nil)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		ids = append(ids, job.ID())
+	}
+	sort.Strings(ids)
+	for range ids {
+		select {
+		case <-appMock.Started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("builds should start")
+		}
+	}
+
+	// Both are reported as running once the deadline passes
+	doneCh := make(chan struct{})
+	var summary *CancelSummary
+	go func() {
+		defer close(doneCh)
+		summary, err = core.CancelAll(10 * time.Millisecond)
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CancelAll should return at the deadline")
+	}
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !reflect.DeepEqual(summary.Running, ids) {
+		t.Fatalf("bad: %#v", summary)
+	}
+}
+
+// testBlockingBuild is an app whose build waits until Release is closed
+// or the build is canceled, and then stores Record. If IgnoreCancel is
+// true, it only waits for Release. If Started is set, a value is sent on
+// it when a build starts.
+type testBlockingBuild struct {
+	*app.Mock
+
+	Release      chan struct{}
+	Record       *directory.Build
+	IgnoreCancel bool
+	Started      chan struct{}
+}
+
+func (a *testBlockingBuild) Build(ctx *app.Context) error {
+	if a.Started != nil {
+		a.Started <- struct{}{}
+	}
+
+	cancelCh := ctx.Cancel
+	if a.IgnoreCancel {
+		cancelCh = nil
+	}
+	if a.Release != nil {
+		select {
+		case <-a.Release:
+		case <-cancelCh:
+			return errors.New("interrupted")
+		}
+	}
+
+	if a.Record == nil {
+		return nil
+	}
+
+	return ctx.Directory.PutBuild(a.Record)
+}
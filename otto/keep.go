@@ -0,0 +1,188 @@
+package otto
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// keptFiles are the files of the compile directory that match
+// CoreConfig.CompileKeep, stashed in the data directory while the
+// compile directory is cleared. Paths are slash-separated and relative
+// to the compile directory.
+type keptFiles struct {
+	dir   string
+	paths []string
+}
+
+// compileKeepMatch returns true if the file at rel, a slash-separated
+// path relative to the compile directory, matches one of the patterns.
+// Patterns with a slash are matched against the whole path, the others
+// against the file name so that ".gitkeep" matches in every directory.
+func compileKeepMatch(patterns []string, rel string) (bool, error) {
+	for _, p := range patterns {
+		target := rel
+		if !strings.Contains(p, "/") {
+			target = path.Base(rel)
+		}
+
+		ok, err := path.Match(p, target)
+		if err != nil {
+			return false, fmt.Errorf("invalid CompileKeep pattern %q: %s", p, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// stashKeptFiles copies the files matching CoreConfig.CompileKeep out of
+// the compile directory before it is cleared. The result is nil if there
+// is nothing to keep. With PreserveCompileDir, files that the compilation
+// doesn't produce are kept anyway, so this does nothing.
+func (c *Core) stashKeptFiles() (*keptFiles, error) {
+	if len(c.compileKeep) == 0 || c.preserveCompile {
+		return nil, nil
+	}
+
+	var paths []string
+	err := filepath.Walk(c.compileDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == c.compileDir {
+				return nil
+			}
+
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.compileDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		ok, err := compileKeepMatch(c.compileKeep, rel)
+		if ok {
+			paths = append(paths, rel)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	// The stash is kept in the data directory rather than a temporary
+	// directory, since it holds the only copy of the kept files if they
+	// can't be restored, and temporary directories are swept.
+	root := c.keepDir()
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(root, c.appfile.ID+"-")
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range paths {
+		src := filepath.Join(c.compileDir, filepath.FromSlash(rel))
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := copyKeptFile(src, dst); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("Error keeping %s: %s", rel, err)
+		}
+	}
+
+	return &keptFiles{dir: dir, paths: paths}, nil
+}
+
+// keepDir is the directory that kept files are stashed in.
+func (c *Core) keepDir() string {
+	return filepath.Join(c.dataDir, "keep")
+}
+
+// restore copies the kept files back into the compile directory and adds
+// their hashes to kept so that they aren't part of the manifest. It is an
+// error if the compilation produced a file with the same path; the kept
+// copy is left in the stash directory then, so it isn't lost.
+func (k *keptFiles) restore(c *Core, kept map[string]string) error {
+	var conflicts []string
+	for _, rel := range k.paths {
+		dst := filepath.Join(c.compileDir, filepath.FromSlash(rel))
+		if _, err := os.Lstat(dst); err == nil {
+			conflicts = append(conflicts, rel)
+			continue
+		}
+
+		if err := copyKeptFile(filepath.Join(k.dir, filepath.FromSlash(rel)), dst); err != nil {
+			return fmt.Errorf("Error restoring %s: %s", rel, err)
+		}
+		hash, err := hashFile(c.hasher, dst)
+		if err != nil {
+			return err
+		}
+		kept[rel] = hash
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf(
+			"The compilation produced files that match CompileKeep, so they\n"+
+				"can't be kept: %s\n\n"+
+				"The kept copies are in %s. Remove the pattern that\n"+
+				"matches them, or the step that produces them.",
+			strings.Join(conflicts, ", "), k.dir)
+	}
+
+	return os.RemoveAll(k.dir)
+}
+
+// restoreOnError puts the kept files back after a failed compilation.
+// Files the compilation produced win, since it will be run again, and
+// errors only result in warnings in the log since there is already an
+// error to report.
+func (k *keptFiles) restoreOnError(c *Core) {
+	kept := make(map[string]string)
+	if err := k.restore(c, kept); err != nil {
+		log.Printf("[WARN] error restoring kept files after failed compile: %s", err)
+	}
+}
+
+// copyKeptFile copies the file at src to dst with the same mode,
+// creating the parent directories of dst.
+func copyKeptFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,134 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCompileKeepMatch(t *testing.T) {
+	patterns := []string{".gitkeep", "app/*.conf"}
+	cases := []struct {
+		Path     string
+		Expected bool
+	}{
+		{".gitkeep", true},
+		{"app/dev/.gitkeep", true},
+		{"app/override.conf", true},
+		{"app/dev/override.conf", false},
+		{"override.conf", false},
+	}
+
+	for _, tc := range cases {
+		actual, err := compileKeepMatch(patterns, tc.Path)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		if actual != tc.Expected {
+			t.Fatalf("%s: bad: %v", tc.Path, actual)
+		}
+	}
+
+	if _, err := compileKeepMatch([]string{"["}, "a"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCoreCompile_keep(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.CompileKeep = []string{".gitkeep", "app/override.conf"}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	keep := []string{
+		filepath.Join(coreConfig.CompileDir, ".gitkeep"),
+		filepath.Join(coreConfig.CompileDir, "app", "override.conf"),
+	}
+	for _, path := range keep {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte("hand"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	other := filepath.Join(coreConfig.CompileDir, "other")
+	if err := ioutil.WriteFile(other, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range keep {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(data) != "hand" {
+			t.Fatalf("bad: %q", data)
+		}
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Fatalf("other files should be deleted: %v", err)
+	}
+
+	// Kept files aren't part of the output
+	m, err := core.Manifest()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, p := range m.Paths() {
+		if p == ".gitkeep" || p == "app/override.conf" {
+			t.Fatalf("bad: %#v", m.Paths())
+		}
+	}
+
+	// The stash is cleaned up
+	stash := filepath.Join(coreConfig.DataDir, "keep")
+	infos, err := ioutil.ReadDir(stash)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(infos) != 0 {
+		t.Fatalf("bad: %d", len(infos))
+	}
+
+	// Producing a kept file is an error
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		return nil, ioutil.WriteFile(
+			filepath.Join(ctx.Dir, "override.conf"), []byte("compiled"), 0644)
+	}
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+
+	// The kept copy is left in the data directory, where temporary
+	// directories aren't swept
+	infos, err = ioutil.ReadDir(stash)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("bad: %d", len(infos))
+	}
+	data, err := ioutil.ReadFile(
+		filepath.Join(stash, infos[0].Name(), "app", "override.conf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "hand" {
+		t.Fatalf("bad: %q", data)
+	}
+}
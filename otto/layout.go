@@ -0,0 +1,40 @@
+package otto
+
+import (
+	"fmt"
+)
+
+// DirLayout determines the names of the directories within the compile
+// directory that the compiled output of the application, its
+// dependencies, and the infrastructure are written to.
+//
+// The names must be unique among each other and must be a single path
+// element.
+type DirLayout interface {
+	// AppDir is the directory for the main application.
+	AppDir() string
+
+	// DepDir is the directory for the dependency with the given
+	// unique Otto ID.
+	DepDir(id string) string
+
+	// InfraDir is the directory for the infrastructure with the given
+	// name.
+	InfraDir(name string) string
+}
+
+// DefaultDirLayout is the DirLayout used if none is configured. It can
+// be embedded to only customize some of the directories.
+type DefaultDirLayout struct{}
+
+func (DefaultDirLayout) AppDir() string {
+	return "app"
+}
+
+func (DefaultDirLayout) DepDir(id string) string {
+	return fmt.Sprintf("dep-%s", id)
+}
+
+func (DefaultDirLayout) InfraDir(name string) string {
+	return fmt.Sprintf("infra-%s", name)
+}
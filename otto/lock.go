@@ -0,0 +1,232 @@
+package otto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/otto/helper/uuid"
+)
+
+// lockFilename is the name of the lock file within the LocalDir.
+const lockFilename = "otto.lock"
+
+// lockWriteGrace is how long a lock file may be empty or invalid before
+// it is considered stale. The holder creates the file and then writes
+// it, so it is empty for a moment, and stays empty if the holder crashed
+// in between.
+const lockWriteGrace = 10 * time.Second
+
+// ErrBusy is returned by an operation on a Core that is called while
+// another operation is already running on the same Core.
+var ErrBusy = errors.New(
+	"Another operation is already running for this Appfile. Please wait\n" +
+		"for it to complete and try again.")
+
+// LockInfo is the information recorded in the lock file while an
+// operation is running, so that it can be determined who holds it.
+type LockInfo struct {
+	// ID is the unique ID of this lock. It is required to force unlock.
+	ID string `json:"id"`
+
+	// Pid and Hostname identify the process that holds the lock.
+	Pid      int    `json:"pid"`
+	Hostname string `json:"hostname"`
+
+	// Created is the time the lock was acquired.
+	Created time.Time `json:"created"`
+
+	// Invalid is true if the lock file is empty or can't be read, such
+	// as when the holder crashed before writing it. Only Created is set
+	// then, to the modification time of the file. Such a lock is broken
+	// automatically once it is older than a few seconds, and ForceUnlock
+	// removes it with any ID.
+	Invalid bool `json:"-"`
+}
+
+// LockError is the error returned when an operation can't start because
+// another process holds the lock for this Appfile.
+type LockError struct {
+	Info *LockInfo
+}
+
+func (e *LockError) Error() string {
+	if e.Info.Invalid {
+		return fmt.Sprintf(
+			"Another Otto process is acquiring the lock for this Appfile,\n"+
+				"which it created at %s. Please wait for it and try again.",
+			e.Info.Created)
+	}
+
+	return fmt.Sprintf(
+		"Another Otto process is running an operation for this Appfile.\n\n"+
+			"  ID:       %s\n"+
+			"  Process:  %d on %s\n"+
+			"  Created:  %s\n\n"+
+			"If you're sure no other Otto process is running, for example\n"+
+			"because it crashed, the lock can be removed with ForceUnlock\n"+
+			"using the ID above.",
+		e.Info.ID, e.Info.Pid, e.Info.Hostname, e.Info.Created)
+}
+
+// LockInfo returns the information about the lock held for this
+// Appfile. If no lock is held, nil is returned. If the lock file can't
+// be read, the result is marked Invalid rather than being an error.
+func (c *Core) LockInfo() (*LockInfo, error) {
+	path := c.lockPath()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result LockInfo
+	if err := json.Unmarshal(data, &result); err != nil || result.ID == "" {
+		fi, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		return &LockInfo{Created: fi.ModTime(), Invalid: true}, nil
+	}
+
+	return &result, nil
+}
+
+// ForceUnlock removes the lock with the given ID. This should only be
+// used to recover from a process that crashed while holding the lock,
+// since removing a lock that is in use allows concurrent operations.
+func (c *Core) ForceUnlock(id string) error {
+	info, err := c.LockInfo()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return fmt.Errorf("no lock is held for this Appfile")
+	}
+	if !info.Invalid && info.ID != id {
+		return fmt.Errorf(
+			"lock ID %q doesn't match the held lock: %s", id, info.ID)
+	}
+
+	return os.Remove(c.lockPath())
+}
+
+// lock marks the start of an exclusive operation on the Core such as
+// Compile or Build. If another operation is already running, ErrBusy is
+// returned, or a *LockError if it is running in another process. Every
+// successful call must be followed by a call to unlock.
+func (c *Core) lock() error {
+	if !atomic.CompareAndSwapInt32(&c.busy, 0, 1) {
+		return ErrBusy
+	}
+
+	if err := c.lockFile(); err != nil {
+		atomic.StoreInt32(&c.busy, 0)
+		return err
+	}
+
+	// Operations read the Appfile throughout, so it must not be
+	// swapped out by Reload while they run.
+	c.stateLock.RLock()
+	c.resetWarnings()
+	if err := c.checkStale(); err != nil {
+		c.unlock()
+		return err
+	}
+
+	return nil
+}
+
+// unlock marks the end of an operation started with lock.
+func (c *Core) unlock() {
+	c.stateLock.RUnlock()
+
+	// Only remove the lock file if it is still ours. It may have been
+	// removed with ForceUnlock and then acquired by another process.
+	if info, err := c.LockInfo(); err == nil && info != nil && info.ID == c.lockID {
+		if err := os.Remove(c.lockPath()); err != nil {
+			log.Printf("[WARN] error removing lock file: %s", err)
+		}
+	}
+
+	c.lockID = ""
+	atomic.StoreInt32(&c.busy, 0)
+}
+
+// lockFile creates the lock file, failing if it already exists.
+func (c *Core) lockFile() error {
+	path := c.lockPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	info := &LockInfo{
+		ID:       uuid.GenerateUUID(),
+		Pid:      os.Getpid(),
+		Hostname: hostname,
+		Created:  c.clock.Now().UTC(),
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if !os.IsExist(err) {
+			return fmt.Errorf("Error creating lock file: %s", err)
+		}
+
+		existing, err := c.LockInfo()
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			// Removed in the meantime
+			return c.lockFile()
+		}
+		// Created is the modification time of the file then, so it is
+		// compared with the wall clock rather than the configured one.
+		if existing.Invalid && time.Since(existing.Created) > lockWriteGrace {
+			log.Printf("[WARN] removing stale invalid lock file: %s", path)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("Error removing stale lock file: %s", err)
+			}
+
+			return c.lockFile()
+		}
+
+		return &LockError{Info: existing}
+	}
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("Error writing lock file: %s", err)
+	}
+
+	c.lockID = info.ID
+	return nil
+}
+
+func (c *Core) lockPath() string {
+	return filepath.Join(c.localDir, lockFilename)
+}
@@ -0,0 +1,149 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoreLock_otherProcess(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	// Another core for the same Appfile, as if in another process
+	other := testCore(t, coreConfig)
+
+	info, err := core.LockInfo()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if info != nil {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	if err := other.lock(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err = core.Compile()
+	lockErr, ok := err.(*LockError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if lockErr.Info.ID != other.lockID || lockErr.Info.Pid != os.Getpid() {
+		t.Fatalf("bad: %#v", lockErr.Info)
+	}
+
+	info, err = core.LockInfo()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if info == nil || info.ID != other.lockID {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	// Force unlock requires the correct ID
+	if err := core.ForceUnlock("nope"); err == nil {
+		t.Fatal("should error")
+	}
+	if err := core.ForceUnlock(info.ID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The original holder unlocking shouldn't affect anything
+	other.unlock()
+	if info, err := core.LockInfo(); err != nil || info != nil {
+		t.Fatalf("bad: %#v %s", info, err)
+	}
+}
+
+func TestCoreLock_invalid(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	// An empty lock file, as left by a crash before it was written
+	path := core.lockPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	info, err := core.LockInfo()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if info == nil || !info.Invalid || info.ID != "" {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	// While it may still be written, it is held
+	err = core.Compile()
+	if lockErr, ok := err.(*LockError); !ok || !lockErr.Info.Invalid {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Once it is stale, it is broken
+	old := time.Now().Add(-2 * lockWriteGrace)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if info, err := core.LockInfo(); err != nil || info != nil {
+		t.Fatalf("bad: %#v %s", info, err)
+	}
+
+	// Garbage can be force unlocked without an ID
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.ForceUnlock(""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCoreLock_invalidClock(t *testing.T) {
+	// A configured clock far from the wall clock doesn't change when an
+	// invalid lock file is stale, since its time comes from the file.
+	for _, offset := range []time.Duration{-24 * time.Hour, 24 * time.Hour} {
+		coreConfig := TestCoreConfig(t)
+		coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+		coreConfig.Clock = &TestClock{T: time.Now().Add(offset)}
+		core := testCore(t, coreConfig)
+
+		path := core.lockPath()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		err := core.Compile()
+		if lockErr, ok := err.(*LockError); !ok || !lockErr.Info.Invalid {
+			t.Fatalf("%s: bad: %#v", offset, err)
+		}
+
+		old := time.Now().Add(-2 * lockWriteGrace)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := core.Compile(); err != nil {
+			t.Fatalf("%s: err: %s", offset, err)
+		}
+	}
+}
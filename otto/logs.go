@@ -0,0 +1,181 @@
+package otto
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// LogsAll streams the logs of the dev environments of the main
+// application and all of its dev dependencies, interleaved line by line
+// with the name of the app each line is from as a prefix. Apps that
+// don't implement app.LogStreamer are skipped with a message.
+//
+// The stream must be closed, which closes the streams of all the apps.
+// This doesn't modify the state, so other operations can run while it is
+// open.
+func (c *Core) LogsAll(opts *app.LogsOpts) (io.ReadCloser, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if opts == nil {
+		opts = new(app.LogsOpts)
+	}
+	if err := c.materialize(); err != nil {
+		return nil, err
+	}
+
+	// The apps must stay open while their logs are streamed, so this
+	// doesn't use walk, which closes them as soon as it is done with them.
+	vertices := c.appfileCompiled.Graph.Vertices()
+	sort.Sort(vertexByName(vertices))
+
+	result := &logsMerger{}
+	for _, raw := range vertices {
+		v := raw.(*appfile.CompiledGraphVertex)
+		if !v.InScope(appfile.DependencyScopeDev) {
+			continue
+		}
+
+		name := dag.VertexName(raw)
+		appCtx, err := c.appContext(v.File)
+		if err != nil {
+			result.Close()
+			return nil, fmt.Errorf(
+				"Error loading Appfile for '%s': %s", name, err)
+		}
+		impl, err := c.app(appCtx)
+		if err != nil {
+			result.Close()
+			return nil, fmt.Errorf(
+				"Error loading App implementation for '%s': %s", name, err)
+		}
+
+		streamer, ok := impl.(app.LogStreamer)
+		if !ok {
+			maybeClose(impl)
+			c.ui.Message(fmt.Sprintf(
+				"[yellow]Skipping logs of '%s', its app type doesn't support logs.",
+				name))
+			continue
+		}
+
+		stream, err := streamer.Logs(appCtx, opts)
+		if err != nil {
+			maybeClose(impl)
+			result.Close()
+			return nil, fmt.Errorf(
+				"Error opening logs of '%s': %s", name, err)
+		}
+
+		result.add(name, stream, impl)
+	}
+
+	if len(result.sources) == 0 {
+		return nil, fmt.Errorf(
+			"None of the applications support streaming their logs.")
+	}
+
+	result.start()
+	return result, nil
+}
+
+// logsMerger merges the log streams of several apps into one, prefixing
+// every line with the name of its app. Lines are never interleaved with
+// each other.
+type logsMerger struct {
+	sources []*logsSource
+	width   int
+
+	pr        *io.PipeReader
+	pw        *io.PipeWriter
+	writeLock sync.Mutex
+	closeOnce sync.Once
+}
+
+type logsSource struct {
+	name   string
+	stream io.ReadCloser
+	impl   app.App
+}
+
+func (m *logsMerger) add(name string, stream io.ReadCloser, impl app.App) {
+	m.sources = append(m.sources, &logsSource{
+		name: name, stream: stream, impl: impl})
+	if len(name) > m.width {
+		m.width = len(name)
+	}
+}
+
+// start copies the lines of every source into the merged stream until
+// they all end.
+func (m *logsMerger) start() {
+	m.pr, m.pw = io.Pipe()
+
+	var wg sync.WaitGroup
+	wg.Add(len(m.sources))
+	for _, s := range m.sources {
+		go func(s *logsSource) {
+			defer wg.Done()
+
+			prefix := fmt.Sprintf("%-*s | ", m.width, s.name)
+			r := bufio.NewReader(s.stream)
+			for {
+				line, err := r.ReadString('\n')
+				if line != "" {
+					if line[len(line)-1] != '\n' {
+						line += "\n"
+					}
+
+					m.writeLock.Lock()
+					_, werr := io.WriteString(m.pw, prefix+line)
+					m.writeLock.Unlock()
+					if werr != nil {
+						// The merged stream was closed
+						return
+					}
+				}
+				if err != nil {
+					if err != io.EOF {
+						log.Printf("[WARN] error reading logs of '%s': %s", s.name, err)
+					}
+
+					return
+				}
+			}
+		}(s)
+	}
+
+	go func() {
+		wg.Wait()
+		m.pw.Close()
+	}()
+}
+
+func (m *logsMerger) Read(p []byte) (int, error) {
+	return m.pr.Read(p)
+}
+
+// Close closes the streams and apps of every source.
+func (m *logsMerger) Close() error {
+	m.closeOnce.Do(func() {
+		if m.pr != nil {
+			m.pr.Close()
+		}
+		for _, s := range m.sources {
+			if err := s.stream.Close(); err != nil {
+				log.Printf("[WARN] error closing logs of '%s': %s", s.name, err)
+			}
+			maybeClose(s.impl)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package otto
+
+import (
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreLogsAll(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := &testLogStreamer{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	r, err := core.LogsAll(&app.LogsOpts{Lines: 10})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"child | first line of child",
+		"child | second line of child",
+		"root  | first line of root",
+		"root  | second line of root",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("bad: %q", lines)
+	}
+	if appMock.Opts == nil || appMock.Opts.Lines != 10 {
+		t.Fatalf("bad: %#v", appMock.Opts)
+	}
+}
+
+func TestCoreLogsAll_unsupported(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	if _, err := core.LogsAll(nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+type testLogStreamer struct {
+	*app.Mock
+
+	Opts *app.LogsOpts
+}
+
+func (s *testLogStreamer) Logs(ctx *app.Context, opts *app.LogsOpts) (io.ReadCloser, error) {
+	s.Opts = opts
+
+	name := ctx.Appfile.Application.Name
+	return ioutil.NopCloser(strings.NewReader(
+		"first line of " + name + "\nsecond line of " + name)), nil
+}
@@ -0,0 +1,341 @@
+package otto
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/otto/appfile"
+)
+
+// manifestFilename is the name of the manifest file within the compile
+// directory.
+const manifestFilename = "manifest.json"
+
+// Manifest is a listing of every file produced by a compilation, along
+// with their sizes and hashes. This can be used to verify the integrity
+// of compiled output or to detect drift.
+type Manifest struct {
+	// HashAlgorithm is the name of the Hasher used for the hashes of
+	// the files. It is blank for SHA-256, so older manifests without it
+	// are read correctly.
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+
+	// App is the output of the main application.
+	App *ManifestSection `json:"app"`
+
+	// Deps are the outputs of the dependencies, keyed by their
+	// unique Otto ID.
+	Deps map[string]*ManifestSection `json:"deps"`
+
+	// Infra is the output of the infrastructure.
+	Infra *ManifestSection `json:"infra"`
+
+	// Foundations are the outputs of the top-level foundations, keyed
+	// by foundation name.
+	Foundations map[string]*ManifestSection `json:"foundations"`
+
+	// Other contains any files in the compile directory that don't
+	// belong to any of the above.
+	Other *ManifestSection `json:"other"`
+}
+
+// ManifestSection is the listing of files in a single output directory
+// of the compilation.
+type ManifestSection struct {
+	// Dir is the directory of this section, relative to the compile
+	// directory.
+	Dir string `json:"dir"`
+
+	// Files are the files within this section, sorted by path.
+	Files []*ManifestFile `json:"files"`
+}
+
+// ManifestFile is a single file within the manifest.
+type ManifestFile struct {
+	// Path is the slash-separated path of the file relative to the
+	// directory of the section it is in.
+	Path string `json:"path"`
+
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// SHA256 is the hex-encoded hash of the file contents. Despite the
+	// name, it uses the algorithm in Manifest.HashAlgorithm.
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest returns the manifest of the last successful compilation. If
+// there has been no compilation, nil is returned.
+func (c *Core) Manifest() (*Manifest, error) {
+	f, err := os.Open(filepath.Join(c.compileDir, manifestFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	var result Manifest
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Hash returns an aggregate SHA-256 hash of the manifest, which is the
+// same for any two compilations that produced the same files.
+func (m *Manifest) Hash() string {
+	return m.HashWith(SHA256Hasher{})
+}
+
+// HashWith is like Hash, but uses the given algorithm.
+func (m *Manifest) HashWith(h Hasher) string {
+	// Maps are encoded sorted by key, so this is deterministic.
+	data, err := json.Marshal(m)
+	if err != nil {
+		// The manifest is only strings and numbers, so this shouldn't
+		// ever fail
+		panic(err)
+	}
+
+	return hashBytes(h, data)
+}
+
+// saveManifest builds the manifest of the compile directory and writes
+// it. kept are the files that weren't produced by the compilation, keyed
+// by path with their hashes; see buildManifest.
+func (c *Core) saveManifest(kept map[string]string) (*Manifest, error) {
+	if err := os.MkdirAll(c.compileDir, 0755); err != nil {
+		return nil, err
+	}
+
+	m, err := buildManifest(c.hasher, c.compileDir, c.manifestDirs(), kept)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(filepath.Join(c.compileDir, manifestFilename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// manifestDir is the section of the manifest that a top-level directory
+// of the compile directory belongs in.
+type manifestDir struct {
+	Kind string // "app", "dep", or "infra"
+	Key  string // Otto ID of the dep
+}
+
+// manifestDirs returns the top-level directories of the compile
+// directory for the application, its dependencies, and the
+// infrastructure, according to the configured DirLayout.
+func (c *Core) manifestDirs() map[string]manifestDir {
+	result := map[string]manifestDir{
+		c.dirLayout.AppDir(): manifestDir{Kind: "app"},
+		c.dirLayout.InfraDir(c.appfile.Project.Infrastructure): manifestDir{
+			Kind: "infra"},
+	}
+
+	// The extra roots are written like dependencies
+	root, _ := c.appfileCompiled.Graph.Root()
+	g := c.appfileCompiled.Graph
+	if c.forest != nil {
+		g = c.forest.Graph
+	}
+	for _, raw := range g.Vertices() {
+		if raw == root {
+			continue
+		}
+
+		id := raw.(*appfile.CompiledGraphVertex).File.ID
+		result[c.dirLayout.DepDir(id)] = manifestDir{Kind: "dep", Key: id}
+	}
+
+	return result
+}
+
+// manifestHashAlgorithm returns the Manifest.HashAlgorithm for h.
+func manifestHashAlgorithm(h Hasher) string {
+	if name := h.Name(); name != (SHA256Hasher{}).Name() {
+		return name
+	}
+
+	return ""
+}
+
+// buildManifest builds a manifest of the compiled output within dir.
+// dirs are the known top-level directories; see manifestDirs. Files in
+// kept, keyed by slash-separated path, that still have the same hash
+// weren't produced by the compilation and are left out.
+func buildManifest(
+	h Hasher,
+	dir string,
+	dirs map[string]manifestDir,
+	kept map[string]string) (*Manifest, error) {
+	result := &Manifest{
+		Deps:        make(map[string]*ManifestSection),
+		Foundations: make(map[string]*ManifestSection),
+	}
+	result.HashAlgorithm = manifestHashAlgorithm(h)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// We only record regular files.
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Our own metadata isn't part of the output.
+		if rel == manifestFilename || rel == "metadata.json" || rel == compileTreeFilename {
+			return nil
+		}
+
+		// Determine the section this belongs in based on the top-level
+		// directory name.
+		var top string
+		if idx := strings.Index(rel, "/"); idx != -1 {
+			top = rel[:idx]
+		}
+		section := result.section(dirs, top)
+
+		hash, err := hashFile(h, path)
+		if err != nil {
+			return err
+		}
+		if h, ok := kept[rel]; ok && h == hash {
+			return nil
+		}
+
+		filePath := rel
+		if section.Dir != "" {
+			filePath = strings.TrimPrefix(rel, section.Dir+"/")
+		}
+
+		section.Files = append(section.Files, &ManifestFile{
+			Path:   filePath,
+			Size:   info.Size(),
+			SHA256: hash,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// section returns the section for the given top-level directory of the
+// compile directory, creating it if it doesn't exist yet.
+func (m *Manifest) section(dirs map[string]manifestDir, top string) *ManifestSection {
+	var key string
+	var sections map[string]*ManifestSection
+	d, ok := dirs[top]
+	switch {
+	case top == "":
+		// Files directly in the compile directory
+	case ok && d.Kind == "app":
+		if m.App == nil {
+			m.App = &ManifestSection{Dir: top}
+		}
+
+		return m.App
+	case ok && d.Kind == "infra":
+		if m.Infra == nil {
+			m.Infra = &ManifestSection{Dir: top}
+		}
+
+		return m.Infra
+	case ok && d.Kind == "dep":
+		key = d.Key
+		sections = m.Deps
+	case strings.HasPrefix(top, "foundation-"):
+		key = strings.TrimPrefix(top, "foundation-")
+		sections = m.Foundations
+	}
+
+	if sections == nil {
+		if m.Other == nil {
+			m.Other = &ManifestSection{}
+		}
+
+		return m.Other
+	}
+
+	s, ok := sections[key]
+	if !ok {
+		s = &ManifestSection{Dir: top}
+		sections[key] = s
+	}
+
+	return s
+}
+
+// Paths returns the slash-separated paths of all the files in the
+// manifest, relative to the compile directory.
+func (m *Manifest) Paths() []string {
+	var result []string
+	m.each(func(path string, f *ManifestFile) {
+		result = append(result, path)
+	})
+
+	sort.Strings(result)
+	return result
+}
+
+// each calls f for every file in the manifest with its slash-separated
+// path relative to the compile directory.
+func (m *Manifest) each(f func(string, *ManifestFile)) {
+	sections := []*ManifestSection{m.App, m.Infra, m.Other}
+	for _, s := range m.Deps {
+		sections = append(sections, s)
+	}
+	for _, s := range m.Foundations {
+		sections = append(sections, s)
+	}
+
+	for _, s := range sections {
+		if s == nil {
+			continue
+		}
+
+		for _, file := range s.Files {
+			path := file.Path
+			if s.Dir != "" {
+				path = s.Dir + "/" + path
+			}
+
+			f(path, file)
+		}
+	}
+}
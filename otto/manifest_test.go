@@ -0,0 +1,80 @@
+package otto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// testLargeFileSize is the size of the files used to verify that output
+// is streamed rather than read into memory.
+const testLargeFileSize = 32 * 1024 * 1024
+
+func TestHashFile_large(t *testing.T) {
+	path := testLargeFile(t, 'a', testLargeFileSize)
+	defer os.Remove(path)
+
+	var actual string
+	allocated := testAllocated(t, func() {
+		var err error
+		actual, err = hashFile(SHA256Hasher{}, path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	})
+	if allocated > testLargeFileSize/8 {
+		t.Fatalf("allocated too much: %d", allocated)
+	}
+
+	h := sha256.New()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+// testLargeFile writes a file of size bytes of c, rounded down to a
+// whole MB, followed by a newline, and returns its path.
+func testLargeFile(t *testing.T, c byte, size int) string {
+	f, err := ioutil.TempFile("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	chunk := make([]byte, 1024*1024)
+	for i := range chunk {
+		chunk[i] = c
+	}
+	for i := 0; i < size/len(chunk); i++ {
+		if _, err := f.Write(chunk); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if _, err := f.Write([]byte("\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return f.Name()
+}
+
+// testAllocated returns the number of bytes allocated while calling f.
+func testAllocated(t *testing.T, f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
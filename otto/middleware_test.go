@@ -0,0 +1,63 @@
+package otto
+
+import (
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreCompile_factoryMiddleware(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+
+	var tuples []app.Tuple
+	var wrapped *testTimedApp
+	coreConfig.FactoryMiddleware = func(tuple app.Tuple, f app.Factory) app.Factory {
+		tuples = append(tuples, tuple)
+		return func() (app.App, error) {
+			impl, err := f()
+			if err != nil {
+				return nil, err
+			}
+
+			wrapped = &testTimedApp{App: impl}
+			return wrapped, nil
+		}
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(tuples) == 0 || tuples[0] != TestAppTuple {
+		t.Fatalf("bad: %#v", tuples)
+	}
+	if wrapped == nil || wrapped.CompileCalls != 1 {
+		t.Fatalf("bad: %#v", wrapped)
+	}
+	if !appMock.CompileCalled {
+		t.Fatal("compile should be called")
+	}
+
+	// A middleware that drops the factory is an error
+	coreConfig.FactoryMiddleware = func(app.Tuple, app.Factory) app.Factory {
+		return nil
+	}
+	core = testCore(t, coreConfig)
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// testTimedApp is an app that intercepts the calls to the wrapped one.
+type testTimedApp struct {
+	app.App
+
+	CompileCalls int
+}
+
+func (a *testTimedApp) Compile(ctx *app.Context) (*app.CompileResult, error) {
+	a.CompileCalls++
+	return a.App.Compile(ctx)
+}
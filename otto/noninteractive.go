@@ -0,0 +1,32 @@
+package otto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/otto/ui"
+)
+
+// requireInputs verifies that u can answer all the given inputs without
+// asking if the Core is non-interactive, so that an operation fails
+// before it starts asking rather than at the first missing value. The
+// error lists every missing value and how to give it.
+func (c *Core) requireInputs(u ui.Ui, opts ...*ui.InputOpts) error {
+	if !c.nonInteractive {
+		return nil
+	}
+
+	var missing []string
+	for _, o := range opts {
+		if !ui.HasAnswer(u, o) {
+			missing = append(missing, "  - "+ui.InputSources(o))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"cannot prompt in non-interactive mode; provide these values:\n\n%s",
+		strings.Join(missing, "\n"))
+}
@@ -0,0 +1,83 @@
+package otto
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreCreds_nonInteractive(t *testing.T) {
+	defer os.Setenv("OTTO_CREDS_PASSWORD", os.Getenv("OTTO_CREDS_PASSWORD"))
+	os.Unsetenv("OTTO_CREDS_PASSWORD")
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	coreConfig.NonInteractive = true
+
+	infra := &testCredsDescriber{
+		Mock: new(infrastructure.Mock),
+		Fields: []infrastructure.CredField{
+			{Key: "region", Required: true},
+			{Key: "token", Secret: true, Required: true},
+		},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return infra, nil
+	}
+	core := testCore(t, coreConfig)
+
+	_, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Every missing value is listed at once, without asking
+	err = core.creds(infra, infraCtx)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	for _, v := range []string{"creds_region", "creds_token", "OTTO_CREDS_PASSWORD"} {
+		if !strings.Contains(err.Error(), v) {
+			t.Fatalf("missing %s: %s", v, err)
+		}
+	}
+	if coreConfig.Ui.(*ui.Mock).InputCalled {
+		t.Fatal("the Ui should not be asked")
+	}
+
+	// With answers for all of them, it succeeds
+	coreConfig.InputAnswers = map[string]string{
+		"creds_region":   "us-east-1",
+		"creds_token":    "secret",
+		"creds_password": "password",
+	}
+	core = testCore(t, coreConfig)
+	_, infraCtx, err = core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.creds(infra, infraCtx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if infraCtx.InfraCreds["region"] != "us-east-1" {
+		t.Fatalf("bad: %#v", infraCtx.InfraCreds)
+	}
+	if coreConfig.Ui.(*ui.Mock).InputCalled {
+		t.Fatal("the Ui should not be asked")
+	}
+}
+
+func TestCoreNonInteractive_nullUi(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = new(ui.Null)
+	core := testCore(t, coreConfig)
+
+	if !core.nonInteractive {
+		t.Fatal("should be non-interactive")
+	}
+}
@@ -0,0 +1,121 @@
+package otto
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+// Outputs returns the outputs of the infrastructure and the deploy of the
+// main application. The result is keyed by the name of the
+// infrastructure and the name of the application.
+//
+// Implementations that implement infrastructure.Outputter or
+// app.Outputter are queried directly. Otherwise, the outputs recorded
+// in the directory are used.
+func (c *Core) Outputs() (map[string]map[string]string, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if err := c.checkDirectory(true); err != nil {
+		return nil, err
+	}
+	if err := c.materialize(); err != nil {
+		return nil, err
+	}
+
+	infra, infraCtx, err := c.infra()
+	if err != nil {
+		return nil, err
+	}
+	defer maybeClose(infra)
+
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return nil, err
+	}
+	rootCtx, err := c.appContext(root.(*appfile.CompiledGraphVertex).File)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error loading App: %s", err)
+	}
+	rootApp, err := c.app(rootCtx)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error loading App: %s", err)
+	}
+	defer maybeClose(rootApp)
+
+	infraOutputter, infraLive := infra.(infrastructure.Outputter)
+	appOutputter, appLive := rootApp.(app.Outputter)
+
+	// Querying outputs directly requires talking to the infrastructure
+	// provider, so we need creds.
+	if infraLive || appLive {
+		if err := c.creds(infra, infraCtx); err != nil {
+			return nil, err
+		}
+
+		rootCtx.Shared.InfraCreds = infraCtx.Shared.InfraCreds
+	}
+
+	result := make(map[string]map[string]string)
+
+	// Infrastructure outputs
+	var infraOutputs map[string]string
+	if infraLive {
+		infraOutputs, err = infraOutputter.Outputs(infraCtx)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Error reading infrastructure outputs: %s", err)
+		}
+	} else {
+		record, err := c.dir.GetInfra(&directory.Infra{
+			Lookup: directory.Lookup{Infra: infraCtx.Infra.Name}})
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Error reading infrastructure outputs: %s", err)
+		}
+		if record != nil {
+			infraOutputs = record.Outputs
+		}
+	}
+	if infraOutputs != nil {
+		result[infraCtx.Infra.Name] = infraOutputs
+	}
+
+	// App outputs
+	var appOutputs map[string]string
+	if appLive {
+		appOutputs, err = appOutputter.Outputs(rootCtx)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Error reading outputs for '%s': %s",
+				rootCtx.Application.Name, err)
+		}
+	} else {
+		record, err := c.dir.GetDeploy(&directory.Deploy{
+			Lookup: directory.Lookup{
+				AppID:       rootCtx.Appfile.ID,
+				Infra:       rootCtx.Tuple.Infra,
+				InfraFlavor: rootCtx.Tuple.InfraFlavor,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Error reading outputs for '%s': %s",
+				rootCtx.Application.Name, err)
+		}
+		if record != nil {
+			appOutputs = record.Deploy
+		}
+	}
+	if appOutputs != nil {
+		result[rootCtx.Application.Name] = appOutputs
+	}
+
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package otto
+
+import (
+	"math"
+	"unicode"
+)
+
+// credsWeakAttempts is the number of times a password that is too weak
+// can be entered before giving up.
+const credsWeakAttempts = 3
+
+// passwordEntropy estimates the entropy of a password in bits from its
+// length and the classes of characters it uses. This is a rough upper
+// bound meant to catch obviously weak passwords, not a guarantee of
+// strength.
+func passwordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	length := 0
+	for _, r := range password {
+		length++
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	return float64(length) * math.Log2(float64(pool))
+}
+
+// passwordStrength returns a human-friendly description of the strength
+// of a password with the given entropy.
+func passwordStrength(bits float64) string {
+	switch {
+	case bits < 28:
+		return "very weak"
+	case bits < 36:
+		return "weak"
+	case bits < 60:
+		return "reasonable"
+	case bits < 128:
+		return "strong"
+	default:
+		return "very strong"
+	}
+}
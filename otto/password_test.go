@@ -0,0 +1,66 @@
+package otto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestPasswordEntropy(t *testing.T) {
+	cases := []struct {
+		Input string
+		Min   float64
+		Max   float64
+	}{
+		{"", 0, 0},
+		{"password", 37, 38},
+		{"Tr0ub4dor&3", 72, 73},
+	}
+
+	for _, tc := range cases {
+		actual := passwordEntropy(tc.Input)
+		if actual < tc.Min || actual > tc.Max {
+			t.Fatalf("%s: bad: %f", tc.Input, actual)
+		}
+	}
+}
+
+func TestCoreCreds_passwordStrength(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.CredsMinPasswordEntropy = 50
+	coreConfig.Ui = &testInputUi{
+		Mock:   new(ui.Mock),
+		Inputs: []string{"password", "correct horse battery staple"},
+	}
+	core := testCore(t, coreConfig)
+
+	infra, infraCtx, err := core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.creds(infra, infraCtx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The weak password was rejected
+	path := filepath.Join(
+		coreConfig.DataDir, "cache", "creds", infraCtx.Infra.Name)
+	if _, err := cryptRead(path, "correct horse battery staple"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A weak password from a source that never changes fails
+	os.RemoveAll(path)
+	coreConfig.Ui = &ui.Mock{InputResult: "password"}
+	core = testCore(t, coreConfig)
+	infra, infraCtx, err = core.infra()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.creds(infra, infraCtx); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,116 @@
+package otto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CompilePhase is a phase of Compile.
+type CompilePhase string
+
+const (
+	// CompilePhaseInfra compiles the infrastructure.
+	CompilePhaseInfra CompilePhase = "infra"
+
+	// CompilePhaseApps compiles the foundations and every app in the
+	// dependency graph.
+	CompilePhaseApps CompilePhase = "apps"
+)
+
+// compilePhases are all the phases, in the order they run in when they
+// don't depend on each other.
+var compilePhases = []CompilePhase{CompilePhaseInfra, CompilePhaseApps}
+
+// DefaultCompilePhaseDeps is the default CoreConfig.CompilePhaseDeps:
+// the apps are compiled after the infrastructure so that they can use
+// its compiled output.
+var DefaultCompilePhaseDeps = map[CompilePhase][]CompilePhase{
+	CompilePhaseApps: {CompilePhaseInfra},
+}
+
+// AppsBeforeInfraCompilePhaseDeps is a CoreConfig.CompilePhaseDeps that
+// compiles the infrastructure after the apps, so that it can use the
+// fragments they produce. See app.CompileResult.InfraFragmentPath.
+var AppsBeforeInfraCompilePhaseDeps = map[CompilePhase][]CompilePhase{
+	CompilePhaseInfra: {CompilePhaseApps},
+}
+
+// compilePhaseOrder returns the order to run the phases in so that every
+// phase runs after the phases it depends on. deps is keyed by phase, and
+// the values are the phases it depends on.
+func compilePhaseOrder(deps map[CompilePhase][]CompilePhase) ([]CompilePhase, error) {
+	known := make(map[CompilePhase]bool, len(compilePhases))
+	for _, p := range compilePhases {
+		known[p] = true
+	}
+	for p, ds := range deps {
+		for _, d := range append([]CompilePhase{p}, ds...) {
+			if !known[d] {
+				return nil, fmt.Errorf("unknown compile phase: %q", d)
+			}
+		}
+	}
+
+	result := make([]CompilePhase, 0, len(compilePhases))
+	done := make(map[CompilePhase]bool, len(compilePhases))
+	var visit func(p CompilePhase, path []CompilePhase) error
+	visit = func(p CompilePhase, path []CompilePhase) error {
+		if done[p] {
+			return nil
+		}
+		for i, v := range path {
+			if v == p {
+				cycle := make([]string, 0, len(path)-i+1)
+				for _, v := range append(path[i:], p) {
+					cycle = append(cycle, string(v))
+				}
+
+				return fmt.Errorf(
+					"compile phases depend on each other in a cycle: %s",
+					strings.Join(cycle, " -> "))
+			}
+		}
+
+		path = append(path, p)
+		for _, d := range deps[p] {
+			if err := visit(d, path); err != nil {
+				return err
+			}
+		}
+
+		done[p] = true
+		result = append(result, p)
+		return nil
+	}
+	for _, p := range compilePhases {
+		if err := visit(p, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// infraFragments returns the infrastructure fragments of the compiled
+// apps for infrastructure.Context.AppFragments. The dependencies were
+// compiled in parallel, so they are sorted by ID for a stable order.
+func infraFragments(md *CompileMetadata) []string {
+	ids := make([]string, 0, len(md.AppDeps))
+	for id := range md.AppDeps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var result []string
+	for _, id := range ids {
+		if path := md.AppDeps[id].InfraFragmentPath; path != "" {
+			result = append(result, path)
+		}
+	}
+	if md.App != nil && md.App.InfraFragmentPath != "" {
+		result = append(result, md.App.InfraFragmentPath)
+	}
+
+	return result
+}
@@ -0,0 +1,111 @@
+package otto
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCompilePhaseOrder(t *testing.T) {
+	cases := []struct {
+		Deps     map[CompilePhase][]CompilePhase
+		Expected []CompilePhase
+		Err      bool
+	}{
+		{
+			DefaultCompilePhaseDeps,
+			[]CompilePhase{CompilePhaseInfra, CompilePhaseApps},
+			false,
+		},
+
+		{
+			AppsBeforeInfraCompilePhaseDeps,
+			[]CompilePhase{CompilePhaseApps, CompilePhaseInfra},
+			false,
+		},
+
+		{
+			map[CompilePhase][]CompilePhase{},
+			[]CompilePhase{CompilePhaseInfra, CompilePhaseApps},
+			false,
+		},
+
+		{
+			map[CompilePhase][]CompilePhase{
+				CompilePhaseApps:  {CompilePhaseInfra},
+				CompilePhaseInfra: {CompilePhaseApps},
+			},
+			nil,
+			true,
+		},
+
+		{
+			map[CompilePhase][]CompilePhase{
+				CompilePhaseApps: {"nope"},
+			},
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		actual, err := compilePhaseOrder(tc.Deps)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%d: bad: %#v", i, actual)
+		}
+	}
+}
+
+func TestCoreCompile_appsBeforeInfra(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.CompilePhaseDeps = AppsBeforeInfraCompilePhaseDeps
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	infra := TestInfra(t, "test", coreConfig)
+	core := testCore(t, coreConfig)
+
+	var path string
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if infra.CompileCalled {
+			t.Fatal("infra should be compiled after the apps")
+		}
+
+		path = filepath.Join(ctx.Dir, "infra-fragment")
+		return &app.CompileResult{InfraFragmentPath: path}, nil
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !infra.CompileCalled {
+		t.Fatal("infra should be compiled")
+	}
+
+	actual := infra.CompileContext.AppFragments
+	if !reflect.DeepEqual(actual, []string{path}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestNewCore_compilePhaseDeps(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.CompilePhaseDeps = AppsBeforeInfraCompilePhaseDeps
+	coreConfig.ConcurrentInfraCompile = true
+	if _, err := NewCore(coreConfig); err == nil {
+		t.Fatal("should error")
+	}
+
+	coreConfig.ConcurrentInfraCompile = false
+	coreConfig.CompilePhaseDeps = map[CompilePhase][]CompilePhase{
+		CompilePhaseApps:  {CompilePhaseInfra},
+		CompilePhaseInfra: {CompilePhaseApps},
+	}
+	if _, err := NewCore(coreConfig); err == nil {
+		t.Fatal("should error")
+	}
+}
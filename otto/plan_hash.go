@@ -0,0 +1,138 @@
+package otto
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// planHashVersion is the version of the inputs of ComputePlanHash. It is
+// part of the hash so that changing what is hashed changes every hash.
+const planHashVersion = 2
+
+// planHashInput is what ComputePlanHash hashes. encoding/json sorts map
+// keys, including those of the customizations, and the apps are sorted
+// by ID, so its encoding is deterministic.
+type planHashInput struct {
+	Version int               `json:"version"`
+	Infra   *planHashInfra    `json:"infra"`
+	Apps    []*planHashApp    `json:"apps"`
+	Vars    map[string]string `json:"vars"`
+
+	// CompileScope is omitted when it is blank, so that the hashes of
+	// the Cores that compile everything stay the same.
+	CompileScope string `json:"compile_scope,omitempty"`
+}
+
+type planHashInfra struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Flavor string `json:"flavor"`
+}
+
+type planHashApp struct {
+	ID      string           `json:"id"`
+	Root    bool             `json:"root"`
+	Tuple   []string         `json:"tuple"`
+	Scope   string           `json:"scope"`
+	Appfile *appfileContents `json:"appfile"`
+	Deps    []string         `json:"deps"`
+}
+
+type planHashAppSlice []*planHashApp
+
+func (s planHashAppSlice) Len() int           { return len(s) }
+func (s planHashAppSlice) Less(i, j int) bool { return s[i].ID < s[j].ID }
+func (s planHashAppSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ComputePlanHash returns a hex-encoded hash of everything that the
+// planned operations depend on: the Appfiles of the application and its
+// dependencies as they're compiled, the dependency graph, the tuple of
+// every application, the active infrastructure and flavor, and the
+// variables. CI systems can store it between runs and skip a stage if
+// it didn't change. The contents of the project besides the Appfiles
+// aren't included; combine this with a hash of the sources if the build
+// output depends on them.
+//
+// The hash is the same on every machine: it doesn't depend on where the
+// project or its dependencies are, or on modification times. It uses the
+// configured Hasher. This has no side effects.
+func (c *Core) ComputePlanHash() (string, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	config := c.appfile.ActiveInfrastructure()
+	if config == nil {
+		return "", errInfraNotFound(c.appfile, c.appfile.Project.Infrastructure)
+	}
+	vars, err := c.vars()
+	if err != nil {
+		return "", err
+	}
+
+	input := &planHashInput{
+		Version: planHashVersion,
+		Infra: &planHashInfra{
+			Name:   config.Name,
+			Type:   config.Type,
+			Flavor: config.Flavor,
+		},
+		Vars:         vars,
+		CompileScope: c.compileScope,
+	}
+	if input.Vars == nil {
+		input.Vars = make(map[string]string)
+	}
+
+	// The extra roots are planned like dependencies
+	g := c.appfileCompiled.Graph
+	if c.forest != nil {
+		g = c.forest.Graph
+	}
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return "", err
+	}
+	for _, raw := range g.Vertices() {
+		v := raw.(*appfile.CompiledGraphVertex)
+		app, err := c.planHashApp(g, v)
+		if err != nil {
+			return "", err
+		}
+		app.Root = raw == root
+
+		input.Apps = append(input.Apps, app)
+	}
+	sort.Sort(planHashAppSlice(input.Apps))
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+
+	return hashBytes(c.hasher, data), nil
+}
+
+// planHashApp returns the inputs of ComputePlanHash for one application.
+func (c *Core) planHashApp(g *dag.AcyclicGraph, v *appfile.CompiledGraphVertex) (*planHashApp, error) {
+	tuple, err := c.appTuple(v.File)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make([]string, 0)
+	for _, raw := range dag.AsVertexList(g.DownEdges(v)) {
+		deps = append(deps, raw.(*appfile.CompiledGraphVertex).File.ID)
+	}
+	sort.Strings(deps)
+
+	return &planHashApp{
+		ID:      v.File.ID,
+		Tuple:   []string{tuple.App, tuple.Infra, tuple.InfraFlavor},
+		Scope:   v.Scope,
+		Appfile: contentsOf(v.File),
+		Deps:    deps,
+	}, nil
+}
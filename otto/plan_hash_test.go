@@ -0,0 +1,77 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoreComputePlanHash(t *testing.T) {
+	planHash := func(path string, vars map[string]string) string {
+		coreConfig := TestCoreConfig(t)
+		coreConfig.Appfile = TestAppfile(t, path)
+		coreConfig.VarSources = []VarSource{VarsFunc(func() (map[string]string, error) {
+			return vars, nil
+		})}
+		core := testCore(t, coreConfig)
+
+		result, err := core.ComputePlanHash()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		return result
+	}
+
+	// The same project in another place has the same hash. The default
+	// application name is the name of the directory, so that is kept.
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	for _, rel := range []string{"Appfile", ".ottoid", "child/Appfile", "child/.ottoid"} {
+		err := copyKeptFile(
+			filepath.Join(testPath("deps"), filepath.FromSlash(rel)),
+			filepath.Join(td, "deps", filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	vars := map[string]string{"region": "us-east-1"}
+	expected := planHash(testPath("deps", "Appfile"), vars)
+	if actual := planHash(filepath.Join(td, "deps", "Appfile"), vars); actual != expected {
+		t.Fatalf("bad: %s != %s", actual, expected)
+	}
+
+	// The variables and the Appfiles are inputs
+	if actual := planHash(testPath("deps", "Appfile"), nil); actual == expected {
+		t.Fatal("hash should change with the variables")
+	}
+	if actual := planHash(testPath("basic", "Appfile"), vars); actual == expected {
+		t.Fatal("hash should change with the Appfile")
+	}
+}
+
+func TestCoreComputePlanHash_customization(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("customization-multi", "Appfile"))
+	core := testCore(t, coreConfig)
+
+	// Customizations are maps, so make sure their order doesn't matter
+	expected, err := core.ComputePlanHash()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for i := 0; i < 20; i++ {
+		actual, err := core.ComputePlanHash()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if actual != expected {
+			t.Fatalf("bad: %s != %s", actual, expected)
+		}
+	}
+}
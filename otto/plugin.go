@@ -0,0 +1,95 @@
+package otto
+
+import (
+	"log"
+	"os/exec"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/plugin"
+)
+
+// PluginGlob is the glob pattern used to find plugins within
+// CoreConfig.PluginDir.
+const PluginGlob = "otto-plugin-*"
+
+// discoverPlugins finds all the plugins in the given directory and
+// adds the app implementations they provide to the apps map. It returns
+// the clients of all the plugins it started, in the order of paths.
+//
+// Plugins that fail to start or handshake are logged and skipped. Tuples
+// that already have an implementation in the map are not overridden.
+// Plugins that end up providing no tuples are killed.
+//
+// The plugin protocol currently only exposes app implementations, so
+// infrastructures can't yet be discovered this way.
+func discoverPlugins(
+	dir string, apps map[app.Tuple]app.Factory) ([]*plugin.Client, error) {
+	log.Printf("[DEBUG] Looking for plugins in: %s", dir)
+	paths, err := plugin.Discover(PluginGlob, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make([]*plugin.Client, 0, len(paths))
+	for _, path := range paths {
+		log.Printf("[DEBUG] Loading plugin: %s", path)
+		client := plugin.NewClient(&plugin.ClientConfig{
+			Cmd:     exec.Command(path),
+			Managed: true,
+		})
+		clients = append(clients, client)
+
+		rpcClient, err := client.Client()
+		if err != nil {
+			log.Printf("[WARN] Error starting plugin %s, skipping: %s", path, err)
+			client.Kill()
+			continue
+		}
+
+		appImpl, err := rpcClient.App()
+		if err != nil {
+			log.Printf("[WARN] Error loading app from plugin %s, skipping: %s", path, err)
+			client.Kill()
+			continue
+		}
+		meta, err := appImpl.Meta()
+		maybeClose(appImpl)
+		if err != nil {
+			log.Printf("[WARN] Error loading plugin metadata %s, skipping: %s", path, err)
+			client.Kill()
+			continue
+		}
+
+		factory := func() (app.App, error) {
+			return rpcClient.App()
+		}
+		registered := 0
+		for _, tuple := range meta.Tuples {
+			if _, ok := apps[tuple]; ok {
+				log.Printf(
+					"[DEBUG] Plugin %s tuple %s already registered, ignoring",
+					path, tuple)
+				continue
+			}
+
+			log.Printf("[INFO] Plugin %s provides app tuple: %s", path, tuple)
+			apps[tuple] = factory
+			registered++
+		}
+
+		// Nothing will ever use this plugin, so don't keep it running
+		if registered == 0 {
+			log.Printf("[DEBUG] Plugin %s provides no new tuples, stopping", path)
+			client.Kill()
+		}
+	}
+
+	return clients, nil
+}
+
+// killPlugins kills the plugins of the given clients.
+func killPlugins(clients []*plugin.Client) {
+	for _, client := range clients {
+		client.Kill()
+	}
+}
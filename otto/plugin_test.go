@@ -0,0 +1,134 @@
+package otto
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/plugin"
+)
+
+func TestDiscoverPlugins(t *testing.T) {
+	if err := os.Setenv("OTTO_TEST_BINARY", os.Args[0]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Unsetenv("OTTO_TEST_BINARY")
+
+	apps := map[app.Tuple]app.Factory{
+		testPluginDupTuple: func() (app.App, error) { return new(app.Mock), nil },
+	}
+	clients, err := discoverPlugins(testPath("plugins"), apps)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() {
+		for _, c := range clients {
+			c.Kill()
+		}
+	}()
+
+	if len(clients) != 2 {
+		t.Fatalf("bad: %d", len(clients))
+	}
+	if len(apps) != 2 {
+		t.Fatalf("bad: %#v", apps)
+	}
+	if _, ok := apps[testPluginAppTuple]; !ok {
+		t.Fatalf("bad: %#v", apps)
+	}
+
+	// The plugin that provides a tuple keeps running
+	if clients[0].Exited() {
+		t.Fatal("app plugin shouldn't have exited")
+	}
+	appImpl, err := apps[testPluginAppTuple]()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	meta, err := appImpl.Meta()
+	maybeClose(appImpl)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(meta.Tuples) != 1 || meta.Tuples[0] != testPluginAppTuple {
+		t.Fatalf("bad: %#v", meta)
+	}
+
+	// The plugin whose tuple was already registered is stopped
+	if !clients[1].Exited() {
+		t.Fatal("dup plugin should have exited")
+	}
+}
+
+func TestDiscoverPlugins_none(t *testing.T) {
+	apps := make(map[app.Tuple]app.Factory)
+	clients, err := discoverPlugins(testPath("basic"), apps)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(clients) != 0 || len(apps) != 0 {
+		t.Fatalf("bad: %#v %#v", clients, apps)
+	}
+}
+
+var (
+	testPluginAppTuple = app.Tuple{App: "plugin", Infra: "test", InfraFlavor: "test"}
+	testPluginDupTuple = app.Tuple{App: "dup", Infra: "test", InfraFlavor: "test"}
+)
+
+// This is not a real test. This is the plugin process started by the
+// scripts in test-fixtures/plugins.
+func TestHelperPlugin(*testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	defer os.Exit(0)
+
+	tuple := testPluginAppTuple
+	if os.Args[len(os.Args)-1] == "dup" {
+		tuple = testPluginDupTuple
+	}
+
+	plugin.Serve(&plugin.ServeOpts{
+		AppFunc: func() app.App {
+			return &app.Mock{
+				MetaResult: &app.Meta{Tuples: []app.Tuple{tuple}},
+			}
+		},
+	})
+}
+
+func TestNewCore_pluginDir(t *testing.T) {
+	if err := os.Setenv("OTTO_TEST_BINARY", os.Args[0]); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Unsetenv("OTTO_TEST_BINARY")
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.PluginDir = testPath("plugins")
+	core := testCore(t, coreConfig)
+	if _, ok := coreConfig.Apps[testPluginAppTuple]; !ok {
+		t.Fatalf("bad: %#v", coreConfig.Apps)
+	}
+
+	// The plugins run until Close
+	plugins := core.plugins
+	if len(plugins) != 2 {
+		t.Fatalf("bad: %d", len(plugins))
+	}
+	for _, c := range plugins {
+		if c.Exited() {
+			t.Fatal("plugin shouldn't have exited")
+		}
+	}
+	if err := core.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, c := range plugins {
+		if !c.Exited() {
+			t.Fatal("plugin should have exited")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package otto
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// producedFiles calls CoreConfig.OnFileProduced with the files of the
+// compile output as each part of the compilation writes them. Apps and
+// infrastructures write their output themselves, so the directory of
+// each one is scanned when its compilation is done.
+//
+// The parts are compiled in parallel, so this is safe for concurrent
+// use. The callback is never called concurrently and is called once
+// per file.
+type producedFiles struct {
+	sync.Mutex
+
+	f    func(string)
+	seen map[string]struct{}
+}
+
+func newProducedFiles(f func(string)) *producedFiles {
+	return &producedFiles{f: f, seen: make(map[string]struct{})}
+}
+
+// dir reports the files in dir that weren't reported yet.
+func (p *producedFiles) dir(dir string) {
+	if p.f == nil || dir == "" {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+		if info.Mode().IsRegular() {
+			p.report(path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("[WARN] error listing compiled files in %s: %s", dir, err)
+	}
+}
+
+// file reports the file at path if it wasn't reported yet.
+func (p *producedFiles) file(path string) {
+	if p.f == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	p.report(path)
+}
+
+func (p *producedFiles) report(path string) {
+	if _, ok := p.seen[path]; ok {
+		return
+	}
+
+	p.seen[path] = struct{}{}
+	p.f(path)
+}
@@ -0,0 +1,84 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreCompile_onFileProduced(t *testing.T) {
+	// The callback isn't synchronized since it is never called
+	// concurrently.
+	var paths []string
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	coreConfig.OnFileProduced = func(path string) {
+		paths = append(paths, path)
+	}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	var dirs []string
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		dirs = append(dirs, ctx.Dir)
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(ctx.Dir, "foo.txt")
+		return nil, ioutil.WriteFile(path, []byte("foo"), 0644)
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(dirs) < 2 {
+		t.Fatalf("bad: %#v", dirs)
+	}
+
+	seen := make(map[string]int)
+	for _, p := range paths {
+		seen[p]++
+	}
+	for _, dir := range dirs {
+		if n := seen[filepath.Join(dir, "foo.txt")]; n != 1 {
+			t.Fatalf("bad: %s %d %#v", dir, n, paths)
+		}
+	}
+	if seen[filepath.Join(coreConfig.CompileDir, manifestFilename)] != 1 {
+		t.Fatalf("bad: %#v", paths)
+	}
+}
+
+func TestCoreCompileTo_onFileProduced(t *testing.T) {
+	var paths []string
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.OnFileProduced = func(path string) {
+		paths = append(paths, path)
+	}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if err := os.MkdirAll(ctx.Dir, 0755); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(ctx.Dir, "foo.txt")
+		return nil, ioutil.WriteFile(path, []byte("foo"), 0644)
+	}
+
+	// Scratch compilations don't report their files
+	dir := filepath.Join(coreConfig.DataDir, "scratch")
+	if err := core.CompileTo(dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, manifestFilename)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("bad: %#v", paths)
+	}
+}
@@ -0,0 +1,138 @@
+package otto
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+// Promote makes the build artifact artifactID of the active
+// infrastructure available to the infrastructure named targetInfra in
+// the Appfile, without building again. The target infrastructure must
+// implement infrastructure.Promoter, which is given the credentials of
+// the target as they're loaded for any other operation on it. Only the
+// active infrastructure is compiled, so the Context.Dir given to the
+// Promoter is empty.
+//
+// The result is stored as the latest build of the target, which Deploy
+// deploys once the target is the active infrastructure. If the target
+// has the same type and flavor as the active infrastructure, the build
+// is scoped to the target by name (directory.Lookup.InfraName) so it
+// doesn't replace the builds it was promoted from. If builds are
+// signed, the artifact must verify and the promoted build is signed
+// again.
+func (c *Core) Promote(artifactID string, targetInfra string) (err error) {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+	defer func() { c.audit("promote", targetInfra, err) }()
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
+	source := c.appfile.ActiveInfrastructure()
+	if source == nil {
+		return errInfraNotFound(c.appfile, c.appfile.Project.Infrastructure)
+	}
+	var target *infrastructure.Context
+	for _, i := range c.appfile.Infrastructure {
+		if i.Name == targetInfra {
+			target = &infrastructure.Context{Infra: i}
+			break
+		}
+	}
+	if target == nil {
+		return errInfraNotFound(c.appfile, targetInfra)
+	}
+	if target.Infra.Name == source.Name {
+		return fmt.Errorf(
+			"The infrastructure '%s' is the active infrastructure, so it\n"+
+				"already has the build artifact.", targetInfra)
+	}
+
+	// Find the artifact. It may itself have been promoted to the active
+	// infrastructure, so look for that first.
+	lookup := directory.Lookup{
+		AppID:       c.appfile.ID,
+		Infra:       source.Type,
+		InfraFlavor: source.Flavor,
+		InfraName:   source.Name,
+	}
+	build, err := c.dir.GetBuild(&directory.Build{Lookup: lookup, ID: artifactID})
+	if err == nil && build == nil {
+		lookup.InfraName = ""
+		build, err = c.dir.GetBuild(&directory.Build{Lookup: lookup, ID: artifactID})
+	}
+	if err != nil {
+		return fmt.Errorf("Error loading build artifact: %s", err)
+	}
+	if build == nil {
+		return fmt.Errorf(
+			"Build artifact '%s' not found for this application and\n"+
+				"infrastructure.", artifactID)
+	}
+	if c.signer != nil {
+		if err := c.verifyBuild(build); err != nil {
+			return err
+		}
+	}
+
+	infra, infraCtx, err := c.infraFor(target.Infra)
+	if err != nil {
+		return err
+	}
+	defer maybeClose(infra)
+
+	// Only the active infrastructure is compiled
+	infraCtx.Dir = ""
+
+	promoter, ok := infra.(infrastructure.Promoter)
+	if !ok {
+		return fmt.Errorf(
+			"The infrastructure type '%s' doesn't support promoting build\n"+
+				"artifacts. Build the application for '%s' instead.",
+			target.Infra.Type, targetInfra)
+	}
+	if err := c.creds(infra, infraCtx); err != nil {
+		return err
+	}
+	c.showIdentity(infra, infraCtx)
+
+	c.ui.Header(fmt.Sprintf(
+		"Promoting build artifact '%s' to '%s'...", artifactID, targetInfra))
+	artifact, err := promoter.Promote(infraCtx, build)
+	if err != nil {
+		return wrapErrorf(err, "Error promoting build artifact: %s")
+	}
+
+	promoted := &directory.Build{
+		Lookup: directory.Lookup{
+			AppID:       c.appfile.ID,
+			Infra:       target.Infra.Type,
+			InfraFlavor: target.Infra.Flavor,
+		},
+		Artifact: artifact,
+	}
+	if target.Infra.Type == source.Type && target.Infra.Flavor == source.Flavor {
+		promoted.Lookup.InfraName = target.Infra.Name
+	}
+	if err := c.dir.PutBuild(promoted); err != nil {
+		return fmt.Errorf("Error storing promoted build: %s", err)
+	}
+	if c.signer != nil {
+		if err := c.signBuild(promoted.Lookup); err != nil {
+			return err
+		}
+	}
+
+	c.ui.Message(fmt.Sprintf(
+		"[green]Promoted build artifact '%s' to '%s' as '%s'.",
+		artifactID, targetInfra, promoted.ID))
+	return nil
+}
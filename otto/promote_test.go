@@ -0,0 +1,168 @@
+package otto
+
+import (
+	"testing"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCorePromote(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	coreConfig.Appfile.File.Infrastructure = append(
+		coreConfig.Appfile.File.Infrastructure, &appfile.Infrastructure{
+			Name:   "production",
+			Type:   "prod",
+			Flavor: "test",
+		})
+	TestInfra(t, "test", coreConfig)
+	promoter := &testPromoter{
+		Mock:     new(infrastructure.Mock),
+		Artifact: map[string]string{"image": "prod-image"},
+	}
+	coreConfig.Infrastructures["prod"] = func() (infrastructure.Infrastructure, error) {
+		return promoter, nil
+	}
+	core := testCore(t, coreConfig)
+
+	build := &directory.Build{
+		Lookup: directory.Lookup{
+			AppID:       core.appfile.ID,
+			Infra:       "test",
+			InfraFlavor: "test",
+		},
+		Artifact: map[string]string{"image": "staging-image"},
+	}
+	if err := coreConfig.Directory.PutBuild(build); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Unknown artifacts and infrastructures are an error
+	if err := core.Promote("unknown", "production"); err == nil {
+		t.Fatal("should error")
+	}
+	if err := core.Promote(build.ID, "unknown"); err == nil {
+		t.Fatal("should error")
+	}
+	if promoter.Build != nil {
+		t.Fatal("promote shouldn't be called")
+	}
+
+	if err := core.Promote(build.ID, "production"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if promoter.Build == nil || promoter.Build.ID != build.ID {
+		t.Fatalf("bad: %#v", promoter.Build)
+	}
+	if promoter.Ctx.Dir != "" {
+		t.Fatalf("bad: %s", promoter.Ctx.Dir)
+	}
+
+	// The result is the latest build of the target
+	actual, err := coreConfig.Directory.GetBuild(&directory.Build{
+		Lookup: directory.Lookup{
+			AppID:       core.appfile.ID,
+			Infra:       "prod",
+			InfraFlavor: "test",
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.Artifact["image"] != "prod-image" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Infrastructures that can't promote are an error
+	coreConfig.Infrastructures["prod"] = func() (infrastructure.Infrastructure, error) {
+		return new(infrastructure.Mock), nil
+	}
+	core = testCore(t, coreConfig)
+	if err := core.Promote(build.ID, "production"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCorePromote_sameType(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	coreConfig.Appfile.File.Infrastructure = append(
+		coreConfig.Appfile.File.Infrastructure, &appfile.Infrastructure{
+			Name:   "production",
+			Type:   "test",
+			Flavor: "test",
+		})
+	promoter := &testPromoter{
+		Mock:     new(infrastructure.Mock),
+		Artifact: map[string]string{"image": "prod-image"},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return promoter, nil
+	}
+	core := testCore(t, coreConfig)
+
+	lookup := directory.Lookup{
+		AppID:       core.appfile.ID,
+		Infra:       "test",
+		InfraFlavor: "test",
+	}
+	build := &directory.Build{
+		Lookup:   lookup,
+		Artifact: map[string]string{"image": "staging-image"},
+	}
+	if err := coreConfig.Directory.PutBuild(build); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Promote(build.ID, "production"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if promoter.Build == nil || promoter.Build.ID != build.ID {
+		t.Fatalf("bad: %#v", promoter.Build)
+	}
+
+	// The result is scoped to the target
+	scoped := lookup
+	scoped.InfraName = "production"
+	actual, err := coreConfig.Directory.GetBuild(&directory.Build{Lookup: scoped})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.Artifact["image"] != "prod-image" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The builds of the type and flavor are left alone
+	actual, err = coreConfig.Directory.GetBuild(&directory.Build{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.ID != build.ID {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The active infrastructure can't be the target
+	if err := core.Promote(build.ID, core.appfile.Project.Infrastructure); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+type testPromoter struct {
+	*infrastructure.Mock
+
+	Artifact map[string]string
+	Build    *directory.Build
+	Ctx      *infrastructure.Context
+}
+
+func (p *testPromoter) Promote(
+	ctx *infrastructure.Context, build *directory.Build) (map[string]string, error) {
+	p.Build = build
+	p.Ctx = ctx
+	return p.Artifact, nil
+}
@@ -0,0 +1,89 @@
+package otto
+
+import (
+	"sort"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+)
+
+// AppName returns the name of the main application, or an empty string
+// if the Appfile doesn't have one. This has no side effects.
+func (c *Core) AppName() string {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if c.appfile.Application == nil {
+		return ""
+	}
+
+	return c.appfile.Application.Name
+}
+
+// AppType returns the type of the main application, or an empty string
+// if the Appfile doesn't have one. This has no side effects.
+func (c *Core) AppType() string {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if c.appfile.Application == nil {
+		return ""
+	}
+
+	return c.appfile.Application.Type
+}
+
+// InfraName returns the name of the infrastructure that is active for
+// this Appfile. This has no side effects.
+func (c *Core) InfraName() string {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if c.appfile.Project == nil {
+		return ""
+	}
+
+	return c.appfile.Project.Infrastructure
+}
+
+// DependencyNames returns the sorted names of all the applications the
+// main application depends on, including transitive dependencies. This
+// has no side effects.
+func (c *Core) DependencyNames() []string {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, raw := range c.appfileCompiled.Graph.Vertices() {
+		if raw == root {
+			continue
+		}
+
+		f := raw.(*appfile.CompiledGraphVertex).File
+		if f.Application == nil {
+			continue
+		}
+
+		result = append(result, f.Application.Name)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// ListApps returns the tuples of all the registered app implementations,
+// sorted. Tuples may contain wildcards. This has no side effects.
+func (c *Core) ListApps() []app.Tuple {
+	return c.appTuples()
+}
+
+// ListInfrastructures returns the sorted types of all the registered
+// infrastructure implementations. This has no side effects.
+func (c *Core) ListInfrastructures() []string {
+	return c.infraNames()
+}
@@ -0,0 +1,98 @@
+package otto
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/terraform/dag"
+)
+
+// Reload replaces the compiled Appfile of this Core, for example after
+// the Appfile changed on disk. Any state derived from the previous
+// Appfile is discarded. If a Root was set in the CoreConfig, it is
+// applied to the new Appfile as well.
+//
+// Reload returns ErrBusy if an operation is running. It waits for any
+// read-only calls such as Status to complete before swapping the
+// Appfile. The reload is rejected if the new Appfile uses an
+// infrastructure or app type that this Core has no implementation for.
+func (c *Core) Reload(compiled *appfile.Compiled) error {
+	if compiled == nil {
+		return fmt.Errorf("a compiled Appfile is required to reload")
+	}
+
+	if !atomic.CompareAndSwapInt32(&c.busy, 0, 1) {
+		return ErrBusy
+	}
+	defer atomic.StoreInt32(&c.busy, 0)
+
+	source := sourceOf(compiled)
+	if c.root != "" {
+		var err error
+		compiled, err = compiledWithRoot(compiled, c.root)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.checkReload(compiled); err != nil {
+		return fmt.Errorf("Error reloading Appfile: %s", err)
+	}
+
+	// The extra roots are merged with the new graph
+	var fo *forest
+	if len(c.extraRoots) > 0 {
+		var err error
+		fo, err = newForest(compiled, c.extraRoots)
+		if err != nil {
+			return fmt.Errorf("Error reloading Appfile: %s", err)
+		}
+	}
+
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+
+	c.appfile = compiled.File
+	c.appfileCompiled = compiled
+	c.source = source
+	c.forest = fo
+	c.resetCompileMetadata()
+	return nil
+}
+
+// checkReload verifies that this Core is able to work with the given
+// compiled Appfile.
+func (c *Core) checkReload(compiled *appfile.Compiled) error {
+	if err := compiled.Validate(); err != nil {
+		return err
+	}
+
+	config := compiled.File.ActiveInfrastructure()
+	if config == nil {
+		return errInfraNotFound(compiled.File, compiled.File.Project.Infrastructure)
+	}
+	if _, ok := c.infras[config.Type]; !ok {
+		return fmt.Errorf(
+			"infrastructure type not supported: %s", config.Type)
+	}
+
+	for _, raw := range compiled.Graph.Vertices() {
+		v, ok := raw.(*appfile.CompiledGraphVertex)
+		if !ok {
+			return fmt.Errorf("unknown vertex: %s", dag.VertexName(raw))
+		}
+
+		tuple, err := c.appTuple(v.File)
+		if err != nil {
+			return err
+		}
+		if app.TupleMap(c.apps).Lookup(tuple) == nil {
+			return fmt.Errorf(
+				"app implementation for tuple not found: %s", tuple)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package otto
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"github.com/hashicorp/otto/app"
+)
+
+// RemoteCache is a cache of built dev dependencies shared between
+// machines, such as an object store used by a CI fleet. Dev looks up
+// every dev dependency that isn't cached locally in the remote cache
+// before building it, and stores the ones it builds there.
+//
+// The remote cache is only an optimization: if it fails, a warning is
+// reported and the dependency is built locally.
+type RemoteCache interface {
+	// Get returns the data stored under key. The bool is false if there
+	// is nothing stored under the key.
+	Get(key string) (io.ReadCloser, bool, error)
+
+	// Put stores the data read from r under key.
+	Put(key string, r io.Reader) error
+}
+
+// remoteDevDepKey returns the key that the dev dependency cached at
+// cachePath is stored under in the RemoteCache. It is derived from the
+// cache key of the dependency and of the app, so dependencies share
+// a key only if they would share the local cache.
+func (c *Core) remoteDevDepKey(ctx *app.Context, cachePath string) string {
+	key := filepath.Base(ctx.CacheDir) + "/" + filepath.Base(cachePath)
+	return "dev-dep-" + hashBytes(c.hasher, []byte(key))
+}
+
+// getRemoteDevDep downloads the dev dependency from the RemoteCache into
+// the cache directory of the app, returning true if it was found.
+func (c *Core) getRemoteDevDep(ctx *app.Context, cachePath string) (bool, error) {
+	key := c.remoteDevDepKey(ctx, cachePath)
+	r, ok, err := c.remoteCache.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		log.Printf("[DEBUG] core: remote cache miss: %s", key)
+		return false, nil
+	}
+	defer r.Close()
+
+	if err := extractArchive(r, ctx.CacheDir); err != nil {
+		return false, fmt.Errorf("Error unpacking %s: %s", key, err)
+	}
+	if _, err := app.ReadDevDep(cachePath); err != nil {
+		return false, fmt.Errorf(
+			"Error reading the dev dependency from %s: %s", key, err)
+	}
+
+	return true, nil
+}
+
+// putRemoteDevDep uploads the cache directory of the app, which holds
+// the dev dependency, to the RemoteCache.
+func (c *Core) putRemoteDevDep(ctx *app.Context, cachePath string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, ctx.CacheDir))
+	}()
+
+	err := c.remoteCache.Put(c.remoteDevDepKey(ctx, cachePath), pr)
+	pr.CloseWithError(io.ErrClosedPipe)
+	return err
+}
@@ -0,0 +1,209 @@
+package otto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreDev_remoteCache(t *testing.T) {
+	remote := &testRemoteCache{}
+
+	// The first machine builds the dependency and stores it
+	core, appMock := testRemoteCacheCore(t, remote)
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DevDepCalled {
+		t.Fatal("DevDep should be called")
+	}
+	if len(remote.Data) != 1 {
+		t.Fatalf("bad: %#v", remote.Data)
+	}
+
+	// A second machine uses the stored dependency
+	core, appMock = testRemoteCacheCore(t, remote)
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.DevDepCalled {
+		t.Fatal("DevDep should not be called")
+	}
+	matches, err := filepath.Glob(filepath.Join(core.dataDir, "cache", "*", "box"))
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("bad: %#v %v", matches, err)
+	}
+	data, err := ioutil.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "box" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	// A failing remote cache only results in a warning
+	remote.Err = errors.New("unavailable")
+	core, appMock = testRemoteCacheCore(t, remote)
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DevDepCalled {
+		t.Fatal("DevDep should be called")
+	}
+	if len(core.Warnings()) != 2 {
+		t.Fatalf("bad: %#v", core.Warnings())
+	}
+}
+
+func TestCoreDev_remoteCacheRetry(t *testing.T) {
+	remote := &testRemoteCache{}
+	core, _ := testRemoteCacheCore(t, remote)
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Transient failures are retried before building the dependency
+	remote.Fails = 2
+	core, appMock := testRemoteCacheCore(t, remote)
+	core.cacheRetries = 2
+	core.cacheBackoff = time.Millisecond
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if appMock.DevDepCalled {
+		t.Fatal("DevDep should not be called")
+	}
+	if len(core.Warnings()) != 0 {
+		t.Fatalf("bad: %#v", core.Warnings())
+	}
+
+	// More failures than retries build it
+	remote.Fails = 3
+	core, appMock = testRemoteCacheCore(t, remote)
+	core.cacheRetries = 2
+	core.cacheBackoff = time.Millisecond
+	if err := core.Dev(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DevDepCalled {
+		t.Fatal("DevDep should be called")
+	}
+}
+
+func TestRetryCacheRead_miss(t *testing.T) {
+	core := &Core{cacheRetries: 3, cacheBackoff: time.Millisecond}
+
+	calls := 0
+	ok, err := core.retryCacheRead("key", func() (bool, error) {
+		calls++
+		return false, nil
+	})
+	if ok || err != nil {
+		t.Fatalf("bad: %v %v", ok, err)
+	}
+	if calls != 1 {
+		t.Fatalf("a miss should not be retried: %d", calls)
+	}
+
+	calls = 0
+	ok, err = core.retryCacheRead("key", func() (bool, error) {
+		calls++
+		return false, errors.New("unavailable")
+	})
+	if ok || err == nil {
+		t.Fatalf("bad: %v %v", ok, err)
+	}
+	if calls != 4 {
+		t.Fatalf("bad: %d", calls)
+	}
+}
+
+// testRemoteCacheCore returns a compiled Core for the deps fixture with
+// its own data directory that uses the remote cache.
+func testRemoteCacheCore(t *testing.T, remote RemoteCache) (*Core, *testDevDepFiles) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	coreConfig.RemoteCache = remote
+	appMock := &testDevDepFiles{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return core, appMock
+}
+
+// testDevDepFiles is an app whose dev dependency is a file in its cache
+// directory.
+type testDevDepFiles struct {
+	*app.Mock
+}
+
+func (a *testDevDepFiles) DevDep(dst, src *app.Context) (*app.DevDep, error) {
+	a.Mock.DevDep(dst, src)
+
+	path := filepath.Join(src.CacheDir, "box")
+	if err := ioutil.WriteFile(path, []byte("box"), 0644); err != nil {
+		return nil, err
+	}
+
+	return &app.DevDep{Files: []string{path}}, nil
+}
+
+// testRemoteCache is an in-memory RemoteCache. If Err is set, every
+// call fails with it. The first Fails calls to Get fail as well.
+type testRemoteCache struct {
+	sync.Mutex
+
+	Data  map[string][]byte
+	Err   error
+	Fails int
+}
+
+func (c *testRemoteCache) Get(key string) (io.ReadCloser, bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Err != nil {
+		return nil, false, c.Err
+	}
+	if c.Fails > 0 {
+		c.Fails--
+		return nil, false, errors.New("transient failure")
+	}
+	data, ok := c.Data[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), true, nil
+}
+
+func (c *testRemoteCache) Put(key string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Err != nil {
+		return c.Err
+	}
+	if c.Data == nil {
+		c.Data = make(map[string][]byte)
+	}
+	c.Data[key] = data
+	return nil
+}
@@ -0,0 +1,229 @@
+package otto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// removeAttempts is the number of times removal of a directory is
+	// tried before giving up.
+	removeAttempts = 5
+
+	// removeWait is how long to wait after the first failed removal.
+	// This doubles after each attempt.
+	removeWait = 100 * time.Millisecond
+)
+
+// removeAll removes path and everything it contains like os.RemoveAll,
+// but retries with backoff if that fails. On Windows, files can't be
+// removed while another process has them open, and tools that Otto ran
+// before sometimes keep files open for a short time after they exit.
+func removeAll(path string) error {
+	return retryRemove(path, os.RemoveAll, removeAttempts, removeWait)
+}
+
+func retryRemove(
+	path string, remove func(string) error, attempts int, wait time.Duration) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			log.Printf(
+				"[WARN] error removing %s, retrying in %s: %s", path, wait, err)
+			time.Sleep(wait)
+			wait *= 2
+		}
+
+		if err = remove(path); err == nil {
+			return nil
+		}
+	}
+
+	// Find the path that couldn't be removed so the user knows what to
+	// look for.
+	failed := path
+	if perr, ok := err.(*os.PathError); ok {
+		failed = perr.Path
+	}
+
+	return fmt.Errorf(
+		"Error deleting %s: %s could not be deleted. Another process, such\n"+
+			"as an editor or a tool run by Otto, may still have it open. Close\n"+
+			"that process and try again.\n\n"+
+			"The original error was: %s",
+		path, failed, err)
+}
+
+// clearCompileDir deletes the prior compilation. If the compile directory
+// is a symbolic link, this either fails or clears the contents of the
+// link target, depending on CoreConfig.FollowCompileDirSymlink.
+func (c *Core) clearCompileDir() error {
+	if err := c.checkCompileDir(); err != nil {
+		return err
+	}
+
+	fi, err := os.Lstat(c.compileDir)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return removeAll(c.compileDir)
+	}
+
+	target, err := filepath.EvalSymlinks(c.compileDir)
+	if err != nil {
+		return fmt.Errorf(
+			"Error resolving compile directory %s: %s", c.compileDir, err)
+	}
+	if !c.followSymlink {
+		return fmt.Errorf(
+			"The compile directory %s is a symbolic link to %s.\n\n"+
+				"Otto deletes the compile directory on every compilation, so it refuses\n"+
+				"to use a symbolic link to avoid deleting data in the link target by\n"+
+				"accident. Remove the link, or configure Otto to follow it and\n"+
+				"compile into the target.",
+			c.compileDir, target)
+	}
+
+	// Clear the target but keep it and the link in place
+	log.Printf("[INFO] compile directory is a link, clearing target: %s", target)
+	entries, err := ioutil.ReadDir(target)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := removeAll(filepath.Join(target, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCompileDir verifies that clearing the compile directory won't
+// delete anything else Otto relies on, which would be the case if it
+// is or contains the project, the data directory, or the local
+// directory. This catches mistakes such as compiling into "/" or the
+// home directory.
+func (c *Core) checkCompileDir() error {
+	dir, err := filepath.Abs(c.compileDir)
+	if err != nil {
+		return fmt.Errorf(
+			"Error resolving compile directory %s: %s", c.compileDir, err)
+	}
+
+	others := []struct {
+		Name string
+		Path string
+	}{
+		{"project directory", c.projectDirPath()},
+		{"data directory", c.dataDir},
+		{"local directory", c.localDir},
+	}
+	for _, other := range others {
+		if other.Path == "" {
+			continue
+		}
+
+		path, err := filepath.Abs(other.Path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return fmt.Errorf(
+				"The compile directory %s contains the %s %s.\n\n"+
+					"Otto deletes the compile directory on every compilation, so it refuses\n"+
+					"to use a directory that would delete the %s as well.\n"+
+					"Please choose a different compile directory.",
+				c.compileDir, other.Name, other.Path, other.Name)
+		}
+	}
+
+	return nil
+}
+
+// removeCompiledFiles deletes only the files that the prior compilation
+// produced according to its manifest, along with directories that are
+// left empty, for CoreConfig.PreserveCompileDir. The remaining files are
+// returned, keyed by slash-separated path with their hashes.
+func (c *Core) removeCompiledFiles() (map[string]string, error) {
+	if err := c.checkCompileDir(); err != nil {
+		return nil, err
+	}
+
+	m, err := c.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error reading the manifest of the prior compilation: %s", err)
+	}
+
+	var paths []string
+	if m != nil {
+		paths = m.Paths()
+		if m.HashAlgorithm != manifestHashAlgorithm(c.hasher) {
+			// The hashes of the remaining files won't match the new
+			// manifest, so they're all kept out of it as if modified.
+			log.Printf(
+				"[WARN] prior manifest uses hash algorithm %q, compiled files "+
+					"will be treated as modified", m.HashAlgorithm)
+		}
+	} else {
+		log.Printf("[WARN] no prior manifest, not deleting any compiled files")
+	}
+	paths = append(paths, manifestFilename, "metadata.json", compileTreeFilename)
+	for _, p := range paths {
+		if strings.HasPrefix(p, "../") {
+			continue
+		}
+
+		path := filepath.Join(c.compileDir, filepath.FromSlash(p))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		// Remove the parents that are now empty. This fails on the
+		// first one that still has contents, which is what we want.
+		for dir := filepath.Dir(path); dir != filepath.Clean(c.compileDir); dir = filepath.Dir(dir) {
+			if err := os.Remove(dir); err != nil {
+				break
+			}
+		}
+	}
+
+	kept := make(map[string]string)
+	err = filepath.Walk(c.compileDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == c.compileDir {
+				return nil
+			}
+
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.compileDir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(c.hasher, path)
+		if err != nil {
+			return err
+		}
+
+		kept[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return kept, nil
+}
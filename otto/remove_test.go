@@ -0,0 +1,240 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestRetryRemove(t *testing.T) {
+	calls := 0
+	remove := func(path string) error {
+		calls++
+		if calls < 3 {
+			return &os.PathError{Op: "remove", Path: path + "/foo", Err: os.ErrPermission}
+		}
+
+		return nil
+	}
+
+	if err := retryRemove("dir", remove, 5, 0); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if calls != 3 {
+		t.Fatalf("bad: %d", calls)
+	}
+}
+
+func TestRetryRemove_fail(t *testing.T) {
+	calls := 0
+	remove := func(path string) error {
+		calls++
+		return &os.PathError{Op: "remove", Path: path + "/foo", Err: os.ErrPermission}
+	}
+
+	err := retryRemove("dir", remove, 3, 0)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if calls != 3 {
+		t.Fatalf("bad: %d", calls)
+	}
+	if !strings.Contains(err.Error(), "dir/foo could not be deleted") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestCoreCompile_symlink(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Point the compile directory at a target with a stale file
+	target := filepath.Join(td, "target")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(target, "stale"), nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	coreConfig.CompileDir = filepath.Join(td, "compile")
+	if err := os.Symlink(target, coreConfig.CompileDir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(filepath.Join(target, "stale")); err != nil {
+		t.Fatal("target should be untouched")
+	}
+
+	coreConfig.FollowCompileDirSymlink = true
+	core = testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi, err := os.Lstat(coreConfig.CompileDir); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("link should be kept")
+	}
+	if _, err := os.Stat(filepath.Join(target, "stale")); err == nil {
+		t.Fatal("stale file should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(target, "metadata.json")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCoreCompile_dangerousDir(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+
+	// The parent of the data directory would delete it. NewCore
+	// rejects this directly, so compile into it instead.
+	parent := filepath.Dir(coreConfig.DataDir)
+	if err := os.MkdirAll(coreConfig.DataDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	core := testCore(t, coreConfig)
+	err := core.CompileTo(parent)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "data directory") {
+		t.Fatalf("bad: %s", err)
+	}
+	if _, err := os.Stat(coreConfig.DataDir); err != nil {
+		t.Fatal("data directory should be untouched")
+	}
+}
+
+func TestNewCore_dangerousDir(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Modify func(*CoreConfig)
+		Err    string
+	}{
+		{
+			"compile dir is the data dir",
+			func(c *CoreConfig) { c.CompileDir = c.DataDir },
+			"data directory",
+		},
+		{
+			"compile dir is the parent of the local dir",
+			func(c *CoreConfig) {
+				c.LocalDir = filepath.Join(c.CompileDir, "local")
+			},
+			"local directory",
+		},
+		{
+			"data dir is nested in the compile dir",
+			func(c *CoreConfig) {
+				c.DataDir = filepath.Join(c.CompileDir, "a", "data")
+			},
+			"data directory",
+		},
+	}
+
+	for _, tc := range cases {
+		coreConfig := TestCoreConfig(t)
+		coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+		tc.Modify(coreConfig)
+
+		_, err := NewCore(coreConfig)
+		if err == nil {
+			t.Fatalf("%s: should error", tc.Name)
+		}
+		if !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %s", tc.Name, err)
+		}
+	}
+
+	// Sibling directories are fine
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	if _, err := NewCore(coreConfig); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCoreCompile_preserveDir(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.PreserveCompileDir = true
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Each compilation produces a differently named file
+	name := "old"
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		dir := filepath.Join(ctx.Dir, "generated")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+
+		return nil, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+
+	// Files maintained by hand
+	manual := []string{
+		filepath.Join(coreConfig.CompileDir, "manual"),
+		filepath.Join(coreConfig.CompileDir, "app", "manual"),
+	}
+	for _, path := range manual {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte("hand"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	name = "new"
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	generated := filepath.Join(coreConfig.CompileDir, "app", "generated")
+	if _, err := os.Stat(filepath.Join(generated, "old")); !os.IsNotExist(err) {
+		t.Fatal("old output should be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(generated, "new")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range manual {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Only the compiled output is in the manifest
+	m, err := core.Manifest()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range m.Paths() {
+		if strings.HasSuffix(path, "manual") {
+			t.Fatalf("bad: %#v", m.Paths())
+		}
+	}
+	if !reflect.DeepEqual(m.Paths(), []string{"app/generated/new"}) {
+		t.Fatalf("bad: %#v", m.Paths())
+	}
+}
@@ -0,0 +1,95 @@
+package otto
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/ui"
+)
+
+// selfTestName is the name of the application and project in the
+// Appfile used by SelfTest.
+const selfTestName = "otto-selftest"
+
+// SelfTest verifies that the registered app and infrastructure
+// implementations work by compiling a minimal Appfile for every
+// registered app tuple. This doesn't need a real project and doesn't
+// touch the directories or data of this Core: everything is done in a
+// temporary directory that is removed afterwards. This is useful as a
+// health check to confirm plugins loaded correctly.
+//
+// Tuples that contain wildcards are skipped since there isn't a single
+// Appfile that matches them.
+func (c *Core) SelfTest() error {
+	tuples := c.appTuples()
+	if len(tuples) == 0 {
+		return fmt.Errorf("no app implementations are registered")
+	}
+
+	for _, tuple := range tuples {
+		if tuple.App == "*" || tuple.Infra == "*" || tuple.InfraFlavor == "*" {
+			log.Printf("[DEBUG] selftest: skipping wildcard tuple %s", tuple)
+			continue
+		}
+
+		if err := c.selfTestTuple(tuple); err != nil {
+			return fmt.Errorf("Self test failed for %s: %s", tuple, err)
+		}
+	}
+
+	return nil
+}
+
+// selfTestTuple compiles a minimal Appfile for a single tuple.
+func (c *Core) selfTestTuple(tuple app.Tuple) error {
+	if _, ok := c.infras[tuple.Infra]; !ok {
+		return fmt.Errorf("infrastructure type not registered: %s", tuple.Infra)
+	}
+
+	td, err := c.tempDir("selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	f := &appfile.File{
+		Path: filepath.Join(td, "Appfile"),
+		Application: &appfile.Application{
+			Name: selfTestName,
+			Type: tuple.App,
+		},
+		Project: &appfile.Project{
+			Name:           selfTestName,
+			Infrastructure: selfTestName,
+		},
+		Infrastructure: []*appfile.Infrastructure{
+			&appfile.Infrastructure{
+				Name:   selfTestName,
+				Type:   tuple.Infra,
+				Flavor: tuple.InfraFlavor,
+			},
+		},
+	}
+
+	core, err := NewCoreFromFile(f, &CoreConfig{
+		DataDir:           filepath.Join(td, "data"),
+		LocalDir:          filepath.Join(td, "local"),
+		CompileDir:        filepath.Join(td, "compile"),
+		Directory:         &directory.BoltBackend{Dir: filepath.Join(td, "directory")},
+		Apps:              c.apps,
+		FactoryMiddleware: c.appMiddleware,
+		Infrastructures:   c.infras,
+		Foundations:       c.foundationMap,
+		Ui:                &ui.Null{},
+	})
+	if err != nil {
+		return err
+	}
+
+	return core.Compile()
+}
@@ -0,0 +1,75 @@
+package otto
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/appfile"
+	execHelper "github.com/hashicorp/otto/helper/exec"
+)
+
+// Shell opens an interactive shell into the development environment
+// of the main application. The process's stdin, stdout, and stderr are
+// attached directly to the session so that terminal features work.
+//
+// The app implementation must implement app.Sheller. If it doesn't, an
+// error is returned.
+func (c *Core) Shell() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if err := c.materialize(); err != nil {
+		return err
+	}
+
+	root, err := c.appfileCompiled.Graph.Root()
+	if err != nil {
+		return err
+	}
+	rootCtx, err := c.appContext(root.(*appfile.CompiledGraphVertex).File)
+	if err != nil {
+		return fmt.Errorf(
+			"Error loading App: %s", err)
+	}
+	rootApp, err := c.app(rootCtx)
+	if err != nil {
+		return fmt.Errorf(
+			"Error loading App: %s", err)
+	}
+	defer maybeClose(rootApp)
+
+	sheller, ok := rootApp.(app.Sheller)
+	if !ok {
+		return fmt.Errorf(
+			"The app implementation for '%s' doesn't support opening a\n"+
+				"shell into the development environment.",
+			rootCtx.Tuple)
+	}
+
+	shellCmd, err := sheller.Shell(rootCtx)
+	if err != nil {
+		return err
+	}
+	if shellCmd == nil || shellCmd.Path == "" {
+		return fmt.Errorf(
+			"The app implementation for '%s' returned no shell command.",
+			rootCtx.Tuple)
+	}
+
+	cmd := exec.Command(shellCmd.Path, shellCmd.Args...)
+	cmd.Dir = shellCmd.Dir
+	if len(shellCmd.Env) > 0 {
+		cmd.Env = append(os.Environ(), shellCmd.Env...)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Printf("[INFO] opening dev shell: %s %v", cmd.Path, shellCmd.Args)
+	return execHelper.Runner(cmd)
+}
@@ -0,0 +1,115 @@
+package otto
+
+import (
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	execHelper "github.com/hashicorp/otto/helper/exec"
+)
+
+func TestCoreShell(t *testing.T) {
+	runner := new(execHelper.MockRunner)
+	defer execHelper.TestChrunner(runner.Run)()
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := &testSheller{
+		Mock: TestApp(t, TestAppTuple, coreConfig),
+		Command: &app.ShellCommand{
+			Path: "ssh",
+			Args: []string{"-t", "default"},
+			Dir:  "/tmp",
+			Env:  []string{"FOO=bar"},
+		},
+	}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Shell(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.ShellCalled {
+		t.Fatal("Shell should be called")
+	}
+	if len(runner.Commands) != 1 {
+		t.Fatalf("bad: %#v", runner.Commands)
+	}
+	cmd := runner.Commands[0]
+	if len(cmd.Args) != 3 || cmd.Args[1] != "-t" || cmd.Args[2] != "default" {
+		t.Fatalf("bad: %#v", cmd.Args)
+	}
+	if cmd.Dir != "/tmp" {
+		t.Fatalf("bad: %s", cmd.Dir)
+	}
+	if env := cmd.Env[len(cmd.Env)-1]; env != "FOO=bar" {
+		t.Fatalf("bad: %s", env)
+	}
+}
+
+func TestCoreShell_notSupported(t *testing.T) {
+	runner := new(execHelper.MockRunner)
+	defer execHelper.TestChrunner(runner.Run)()
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Shell(); err == nil {
+		t.Fatal("should error")
+	}
+	if len(runner.Commands) != 0 {
+		t.Fatalf("bad: %#v", runner.Commands)
+	}
+}
+
+func TestCoreShell_noCommand(t *testing.T) {
+	runner := new(execHelper.MockRunner)
+	defer execHelper.TestChrunner(runner.Run)()
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	appMock := &testSheller{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// No command at all
+	if err := core.Shell(); err == nil {
+		t.Fatal("should error")
+	}
+
+	// A command without a path
+	appMock.Command = &app.ShellCommand{Args: []string{"foo"}}
+	if err := core.Shell(); err == nil {
+		t.Fatal("should error")
+	}
+	if len(runner.Commands) != 0 {
+		t.Fatalf("bad: %#v", runner.Commands)
+	}
+}
+
+// testSheller is an app that opens the shell command it is given.
+type testSheller struct {
+	*app.Mock
+
+	Command     *app.ShellCommand
+	ShellCalled bool
+}
+
+func (s *testSheller) Shell(*app.Context) (*app.ShellCommand, error) {
+	s.ShellCalled = true
+	return s.Command, nil
+}
@@ -0,0 +1,91 @@
+package otto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/otto/directory"
+)
+
+// Signer signs and verifies build artifacts. If a Signer is configured
+// for a Core, every successful Build is signed and Deploy refuses to
+// deploy a build whose signature doesn't verify.
+//
+// Both methods are given the same canonical representation of the build
+// metadata: the lookup information and the artifact.
+type Signer interface {
+	// Sign returns the signature for the data.
+	Sign(data []byte) ([]byte, error)
+
+	// Verify returns an error if the signature isn't valid for the data.
+	Verify(data, signature []byte) error
+}
+
+// signedBuild is the canonical representation of a build that is
+// signed. encoding/json sorts map keys, so the encoding of this is
+// deterministic.
+type signedBuild struct {
+	AppID       string            `json:"app_id"`
+	Infra       string            `json:"infra"`
+	InfraFlavor string            `json:"infra_flavor"`
+	Artifact    map[string]string `json:"artifact"`
+}
+
+// buildSigningData returns the data that is signed for a build.
+func buildSigningData(b *directory.Build) ([]byte, error) {
+	return json.Marshal(&signedBuild{
+		AppID:       b.AppID,
+		Infra:       b.Infra,
+		InfraFlavor: b.InfraFlavor,
+		Artifact:    b.Artifact,
+	})
+}
+
+// signBuild signs the build stored for the given lookup and stores the
+// signature with it.
+func (c *Core) signBuild(lookup directory.Lookup) error {
+	build, err := c.dir.GetBuild(&directory.Build{Lookup: lookup})
+	if err != nil {
+		return fmt.Errorf("Error loading build for signing: %s", err)
+	}
+	if build == nil {
+		return fmt.Errorf(
+			"The build didn't record an artifact in the directory,\n" +
+				"so it can't be signed.")
+	}
+
+	data, err := buildSigningData(build)
+	if err != nil {
+		return err
+	}
+	build.Signature, err = c.signer.Sign(data)
+	if err != nil {
+		return fmt.Errorf("Error signing build: %s", err)
+	}
+
+	if err := c.dir.PutBuild(build); err != nil {
+		return fmt.Errorf("Error storing build signature: %s", err)
+	}
+
+	return nil
+}
+
+// verifyBuild verifies the signature of the build.
+func (c *Core) verifyBuild(build *directory.Build) error {
+	if len(build.Signature) == 0 {
+		return fmt.Errorf(
+			"The build artifact '%s' isn't signed. Only signed build\n"+
+				"artifacts can be deployed. Please build again.", build.ID)
+	}
+
+	data, err := buildSigningData(build)
+	if err != nil {
+		return err
+	}
+	if err := c.signer.Verify(data, build.Signature); err != nil {
+		return fmt.Errorf(
+			"The signature of build artifact '%s' is invalid: %s", build.ID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package otto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreBuild_sign(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	coreConfig.Signer = &testSigner{Key: []byte("secret")}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	tuple, err := core.RootTuple()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	lookup := directory.Lookup{
+		AppID:       core.appfile.ID,
+		Infra:       tuple.Infra,
+		InfraFlavor: tuple.InfraFlavor,
+	}
+
+	// The app records the build in the directory
+	err = coreConfig.Directory.PutBuild(&directory.Build{
+		Lookup:   lookup,
+		Artifact: map[string]string{"ami": "ami-123"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := core.Build(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	build, err := coreConfig.Directory.GetBuild(&directory.Build{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(build.Signature) == 0 {
+		t.Fatal("build should be signed")
+	}
+
+	// Deploying the signed build works
+	if err := core.Deploy(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !appMock.DeployCalled {
+		t.Fatal("deploy should be called")
+	}
+
+	// Tamper with the artifact
+	appMock.DeployCalled = false
+	build.Artifact["ami"] = "ami-456"
+	if err := coreConfig.Directory.PutBuild(build); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Deploy(nil); err == nil {
+		t.Fatal("should error")
+	}
+	if appMock.DeployCalled {
+		t.Fatal("deploy should not be called")
+	}
+}
+
+// testSigner is a Signer that uses an HMAC.
+type testSigner struct {
+	Key []byte
+}
+
+func (s *testSigner) Sign(data []byte) ([]byte, error) {
+	h := hmac.New(sha256.New, s.Key)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+func (s *testSigner) Verify(data, signature []byte) error {
+	expected, _ := s.Sign(data)
+	if !bytes.Equal(expected, signature) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
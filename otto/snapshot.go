@@ -0,0 +1,231 @@
+package otto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/directory"
+)
+
+// snapshotVersion is the version of the snapshot format written by
+// Snapshot. Restore only reads snapshots of this version.
+const snapshotVersion = 1
+
+// snapshot is the format of the state written by Snapshot.
+type snapshot struct {
+	Version int                 `json:"version"`
+	Devs    []*directory.Dev    `json:"devs"`
+	Builds  []*directory.Build  `json:"builds"`
+	Deploys []*directory.Deploy `json:"deploys"`
+	Infras  []*directory.Infra  `json:"infras"`
+
+	// BuildHistory are the earlier builds kept for the same lookups as
+	// Builds. Restore stores them before Builds, so that the latest
+	// builds stay the latest.
+	BuildHistory []*directory.Build `json:"build_history,omitempty"`
+
+	// Blobs are the stored binary data, such as the Terraform state of
+	// the records above, keyed by blob key.
+	Blobs map[string][]byte `json:"blobs"`
+}
+
+// Snapshot writes the state stored in the directory for this Appfile
+// to w: the records of the dev environments and deploys of every
+// application in the dependency graph, their builds for every
+// infrastructure in the Appfile, including promoted builds and the
+// earlier builds the directory keeps, the records of the active
+// infrastructure and its foundations, and the data stored for them.
+// The snapshot can be loaded into another directory backend with
+// Restore, which is useful for backups or to move the state to a
+// different machine or backend.
+func (c *Core) Snapshot(w io.Writer) error {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+
+	infra := c.appfile.ActiveInfrastructure()
+	if infra == nil {
+		return errInfraNotFound(c.appfile, c.appfile.Project.Infrastructure)
+	}
+
+	result := &snapshot{
+		Version: snapshotVersion,
+		Blobs:   make(map[string][]byte),
+	}
+	var ids []string
+
+	// Builds are shared by the infrastructures of the same type and
+	// flavor, and promoted builds may also be scoped to one of them by
+	// name, so look for both for every infrastructure.
+	var buildLookups []directory.Lookup
+	seen := make(map[directory.Lookup]bool)
+	for _, i := range c.appfile.Infrastructure {
+		for _, lookup := range []directory.Lookup{
+			directory.Lookup{Infra: i.Type, InfraFlavor: i.Flavor},
+			directory.Lookup{Infra: i.Type, InfraFlavor: i.Flavor, InfraName: i.Name},
+		} {
+			if !seen[lookup] {
+				seen[lookup] = true
+				buildLookups = append(buildLookups, lookup)
+			}
+		}
+	}
+	lister, _ := c.dir.(directory.BuildLister)
+
+	// The records of every application
+	for _, raw := range c.appfileCompiled.Graph.Vertices() {
+		id := raw.(*appfile.CompiledGraphVertex).File.ID
+		lookup := directory.Lookup{
+			AppID: id, Infra: infra.Type, InfraFlavor: infra.Flavor}
+
+		dev, err := c.dir.GetDev(&directory.Dev{Lookup: directory.Lookup{AppID: id}})
+		if err != nil {
+			return fmt.Errorf("Error reading dev record: %s", err)
+		}
+		if dev != nil {
+			result.Devs = append(result.Devs, dev)
+			ids = append(ids, dev.ID)
+		}
+
+		for _, buildLookup := range buildLookups {
+			buildLookup.AppID = id
+			build, err := c.dir.GetBuild(&directory.Build{Lookup: buildLookup})
+			if err != nil {
+				return fmt.Errorf("Error reading build record: %s", err)
+			}
+			if build == nil {
+				continue
+			}
+			result.Builds = append(result.Builds, build)
+			ids = append(ids, build.ID)
+
+			if lister == nil {
+				continue
+			}
+			history, err := lister.ListBuilds(&directory.Build{Lookup: buildLookup})
+			if err != nil {
+				return fmt.Errorf("Error reading build history: %s", err)
+			}
+			for _, b := range history {
+				if b.ID != build.ID {
+					result.BuildHistory = append(result.BuildHistory, b)
+					ids = append(ids, b.ID)
+				}
+			}
+		}
+
+		deploy, err := c.dir.GetDeploy(&directory.Deploy{Lookup: lookup})
+		if err != nil {
+			return fmt.Errorf("Error reading deploy record: %s", err)
+		}
+		if deploy != nil {
+			result.Deploys = append(result.Deploys, deploy)
+			ids = append(ids, deploy.ID)
+		}
+	}
+
+	// The records of the infrastructure and its foundations
+	lookups := []directory.Lookup{directory.Lookup{Infra: infra.Name}}
+	for _, f := range infra.Foundations {
+		lookups = append(lookups, directory.Lookup{
+			Infra: infra.Type, Foundation: f.Name})
+	}
+	for _, lookup := range lookups {
+		record, err := c.dir.GetInfra(&directory.Infra{Lookup: lookup})
+		if err != nil {
+			return fmt.Errorf("Error reading infrastructure record: %s", err)
+		}
+		if record != nil {
+			result.Infras = append(result.Infras, record)
+			ids = append(ids, record.ID)
+		}
+	}
+
+	// The data stored for the records, such as Terraform state, is
+	// keyed by their IDs.
+	for _, key := range append(ids, c.compileHashKey(), c.auditKey(), c.devHandleKey()) {
+		blob, err := c.dir.GetBlob(key)
+		if err != nil {
+			return fmt.Errorf("Error reading blob %s: %s", key, err)
+		}
+		if blob == nil {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(blob.Data)
+		blob.Close()
+		if err != nil {
+			return fmt.Errorf("Error reading blob %s: %s", key, err)
+		}
+
+		result.Blobs[key] = data
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(result)
+}
+
+// Restore loads a snapshot written by Snapshot into the directory,
+// overwriting any records that already exist for the same applications
+// and infrastructure.
+func (c *Core) Restore(r io.Reader) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	if err := c.checkDirectory(true); err != nil {
+		return err
+	}
+
+	var s snapshot
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&s); err != nil {
+		return fmt.Errorf("Error reading snapshot: %s", err)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf(
+			"The snapshot has version %d, but this version of Otto only\n"+
+				"supports version %d. Please restore it with the version of\n"+
+				"Otto that created it.",
+			s.Version, snapshotVersion)
+	}
+
+	for _, d := range s.Devs {
+		if err := c.dir.PutDev(d); err != nil {
+			return fmt.Errorf("Error restoring dev record: %s", err)
+		}
+	}
+	for _, b := range append(s.BuildHistory, s.Builds...) {
+		if err := c.dir.PutBuild(b); err != nil {
+			return fmt.Errorf("Error restoring build record: %s", err)
+		}
+	}
+	for _, d := range s.Deploys {
+		if err := c.dir.PutDeploy(d); err != nil {
+			return fmt.Errorf("Error restoring deploy record: %s", err)
+		}
+	}
+	for _, i := range s.Infras {
+		if err := c.dir.PutInfra(i); err != nil {
+			return fmt.Errorf("Error restoring infrastructure record: %s", err)
+		}
+	}
+	for key, data := range s.Blobs {
+		err := c.dir.PutBlob(key, &directory.BlobData{
+			Data: bytes.NewReader(data),
+		})
+		if err != nil {
+			return fmt.Errorf("Error restoring blob %s: %s", key, err)
+		}
+	}
+
+	return nil
+}
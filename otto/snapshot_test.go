@@ -0,0 +1,188 @@
+package otto
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/appfile"
+	"github.com/hashicorp/otto/directory"
+	"github.com/hashicorp/otto/infrastructure"
+	"github.com/hashicorp/otto/ui"
+)
+
+func TestCoreSnapshot(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Store some state
+	infra := coreConfig.Appfile.File.ActiveInfrastructure()
+	build := &directory.Build{
+		Lookup: directory.Lookup{
+			AppID:       coreConfig.Appfile.File.ID,
+			Infra:       infra.Type,
+			InfraFlavor: infra.Flavor,
+		},
+		Artifact: map[string]string{"foo": "bar"},
+	}
+	if err := coreConfig.Directory.PutBuild(build); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	record := &directory.Infra{
+		Lookup: directory.Lookup{Infra: infra.Name},
+		State:  directory.InfraStateReady,
+	}
+	if err := coreConfig.Directory.PutInfra(record); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := coreConfig.Directory.PutBlob(record.ID, &directory.BlobData{
+		Data: strings.NewReader("state"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := core.Snapshot(&buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Restore it into an empty directory
+	otherConfig := TestCoreConfig(t)
+	otherConfig.Appfile = coreConfig.Appfile
+	other := testCore(t, otherConfig)
+	if err := other.Restore(&buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual, err := otherConfig.Directory.GetBuild(&directory.Build{Lookup: build.Lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.ID != build.ID || actual.Artifact["foo"] != "bar" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	actualInfra, err := otherConfig.Directory.GetInfra(&directory.Infra{Lookup: record.Lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !actualInfra.IsReady() {
+		t.Fatalf("bad: %#v", actualInfra)
+	}
+
+	blob, err := otherConfig.Directory.GetBlob(record.ID)
+	if err != nil || blob == nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer blob.Close()
+	data, err := ioutil.ReadAll(blob.Data)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "state" {
+		t.Fatalf("bad: %s", data)
+	}
+}
+
+func TestCoreSnapshot_promoted(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.Ui = &ui.Mock{InputResult: "foo"}
+	coreConfig.Appfile.File.Infrastructure = append(
+		coreConfig.Appfile.File.Infrastructure, &appfile.Infrastructure{
+			Name:   "production",
+			Type:   "test",
+			Flavor: "test",
+		})
+	promoter := &testPromoter{
+		Mock:     new(infrastructure.Mock),
+		Artifact: map[string]string{"image": "prod-image"},
+	}
+	coreConfig.Infrastructures["test"] = func() (infrastructure.Infrastructure, error) {
+		return promoter, nil
+	}
+	core := testCore(t, coreConfig)
+
+	// Two builds, the latest of which is promoted
+	lookup := directory.Lookup{
+		AppID:       core.appfile.ID,
+		Infra:       "test",
+		InfraFlavor: "test",
+	}
+	first := &directory.Build{
+		Lookup:   lookup,
+		Artifact: map[string]string{"image": "first-image"},
+	}
+	if err := coreConfig.Directory.PutBuild(first); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	second := &directory.Build{
+		Lookup:   lookup,
+		Artifact: map[string]string{"image": "second-image"},
+	}
+	if err := coreConfig.Directory.PutBuild(second); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := core.Promote(second.ID, "production"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := core.Snapshot(&buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Restore it into an empty directory
+	otherConfig := TestCoreConfig(t)
+	otherConfig.Appfile = coreConfig.Appfile
+	other := testCore(t, otherConfig)
+	if err := other.Restore(&buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The promoted build
+	scoped := lookup
+	scoped.InfraName = "production"
+	actual, err := otherConfig.Directory.GetBuild(&directory.Build{Lookup: scoped})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.Artifact["image"] != "prod-image" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The latest build is still the latest
+	actual, err = otherConfig.Directory.GetBuild(&directory.Build{Lookup: lookup})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.ID != second.ID {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The earlier build is kept
+	actual, err = otherConfig.Directory.GetBuild(
+		&directory.Build{Lookup: lookup, ID: first.ID})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual == nil || actual.Artifact["image"] != "first-image" {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestCoreRestore_version(t *testing.T) {
+	core := TestCore(t, &TestCoreOpts{Path: testPath("basic", "Appfile")})
+
+	err := core.Restore(strings.NewReader(`{"version": 42}`))
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "version 42") {
+		t.Fatalf("bad: %s", err)
+	}
+}
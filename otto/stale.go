@@ -0,0 +1,92 @@
+package otto
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/hashicorp/otto/appfile"
+)
+
+// appfileSource is the source Appfile that the Appfile of the Core was
+// compiled from, and its hash at the time.
+type appfileSource struct {
+	Path string
+	Hash string
+}
+
+// sourceOf returns the source of the compiled Appfile. For a Core with
+// a Root, this is the source of the main Appfile, which is the one that
+// is compiled.
+func sourceOf(compiled *appfile.Compiled) appfileSource {
+	return appfileSource{
+		Path: compiled.File.Path,
+		Hash: compiled.SourceHash,
+	}
+}
+
+// IsStale returns true if the source Appfile changed since the Appfile
+// of this Core was compiled, or was removed, so that operations would
+// use the old version of it. The source is the Appfile in the ProjectDir
+// if one is configured, such as when the compiled Appfile was moved,
+// and the file that was compiled otherwise. It is never stale if the
+// hash of the source wasn't recorded, such as for a default Appfile.
+//
+// Operations warn about a stale Appfile when they start, or fail with
+// CoreConfig.RequireFreshAppfile.
+func (c *Core) IsStale() (bool, error) {
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	return c.isStale()
+}
+
+func (c *Core) isStale() (bool, error) {
+	if c.source.Hash == "" {
+		return false, nil
+	}
+
+	path := c.source.Path
+	if c.projectDir != "" {
+		name := "Appfile"
+		if path != "" {
+			name = filepath.Base(path)
+		}
+
+		path = filepath.Join(c.projectDir, name)
+	}
+
+	hash, err := appfile.SourceHash(path)
+	if err != nil {
+		return false, fmt.Errorf("Error reading Appfile %s: %s", path, err)
+	}
+
+	return hash != c.source.Hash, nil
+}
+
+// checkStale warns if the Appfile is stale, or returns an error if a
+// fresh one is required. Failing to read the source only results in a
+// warning in the log, since the operation doesn't need it.
+func (c *Core) checkStale() error {
+	stale, err := c.isStale()
+	if err != nil {
+		log.Printf("[WARN] error checking whether the Appfile is stale: %s", err)
+		return nil
+	}
+	if !stale {
+		return nil
+	}
+
+	if c.requireFresh {
+		return fmt.Errorf(
+			"The Appfile changed since it was compiled. Compile it again\n" +
+				"with `otto compile` so that the changes are used.")
+	}
+
+	c.ui.Message(
+		"[yellow]WARNING: The Appfile changed since it was compiled. Otto\n" +
+			"will use the version that was compiled. Compile it again with\n" +
+			"`otto compile` so that the changes are used.")
+	c.warn(WarningSourceCore, "", "The Appfile changed since it was compiled")
+	return nil
+}
@@ -0,0 +1,73 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoreIsStale(t *testing.T) {
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	for _, name := range []string{"Appfile", ".ottoid"} {
+		err := copyKeptFile(testPath("basic", name), filepath.Join(td, "basic", name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	path := filepath.Join(td, "basic", "Appfile")
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, path)
+	if coreConfig.Appfile.SourceHash == "" {
+		t.Fatal("source hash should be recorded")
+	}
+	core := testCore(t, coreConfig)
+
+	stale, err := core.IsStale()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stale {
+		t.Fatal("shouldn't be stale")
+	}
+
+	// Edit the Appfile
+	err = ioutil.WriteFile(path, []byte("application {\n  name = \"edited\"\n}\n"), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	stale, err = core.IsStale()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !stale {
+		t.Fatal("should be stale")
+	}
+
+	// Operations warn by default
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(core.Warnings()) != 1 {
+		t.Fatalf("bad: %#v", core.Warnings())
+	}
+
+	// Or fail if a fresh Appfile is required
+	coreConfig.RequireFreshAppfile = true
+	core = testCore(t, coreConfig)
+	if err := core.Compile(); err == nil {
+		t.Fatal("should error")
+	}
+
+	// The source is looked up in the ProjectDir if there is one
+	coreConfig.ProjectDir = testPath("basic")
+	core = testCore(t, coreConfig)
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,76 @@
+package otto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTempGracePeriod is the CoreConfig.TempGracePeriod used if none
+// is configured.
+const DefaultTempGracePeriod = 24 * time.Hour
+
+// tempDirPrefix starts the names of the temporary directories the Core
+// creates, which are named "otto-<appfile ID>-<purpose>-<random>" so that
+// those left behind by this project can be found and removed.
+const tempDirPrefix = "otto-"
+
+// tempDir creates a temporary directory for the given purpose, such as
+// "drift", in the configured TempDir. The caller must remove it.
+func (c *Core) tempDir(purpose string) (string, error) {
+	return ioutil.TempDir(c.tempRoot(), fmt.Sprintf(
+		"%s%s-%s-", tempDirPrefix, c.appfile.ID, purpose))
+}
+
+// tempRoot returns the directory that temporary directories are made in.
+func (c *Core) tempRoot() string {
+	if c.tempDirRoot != "" {
+		return c.tempDirRoot
+	}
+
+	return os.TempDir()
+}
+
+// sweepTempDirs removes the temporary directories of this project that
+// are older than the grace period. These are left behind when Otto is
+// interrupted before it cleans up after itself. Errors only result in
+// warnings in the log, since they don't affect the operation.
+func (c *Core) sweepTempDirs() {
+	grace := c.tempGrace
+	if grace == 0 {
+		grace = DefaultTempGracePeriod
+	}
+	if grace < 0 {
+		return
+	}
+
+	root := c.tempRoot()
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		log.Printf("[WARN] error listing temporary directories in %s: %s", root, err)
+		return
+	}
+
+	prefix := fmt.Sprintf("%s%s-", tempDirPrefix, c.appfile.ID)
+	cutoff := c.clock.Now().Add(-grace)
+	for _, info := range infos {
+		if !info.IsDir() || !strings.HasPrefix(info.Name(), prefix) {
+			continue
+		}
+		if !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(root, info.Name())
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("[WARN] error removing stale temporary directory %s: %s", path, err)
+			continue
+		}
+
+		log.Printf("[INFO] removed stale temporary directory: %s", path)
+	}
+}
@@ -0,0 +1,58 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoreCompile_sweepTempDirs(t *testing.T) {
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.TempDir = td
+	coreConfig.TempGracePeriod = time.Hour
+	TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	// Only the directories of this project that are old are removed
+	id := coreConfig.Appfile.File.ID
+	recent := "otto-" + id + "-drift-2"
+	dirs := map[string]bool{
+		"otto-" + id + "-drift-1":    true,
+		recent:                       false,
+		"otto-other-id-drift-1":      false,
+		"unrelated-" + id + "-drift": false,
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for name := range dirs {
+		path := filepath.Join(td, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if name != recent {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+	}
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for name, stale := range dirs {
+		_, err := os.Stat(filepath.Join(td, name))
+		if stale != os.IsNotExist(err) {
+			t.Fatalf("bad: %s %v", name, err)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package otto
 import (
 	"io/ioutil"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/otto/app"
 	"github.com/hashicorp/otto/directory"
@@ -74,3 +76,24 @@ func TestCoreConfig(t TestT) *CoreConfig {
 
 	return config
 }
+
+// TestClock is a Clock for tests. The time only changes when it is set
+// or advanced, so time-based behavior can be tested deterministically.
+type TestClock struct {
+	sync.Mutex
+
+	T time.Time
+}
+
+func (c *TestClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.T
+}
+
+// Advance moves the clock forward by d.
+func (c *TestClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.T = c.T.Add(d)
+}
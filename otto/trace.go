@@ -0,0 +1,137 @@
+package otto
+
+import (
+	"sync"
+)
+
+// The names of the spans the Core starts.
+const (
+	SpanCompile      = "otto.compile"
+	SpanCompileInfra = "otto.compile.infra"
+	SpanVertex       = "otto.vertex"
+	SpanBuild        = "otto.build"
+	SpanDev          = "otto.dev"
+	SpanCreds        = "otto.creds"
+)
+
+// The attributes of the spans the Core starts.
+const (
+	SpanAttrAppfileID = "otto.appfile_id"
+	SpanAttrApp       = "otto.app"
+	SpanAttrTuple     = "otto.tuple"
+	SpanAttrInfra     = "otto.infra"
+	SpanAttrCacheHit  = "otto.cache_hit"
+)
+
+// Tracer creates spans around the major operations of the Core, such as
+// Compile and every application it walks, to integrate Otto with a
+// tracing system. Otto doesn't depend on any tracing library, so an
+// adapter is needed, for example one that calls Start on an
+// OpenTelemetry tracer with the context of the parent span.
+//
+// The graph is walked in parallel, so StartSpan may be called
+// concurrently.
+type Tracer interface {
+	// StartSpan starts a span with the given name and attributes. parent
+	// is the span it is part of, or nil if it is a top-level operation.
+	StartSpan(parent Span, name string, attrs map[string]string) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span, such as whether a
+	// cached result was used.
+	SetAttribute(key, value string)
+
+	// End ends the span. err is the error the operation failed with, if
+	// it failed.
+	End(err error)
+}
+
+// NoopTracer is the Tracer used if none is configured. Its spans do
+// nothing.
+type NoopTracer struct{}
+
+func (NoopTracer) StartSpan(Span, string, map[string]string) Span {
+	return noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, string) {}
+func (noopSpan) End(error)                   {}
+
+// startSpan starts the span of an operation that modifies the state, as
+// part of the current one. The spans started until end is called are
+// part of it. This must only be called with the lock held and not while
+// the graph is walked.
+func (c *Core) startSpan(name string, attrs map[string]string) (span Span, end func(error)) {
+	c.spanLock.Lock()
+	defer c.spanLock.Unlock()
+
+	parent := c.span
+	span = c.tracer.StartSpan(parent, name, c.spanAttrs(attrs))
+	c.span = span
+	return span, func(err error) {
+		c.spanLock.Lock()
+		c.span = parent
+		c.spanLock.Unlock()
+
+		span.End(err)
+	}
+}
+
+// startChildSpan starts a span as part of the current operation without
+// making it the parent of other spans. This can be called concurrently.
+func (c *Core) startChildSpan(name string, attrs map[string]string) Span {
+	c.spanLock.Lock()
+	parent := c.span
+	c.spanLock.Unlock()
+
+	return c.tracer.StartSpan(parent, name, c.spanAttrs(attrs))
+}
+
+// spanAttrs adds the attributes that every span has to attrs.
+func (c *Core) spanAttrs(attrs map[string]string) map[string]string {
+	result := map[string]string{SpanAttrAppfileID: c.appfile.ID}
+	for k, v := range attrs {
+		result[k] = v
+	}
+
+	return result
+}
+
+// vertexSpans are the spans of the applications being walked, by Otto
+// ID, so that the walk callbacks can add attributes to them. It is safe
+// for concurrent use.
+type vertexSpans struct {
+	sync.Mutex
+	spans map[string]Span
+}
+
+func (s *vertexSpans) put(id string, span Span) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.spans == nil {
+		s.spans = make(map[string]Span)
+	}
+	if span == nil {
+		delete(s.spans, id)
+		return
+	}
+	s.spans[id] = span
+}
+
+// get returns the span of the app with the given ID, which does nothing
+// if it isn't being walked.
+func (s *vertexSpans) get(id string) Span {
+	s.Lock()
+	defer s.Unlock()
+
+	if span, ok := s.spans[id]; ok {
+		return span
+	}
+
+	return noopSpan{}
+}
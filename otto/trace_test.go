@@ -0,0 +1,123 @@
+package otto
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreTracer(t *testing.T) {
+	tracer := new(testTracer)
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	coreConfig.Tracer = tracer
+	appMock := &testDevDepFiles{Mock: TestApp(t, TestAppTuple, coreConfig)}
+	coreConfig.Apps[TestAppTuple] = func() (app.App, error) {
+		return appMock, nil
+	}
+	core := testCore(t, coreConfig)
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	compile := tracer.Find(SpanCompile, "")
+	if compile == nil || compile.Parent != nil || !compile.Ended {
+		t.Fatalf("bad: %#v", compile)
+	}
+	if compile.Attrs[SpanAttrAppfileID] != core.appfile.ID {
+		t.Fatalf("bad: %#v", compile.Attrs)
+	}
+	if s := tracer.Find(SpanCompileInfra, ""); s == nil || s.Parent != compile {
+		t.Fatalf("bad: %#v", s)
+	}
+	for _, name := range []string{"root", "child"} {
+		s := tracer.Find(SpanVertex, name)
+		if s == nil || s.Parent != compile || !s.Ended {
+			t.Fatalf("bad: %#v", s)
+		}
+		if s.Attrs[SpanAttrTuple] != TestAppTuple.String() {
+			t.Fatalf("bad: %#v", s.Attrs)
+		}
+	}
+
+	// The dev dependency is built once and then cached
+	for _, hit := range []string{"false", "true"} {
+		tracer.Reset()
+		if err := core.Dev(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		dev := tracer.Find(SpanDev, "")
+		if dev == nil || !dev.Ended {
+			t.Fatalf("bad: %#v", dev)
+		}
+		s := tracer.Find(SpanVertex, "child")
+		if s == nil || s.Parent != dev || s.Attrs[SpanAttrCacheHit] != hit {
+			t.Fatalf("bad: %#v", s)
+		}
+	}
+}
+
+// testTracer is a Tracer that records the spans.
+type testTracer struct {
+	sync.Mutex
+	Spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(parent Span, name string, attrs map[string]string) Span {
+	t.Lock()
+	defer t.Unlock()
+
+	s := &testSpan{Name: name, Attrs: attrs}
+	if parent != nil {
+		s.Parent = parent.(*testSpan)
+	}
+	t.Spans = append(t.Spans, s)
+	return s
+}
+
+// Find returns the span with the given name and, if app isn't blank,
+// for the given app.
+func (t *testTracer) Find(name, app string) *testSpan {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, s := range t.Spans {
+		if s.Name == name && (app == "" || s.Attrs[SpanAttrApp] == app) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (t *testTracer) Reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.Spans = nil
+}
+
+type testSpan struct {
+	sync.Mutex
+
+	Name   string
+	Parent *testSpan
+	Attrs  map[string]string
+	Ended  bool
+	Err    error
+}
+
+func (s *testSpan) SetAttribute(key, value string) {
+	s.Lock()
+	defer s.Unlock()
+	s.Attrs[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.Ended = true
+	s.Err = err
+}
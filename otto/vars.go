@@ -0,0 +1,139 @@
+package otto
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+)
+
+// VarSource is a source of variable values that are given to the app,
+// infrastructure, and foundation implementations through their
+// contexts. This lets values that differ between environments be kept
+// out of the Appfile.
+type VarSource interface {
+	Vars() (map[string]string, error)
+}
+
+// VarsFile is a VarSource that reads the variables from an HCL or JSON
+// file of keys and string values, such as:
+//
+//	region = "us-east-1"
+//
+// If Optional is true, a file that doesn't exist has no variables.
+type VarsFile struct {
+	Path     string
+	Optional bool
+}
+
+func (s *VarsFile) Vars() (map[string]string, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		if s.Optional && os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result map[string]string
+	if err := hcl.Decode(&result, string(data)); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", s.Path, err)
+	}
+
+	return result, nil
+}
+
+// VarsEnv is a VarSource that reads the variables from the environment
+// variables that start with Prefix, such as "OTTO_VAR_". The prefix is
+// removed from the names of the variables.
+type VarsEnv struct {
+	Prefix string
+}
+
+func (s *VarsEnv) Vars() (map[string]string, error) {
+	result := make(map[string]string)
+	for _, kv := range os.Environ() {
+		idx := strings.Index(kv, "=")
+		if idx == -1 || !strings.HasPrefix(kv[:idx], s.Prefix) {
+			continue
+		}
+
+		if k := kv[len(s.Prefix):idx]; k != "" {
+			result[k] = kv[idx+1:]
+		}
+	}
+
+	return result, nil
+}
+
+// VarsFunc is a VarSource that calls a function for the variables.
+type VarsFunc func() (map[string]string, error)
+
+func (f VarsFunc) Vars() (map[string]string, error) {
+	return f()
+}
+
+// vars returns the variables from the configured sources, resolving
+// them the first time they're needed.
+func (c *Core) vars() (map[string]string, error) {
+	c.varsLock.Lock()
+	defer c.varsLock.Unlock()
+
+	if c.varsCache == nil {
+		result, err := resolveVars(c.varSources, c.requiredVars)
+		if err != nil {
+			return nil, err
+		}
+
+		c.varsCache = result
+	}
+
+	return c.varsCache, nil
+}
+
+// resetVars makes the variables be resolved again the next time they're
+// needed, so that each compilation sees the current values.
+func (c *Core) resetVars() {
+	c.varsLock.Lock()
+	defer c.varsLock.Unlock()
+
+	c.varsCache = nil
+}
+
+// resolveVars merges the variables of the sources in order, so later
+// sources override the values of earlier ones, and verifies that every
+// required variable has a value.
+func resolveVars(sources []VarSource, required []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for i, s := range sources {
+		vars, err := s.Vars()
+		if err != nil {
+			return nil, fmt.Errorf(
+				"Error loading variables from source %d: %s", i+1, err)
+		}
+
+		for k, v := range vars {
+			result[k] = v
+		}
+	}
+
+	var missing []string
+	for _, k := range required {
+		if _, ok := result[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf(
+			"The following required variables don't have a value: %s\n\n"+
+				"Please set them in one of the configured variable sources.",
+			strings.Join(missing, ", "))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package otto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+)
+
+func TestCoreCompile_vars(t *testing.T) {
+	td, err := ioutil.TempDir("", "otto")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "vars.hcl")
+	data := "region = \"us-east-1\"\nsize = \"small\"\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("basic", "Appfile"))
+	coreConfig.VarSources = []VarSource{
+		&VarsFile{Path: path},
+		&VarsFile{Path: filepath.Join(td, "missing"), Optional: true},
+		VarsFunc(func() (map[string]string, error) {
+			return map[string]string{"size": "large"}, nil
+		}),
+	}
+	coreConfig.RequiredVars = []string{"region"}
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	core := testCore(t, coreConfig)
+
+	var actual map[string]string
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		actual = ctx.Vars
+		return nil, nil
+	}
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Later sources win
+	expected := map[string]string{"region": "us-east-1", "size": "large"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// A required variable without a value fails before compiling
+	coreConfig.RequiredVars = []string{"region", "zone"}
+	appMock.CompileCalled = false
+	core = testCore(t, coreConfig)
+	err = core.Compile()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "zone") {
+		t.Fatalf("bad: %s", err)
+	}
+	if appMock.CompileCalled {
+		t.Fatal("compile should not be called")
+	}
+}
+
+func TestVarsEnv(t *testing.T) {
+	defer os.Setenv("OTTO_TEST_VAR_FOO", os.Getenv("OTTO_TEST_VAR_FOO"))
+	os.Setenv("OTTO_TEST_VAR_FOO", "bar")
+
+	vars, err := (&VarsEnv{Prefix: "OTTO_TEST_VAR_"}).Vars()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(vars, map[string]string{"FOO": "bar"}) {
+		t.Fatalf("bad: %#v", vars)
+	}
+}
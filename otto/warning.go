@@ -0,0 +1,61 @@
+package otto
+
+import (
+	"log"
+)
+
+// The sources of a Warning.
+const (
+	WarningSourceCore       = "core"
+	WarningSourceApp        = "app"
+	WarningSourceInfra      = "infra"
+	WarningSourceFoundation = "foundation"
+)
+
+// Warning is a problem found during an operation that didn't cause it
+// to fail, such as deprecated configuration or a soft limit that was
+// reached.
+type Warning struct {
+	// Source is what reported the warning, one of the WarningSource
+	// constants.
+	Source string
+
+	// Vertex is the name of the application in the dependency graph
+	// that the warning is about. This is empty if it isn't about a
+	// single application.
+	Vertex string
+
+	Message string
+}
+
+// Warnings returns the warnings of the last operation, such as Compile,
+// in the order they were reported. They're kept until the next
+// operation starts. This is safe to call while an operation runs.
+func (c *Core) Warnings() []Warning {
+	c.warningsLock.Lock()
+	defer c.warningsLock.Unlock()
+
+	result := make([]Warning, len(c.warnings))
+	copy(result, c.warnings)
+	return result
+}
+
+// warn records a warning for the current operation.
+func (c *Core) warn(source, vertex, msg string) {
+	log.Printf("[WARN] %s: %s", source, msg)
+
+	c.warningsLock.Lock()
+	defer c.warningsLock.Unlock()
+	c.warnings = append(c.warnings, Warning{
+		Source:  source,
+		Vertex:  vertex,
+		Message: msg,
+	})
+}
+
+// resetWarnings clears the warnings when a new operation starts.
+func (c *Core) resetWarnings() {
+	c.warningsLock.Lock()
+	defer c.warningsLock.Unlock()
+	c.warnings = nil
+}
@@ -0,0 +1,49 @@
+package otto
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/otto/app"
+	"github.com/hashicorp/otto/infrastructure"
+)
+
+func TestCoreWarnings(t *testing.T) {
+	coreConfig := TestCoreConfig(t)
+	coreConfig.Appfile = TestAppfile(t, testPath("deps", "Appfile"))
+	appMock := TestApp(t, TestAppTuple, coreConfig)
+	infraMock := TestInfra(t, "test", coreConfig)
+	infraMock.CompileResult = &infrastructure.CompileResult{
+		Warnings: []string{"infra is old"},
+	}
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		if ctx.Appfile.Application.Name == "root" {
+			return nil, nil
+		}
+
+		return &app.CompileResult{Warnings: []string{"deprecated"}}, nil
+	}
+	core := testCore(t, coreConfig)
+
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Warning{
+		{Source: WarningSourceInfra, Message: "infra is old"},
+		{Source: WarningSourceApp, Vertex: "child", Message: "deprecated"},
+	}
+	if actual := core.Warnings(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The next operation starts fresh
+	infraMock.CompileResult = nil
+	appMock.CompileFunc = nil
+	if err := core.Compile(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := core.Warnings(); len(actual) != 0 {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
@@ -135,6 +135,35 @@ func TestApp_compileUi(t *testing.T) {
 	}
 }
 
+func TestApp_compileCredRead(t *testing.T) {
+	client, server, streams := testNewClientServer(t)
+	defer streams.Close()
+	defer client.Close()
+
+	appMock := server.AppFunc().(*app.Mock)
+	appReal, err := client.App()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	appMock.CompileFunc = func(ctx *app.Context) (*app.CompileResult, error) {
+		ctx.Cred("token")
+		return nil, nil
+	}
+
+	var read []string
+	ctx := new(app.Context)
+	ctx.Ui = new(ui.Mock)
+	ctx.CredRead = func(key string) { read = append(read, key) }
+
+	if _, err := appReal.Compile(ctx); err != nil {
+		t.Fatalf("bad: %#v", err)
+	}
+	if !reflect.DeepEqual(read, []string{"token"}) {
+		t.Fatalf("bad: %#v", read)
+	}
+}
+
 func TestApp_build(t *testing.T) {
 	client, server, streams := testNewClientServer(t)
 	defer streams.Close()
@@ -14,6 +14,9 @@ import (
 type ContextSharedArgs struct {
 	DirectoryId uint32
 	UiId        uint32
+
+	// CredReadId is zero if the context has no CredRead.
+	CredReadId uint32
 }
 
 func connectContext(
@@ -46,6 +49,21 @@ func connectContext(
 		Name:   "Ui",
 	}
 
+	// Setup CredRead
+	if args.CredReadId != 0 {
+		conn, err = broker.Dial(args.CredReadId)
+		if err != nil {
+			return closer, err
+		}
+		client = rpc.NewClient(conn)
+		closer.Closers = append(closer.Closers, client)
+		reader := &CredReader{
+			Client: client,
+			Name:   "CredRead",
+		}
+		ctx.CredRead = reader.Read
+	}
+
 	return closer, nil
 }
 
@@ -65,6 +83,15 @@ func serveContext(broker *muxBroker, ctx *context.Shared, args *ContextSharedArg
 	})
 	args.UiId = id
 
+	// Serve CredRead
+	if ctx.CredRead != nil {
+		id = broker.NextId()
+		go acceptAndServe(broker, id, "CredRead", &CredReaderServer{
+			CredRead: ctx.CredRead,
+		})
+		args.CredReadId = id
+	}
+
 	// Set the context fields to nil so that they aren't sent over the
 	// network (Go will just panic if we didn't do this).
 	ctx.Directory = nil
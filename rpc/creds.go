@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"log"
+	"net/rpc"
+)
+
+// CredReader is the client side of context.Shared.CredRead, which tells
+// the core which credentials the plugin read.
+type CredReader struct {
+	Client *rpc.Client
+	Name   string
+}
+
+func (r *CredReader) Read(key string) {
+	err := r.Client.Call(r.Name+".Read", key, new(interface{}))
+	if err != nil {
+		log.Printf("[ERR] rpc/creds: %s", err)
+	}
+}
+
+// CredReaderServer is a net/rpc compatible structure for serving
+// context.Shared.CredRead. This should not be used directly.
+type CredReaderServer struct {
+	CredRead func(key string)
+}
+
+func (s *CredReaderServer) Read(key string, reply *interface{}) error {
+	s.CredRead(key)
+	*reply = new(struct{})
+	return nil
+}
@@ -4,8 +4,12 @@ package rpc
 // across RPC channels. Since "error" is an interface, we can't always
 // gob-encode the underlying structure. This is a valid error interface
 // implementer that we will push across.
+//
+// If the error carries the exit code of a subprocess (it has an
+// ExitCode() int method, like context.ExitError), the code is kept.
 type BasicError struct {
-	Message string
+	Message    string
+	ExitStatus int
 }
 
 func NewBasicError(err error) *BasicError {
@@ -13,13 +17,26 @@ func NewBasicError(err error) *BasicError {
 		return nil
 	}
 
-	return &BasicError{err.Error()}
+	result := &BasicError{Message: err.Error()}
+	if ec, ok := err.(interface {
+		ExitCode() int
+	}); ok {
+		result.ExitStatus = ec.ExitCode()
+	}
+
+	return result
 }
 
 func (e *BasicError) Error() string {
 	return e.Message
 }
 
+// ExitCode returns the exit code of the original error, or zero if it
+// didn't have one.
+func (e *BasicError) ExitCode() int {
+	return e.ExitStatus
+}
+
 // ErrorResponse is a basic response structure that can be used with
 // the RPC layer to only return an error.
 type ErrorResponse struct {
@@ -3,6 +3,8 @@ package rpc
 import (
 	"errors"
 	"testing"
+
+	"github.com/hashicorp/otto/context"
 )
 
 func TestBasicError_ImplementsError(t *testing.T) {
@@ -24,3 +26,15 @@ func TestNewBasicError_nil(t *testing.T) {
 		t.Fatalf("bad: %#v", r)
 	}
 }
+
+func TestNewBasicError_exitCode(t *testing.T) {
+	r := NewBasicError(&context.ExitError{Code: 3, Err: errors.New("foo")})
+	if r.ExitCode() != 3 || r.Error() != "foo" {
+		t.Fatalf("bad: %#v", r)
+	}
+
+	r = NewBasicError(errors.New("foo"))
+	if r.ExitCode() != 0 {
+		t.Fatalf("bad: %#v", r)
+	}
+}
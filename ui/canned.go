@@ -0,0 +1,43 @@
+package ui
+
+// Canned is an implementation of Ui that answers Input calls from a
+// set of pre-seeded answers, keyed by the Id of the input. Inputs that
+// don't have an answer, and all output, are passed through to Ui.
+//
+// This is useful for scripted runs and for testing interactive flows.
+type Canned struct {
+	Ui      Ui
+	Answers map[string]string
+}
+
+func (c *Canned) Header(msg string) {
+	c.Ui.Header(msg)
+}
+
+func (c *Canned) Message(msg string) {
+	c.Ui.Message(msg)
+}
+
+func (c *Canned) Raw(msg string) {
+	c.Ui.Raw(msg)
+}
+
+func (c *Canned) Input(opts *InputOpts) (string, error) {
+	if v, ok := c.Answers[opts.Id]; ok {
+		return v, nil
+	}
+
+	return c.Ui.Input(opts)
+}
+
+func (c *Canned) Interactive() bool {
+	return IsInteractive(c.Ui)
+}
+
+func (c *Canned) HasAnswer(opts *InputOpts) bool {
+	if _, ok := c.Answers[opts.Id]; ok {
+		return true
+	}
+
+	return HasAnswer(c.Ui, opts)
+}
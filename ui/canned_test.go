@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"testing"
+)
+
+func TestCanned_impl(t *testing.T) {
+	var _ Ui = new(Canned)
+}
+
+func TestCanned(t *testing.T) {
+	mock := &Mock{InputResult: "real"}
+	u := &Canned{
+		Ui:      mock,
+		Answers: map[string]string{"foo": "canned"},
+	}
+
+	v, err := u.Input(&InputOpts{Id: "foo"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "canned" || mock.InputCalled {
+		t.Fatalf("bad: %s", v)
+	}
+
+	v, err = u.Input(&InputOpts{Id: "bar"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "real" || !mock.InputCalled {
+		t.Fatalf("bad: %s", v)
+	}
+}
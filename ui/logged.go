@@ -29,3 +29,11 @@ func (l *Logged) Input(opts *InputOpts) (string, error) {
 	// Not sure what to log here.
 	return l.Ui.Input(opts)
 }
+
+func (l *Logged) Interactive() bool {
+	return IsInteractive(l.Ui)
+}
+
+func (l *Logged) HasAnswer(opts *InputOpts) bool {
+	return HasAnswer(l.Ui, opts)
+}
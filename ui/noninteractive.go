@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NonInteractive is an implementation of Ui for environments where
+// nobody can answer questions, such as a daemon. Input calls are answered
+// from the EnvVars of the input, then its Default. Otherwise they fail
+// with an *InputRequiredError rather than waiting for an answer. All
+// output is passed through to Ui.
+type NonInteractive struct {
+	Ui Ui
+}
+
+func (n *NonInteractive) Header(msg string) {
+	n.Ui.Header(msg)
+}
+
+func (n *NonInteractive) Message(msg string) {
+	n.Ui.Message(msg)
+}
+
+func (n *NonInteractive) Raw(msg string) {
+	n.Ui.Raw(msg)
+}
+
+func (n *NonInteractive) Input(opts *InputOpts) (string, error) {
+	if v := opts.EnvVarValue(); v != "" {
+		return v, nil
+	}
+	if opts.Default != "" {
+		return opts.Default, nil
+	}
+
+	return "", &InputRequiredError{Opts: opts}
+}
+
+func (n *NonInteractive) Interactive() bool { return false }
+
+func (n *NonInteractive) HasAnswer(opts *InputOpts) bool {
+	return opts.EnvVarValue() != "" || opts.Default != ""
+}
+
+// InputRequiredError is the error returned by NonInteractive for an input
+// that has no answer.
+type InputRequiredError struct {
+	Opts *InputOpts
+}
+
+func (e *InputRequiredError) Error() string {
+	return fmt.Sprintf(
+		"cannot prompt in non-interactive mode; provide %s", InputSources(e.Opts))
+}
+
+// InputSources describes the ways the answer to an input can be given
+// without a prompt, such as "the OTTO_CREDS_PASSWORD environment variable
+// or the answer "creds_password"".
+func InputSources(opts *InputOpts) string {
+	var sources []string
+	for _, v := range opts.EnvVars {
+		sources = append(sources, fmt.Sprintf("the %s environment variable", v))
+	}
+	if opts.Id != "" {
+		sources = append(sources, fmt.Sprintf("the answer %q", opts.Id))
+	}
+
+	name := opts.Query
+	if name == "" {
+		name = opts.Id
+	}
+	if len(sources) == 0 {
+		return name
+	}
+
+	return fmt.Sprintf("%s via %s", name, strings.Join(sources, " or "))
+}
+
+// IsInteractive returns true if the Ui can ask questions. A Ui can
+// report this with an Interactive() bool method; others are assumed to
+// be interactive, except for Null.
+func IsInteractive(u Ui) bool {
+	switch v := u.(type) {
+	case *Null:
+		return false
+	case interface {
+		Interactive() bool
+	}:
+		return v.Interactive()
+	default:
+		return true
+	}
+}
+
+// HasAnswer returns true if the Ui answers the input without asking,
+// such as from a canned answer. A Ui can report this with a
+// HasAnswer(*InputOpts) bool method; others never do.
+func HasAnswer(u Ui, opts *InputOpts) bool {
+	if v, ok := u.(interface {
+		HasAnswer(*InputOpts) bool
+	}); ok {
+		return v.HasAnswer(opts)
+	}
+
+	return false
+}
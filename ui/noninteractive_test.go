@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNonInteractive_impl(t *testing.T) {
+	var _ Ui = new(NonInteractive)
+}
+
+func TestNonInteractive(t *testing.T) {
+	mock := new(Mock)
+	u := &NonInteractive{Ui: mock}
+
+	defer os.Setenv("OTTO_TEST_NONINTERACTIVE", os.Getenv("OTTO_TEST_NONINTERACTIVE"))
+	os.Setenv("OTTO_TEST_NONINTERACTIVE", "env")
+	v, err := u.Input(&InputOpts{Id: "foo", EnvVars: []string{"OTTO_TEST_NONINTERACTIVE"}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "env" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	v, err = u.Input(&InputOpts{Id: "foo", Default: "default"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "default" {
+		t.Fatalf("bad: %s", v)
+	}
+
+	opts := &InputOpts{
+		Id:      "password",
+		Query:   "Password",
+		EnvVars: []string{"OTTO_TEST_MISSING"},
+	}
+	if u.HasAnswer(opts) {
+		t.Fatal("shouldn't have an answer")
+	}
+	_, err = u.Input(opts)
+	if _, ok := err.(*InputRequiredError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if !strings.Contains(err.Error(), `Password via the OTTO_TEST_MISSING environment variable or the answer "password"`) {
+		t.Fatalf("bad: %s", err)
+	}
+	if mock.InputCalled {
+		t.Fatal("input shouldn't be called")
+	}
+}
+
+func TestIsInteractive(t *testing.T) {
+	cases := []struct {
+		Ui       Ui
+		Expected bool
+	}{
+		{new(Mock), true},
+		{new(Null), false},
+		{&NonInteractive{Ui: new(Mock)}, false},
+		{&Logged{Ui: &Canned{Ui: &NonInteractive{Ui: new(Mock)}}}, false},
+		{&Logged{Ui: new(Mock)}, true},
+	}
+
+	for i, tc := range cases {
+		if actual := IsInteractive(tc.Ui); actual != tc.Expected {
+			t.Fatalf("%d: bad: %v", i, actual)
+		}
+	}
+}
+
+func TestHasAnswer(t *testing.T) {
+	u := &Logged{Ui: &Canned{
+		Ui:      &NonInteractive{Ui: new(Mock)},
+		Answers: map[string]string{"foo": "canned"},
+	}}
+
+	if !HasAnswer(u, &InputOpts{Id: "foo"}) {
+		t.Fatal("should have an answer")
+	}
+	if HasAnswer(u, &InputOpts{Id: "bar"}) {
+		t.Fatal("shouldn't have an answer")
+	}
+	if HasAnswer(new(Mock), &InputOpts{Id: "foo"}) {
+		t.Fatal("shouldn't have an answer")
+	}
+}